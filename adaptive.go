@@ -0,0 +1,199 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// adaptiveWindow is the number of latency samples kept per host.
+	adaptiveWindow = 100
+
+	// adaptiveMinSamples is the number of samples required before the
+	// observed latency of a host is used to compute its timeout.
+	adaptiveMinSamples = 20
+)
+
+// WithAdaptiveTimeout makes the clients of the pool derive the deadline
+// of every request from the latency recently observed for its host. The
+// deadline is set on the request context to the specified percentile,
+// between 0 and 1, of the latencies of the last requests to the host,
+// clamped to [minimum, maximum]. Until enough requests have been observed
+// for a host, the maximum is used. The latency of a request spans the
+// whole time its deadline applies, until its response body is read or
+// closed. Requests timing out count with the latency of their deadline,
+// and every consecutive timeout doubles the deadline of the next
+// requests to the host, up to the maximum, so that a host slowing down
+// is not cut off for good.
+//
+// Since the deadline is enforced through the context, it can only
+// tighten the timeout of the client used to send the request, or an
+// earlier deadline of the request context.
+func WithAdaptiveTimeout(minimum, maximum time.Duration, percentile float64) Option {
+	timeouts := &adaptiveTimeouts{
+		minimum:    minimum,
+		maximum:    maximum,
+		percentile: percentile,
+		hosts:      make(map[string]*latencyWindow),
+	}
+
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			return &adaptiveTransport{
+				next:     next,
+				timeouts: timeouts,
+				clock:    c.timeSource(),
+			}
+		})
+	}
+}
+
+// latencyWindow is a ring buffer of the latest latency samples, along
+// with the number of consecutive timeouts.
+type latencyWindow struct {
+	samples  [adaptiveWindow]time.Duration
+	count    int
+	next     int
+	timeouts int
+}
+
+// adaptiveTimeouts tracks the latency of every host to compute their
+// timeouts.
+type adaptiveTimeouts struct {
+	minimum    time.Duration
+	maximum    time.Duration
+	percentile float64
+
+	mtx   sync.Mutex
+	hosts map[string]*latencyWindow
+}
+
+// observe records the latency of a request to the host, which timed out
+// if timedOut is set.
+func (a *adaptiveTimeouts) observe(host string, latency time.Duration, timedOut bool) {
+	a.mtx.Lock()
+	{
+		window := a.hosts[host]
+		if window == nil {
+			window = &latencyWindow{}
+			a.hosts[host] = window
+		}
+
+		window.samples[window.next] = latency
+		window.next = (window.next + 1) % adaptiveWindow
+		if window.count < adaptiveWindow {
+			window.count++
+		}
+
+		if timedOut {
+			window.timeouts++
+		} else {
+			window.timeouts = 0
+		}
+	}
+	a.mtx.Unlock()
+}
+
+// timeout returns the timeout to use for the next request to the host.
+func (a *adaptiveTimeouts) timeout(host string) time.Duration {
+	var samples []time.Duration
+	var timeouts int
+
+	a.mtx.Lock()
+	{
+		if window := a.hosts[host]; window != nil && window.count >= adaptiveMinSamples {
+			samples = append(samples, window.samples[:window.count]...)
+			timeouts = window.timeouts
+		}
+	}
+	a.mtx.Unlock()
+
+	if samples == nil {
+		return a.maximum
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	i := int(a.percentile*float64(len(samples))+0.5) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(samples) {
+		i = len(samples) - 1
+	}
+
+	timeout := samples[i]
+	for ; timeouts > 0 && timeout < a.maximum; timeouts-- {
+		timeout *= 2
+	}
+	if timeout < a.minimum {
+		timeout = a.minimum
+	} else if timeout > a.maximum {
+		timeout = a.maximum
+	}
+	return timeout
+}
+
+// adaptiveTransport sets the deadline of the requests sent through the
+// next transport from the observed latency of their host.
+type adaptiveTransport struct {
+	next     http.RoundTripper
+	timeouts *adaptiveTimeouts
+	clock    clock
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *adaptiveTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	timeout := t.timeouts.timeout(host)
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+
+	start := t.clock.Now()
+	var once sync.Once
+	done := func(failed bool) {
+		once.Do(func() {
+			// Only the requests stopped by the deadline set here
+			// timed out. The latency of the other failures is not
+			// meaningful.
+			switch {
+			case ctx.Err() == context.DeadlineExceeded && req.Context().Err() == nil:
+				t.timeouts.observe(host, timeout, true)
+			case !failed:
+				t.timeouts.observe(host, t.clock.Now().Sub(start), false)
+			}
+			cancel()
+		})
+	}
+
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		done(true)
+		return nil, err
+	}
+
+	resp.Body = &adaptiveBody{ReadCloser: resp.Body, done: done}
+	return resp, nil
+}
+
+// adaptiveBody records the latency of a request once its body is read
+// entirely, fails or is closed.
+type adaptiveBody struct {
+	io.ReadCloser
+	done func(failed bool)
+}
+
+func (b *adaptiveBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.done(err != io.EOF)
+	}
+	return n, err
+}
+
+func (b *adaptiveBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done(false)
+	return err
+}
@@ -0,0 +1,218 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	nethttp "net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// latencyTransport answers requests after the delay returned by latency
+// and records the remaining time until the deadline of each request.
+type latencyTransport struct {
+	latency func(*nethttp.Request) time.Duration
+
+	mtx       sync.Mutex
+	remaining []time.Duration
+}
+
+func (t *latencyTransport) RoundTrip(req *nethttp.Request) (*nethttp.Response, error) {
+	if deadline, ok := req.Context().Deadline(); ok {
+		t.mtx.Lock()
+		t.remaining = append(t.remaining, time.Until(deadline))
+		t.mtx.Unlock()
+	}
+
+	time.Sleep(t.latency(req))
+	return statusResponse(req, nethttp.StatusOK), nil
+}
+
+func (t *latencyTransport) last() time.Duration {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	return t.remaining[len(t.remaining)-1]
+}
+
+func TestAdaptiveTimeoutPercentile(t *testing.T) {
+	var n int
+	transport := &latencyTransport{latency: func(*nethttp.Request) time.Duration {
+		// Cycles through 2ms, 4ms, ..., 20ms.
+		n++
+		return time.Duration(n%10+1) * 2 * time.Millisecond
+	}}
+
+	cp := http.NewClientPool(http.WithAdaptiveTimeout(time.Millisecond, time.Second, 0.9))
+	cp.SetTransport(transport)
+	client := cp.GetClient(5 * time.Second)
+
+	get := func() {
+		resp, err := client.Get("http://example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	// The maximum is used while warming up.
+	get()
+	if remaining := transport.last(); remaining < 900*time.Millisecond {
+		t.Fatalf("warm-up deadline in %v, want about 1s", remaining)
+	}
+
+	for i := 0; i < 30; i++ {
+		get()
+	}
+	if remaining := transport.last(); remaining < 16*time.Millisecond || remaining > 30*time.Millisecond {
+		t.Fatalf("deadline in %v, want about the 90th percentile of 18ms", remaining)
+	}
+}
+
+func TestAdaptiveTimeoutClamp(t *testing.T) {
+	transport := &latencyTransport{latency: func(*nethttp.Request) time.Duration {
+		return 0
+	}}
+
+	cp := http.NewClientPool(http.WithAdaptiveTimeout(200*time.Millisecond, time.Second, 0.5))
+	cp.SetTransport(transport)
+	client := cp.GetClient(5 * time.Second)
+
+	for i := 0; i < 25; i++ {
+		resp, err := client.Get("http://example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if remaining := transport.last(); remaining < 150*time.Millisecond || remaining > 200*time.Millisecond {
+		t.Fatalf("deadline in %v, want the 200ms minimum", remaining)
+	}
+}
+
+func TestAdaptiveTimeoutPerHost(t *testing.T) {
+	transport := &latencyTransport{latency: func(req *nethttp.Request) time.Duration {
+		if req.URL.Host == "slow.example.com" {
+			return 10 * time.Millisecond
+		}
+		return 0
+	}}
+
+	cp := http.NewClientPool(http.WithAdaptiveTimeout(time.Millisecond, time.Second, 0.5))
+	cp.SetTransport(transport)
+	client := cp.GetClient(5 * time.Second)
+
+	for i := 0; i < 25; i++ {
+		resp, err := client.Get("http://slow.example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	// The latency of another host does not affect a new host.
+	resp, err := client.Get("http://fast.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if remaining := transport.last(); remaining < 900*time.Millisecond {
+		t.Fatalf("deadline in %v, want the maximum for a new host", remaining)
+	}
+}
+
+func TestAdaptiveTimeoutSlowdown(t *testing.T) {
+	var slow int32
+	cp := http.NewClientPool(http.WithAdaptiveTimeout(5*time.Millisecond, time.Second, 0.9))
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		if atomic.LoadInt32(&slow) == 0 {
+			return statusResponse(req, nethttp.StatusOK), nil
+		}
+		select {
+		case <-time.After(40 * time.Millisecond):
+			return statusResponse(req, nethttp.StatusOK), nil
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}))
+	client := cp.GetClient(5 * time.Second)
+
+	for i := 0; i < 25; i++ {
+		resp, err := client.Get("http://example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	// The host slows down past its deadline: the consecutive timeouts
+	// widen the deadline until the requests succeed again.
+	atomic.StoreInt32(&slow, 1)
+	timeouts := 0
+	for {
+		resp, err := client.Get("http://example.com/")
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal(err)
+		}
+		if timeouts++; timeouts > 5 {
+			t.Fatalf("still timing out after %d requests", timeouts)
+		}
+	}
+	if timeouts == 0 {
+		t.Fatal("slow request did not time out")
+	}
+}
+
+// slowBody returns its payload after a delay.
+type slowBody struct {
+	delay time.Duration
+	read  bool
+}
+
+func (b *slowBody) Read(p []byte) (int, error) {
+	if b.read {
+		return 0, io.EOF
+	}
+	time.Sleep(b.delay)
+	b.read = true
+	return copy(p, "ok"), nil
+}
+
+func (b *slowBody) Close() error { return nil }
+
+func TestAdaptiveTimeoutBody(t *testing.T) {
+	transport := &latencyTransport{latency: func(*nethttp.Request) time.Duration { return 0 }}
+	cp := http.NewClientPool(http.WithAdaptiveTimeout(time.Millisecond, time.Second, 0.5))
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		resp, _ := transport.RoundTrip(req)
+		resp.Body = &slowBody{delay: 20 * time.Millisecond}
+		return resp, nil
+	}))
+	client := cp.GetClient(5 * time.Second)
+
+	// The deadline also covers reading the body, whose time counts in
+	// the latency.
+	for i := 0; i < 25; i++ {
+		resp, err := client.Get("http://example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(resp.Body); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if remaining := transport.last(); remaining < 18*time.Millisecond {
+		t.Fatalf("deadline in %v, want at least the 20ms taken by the body", remaining)
+	}
+}
@@ -0,0 +1,160 @@
+package http
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// affinityIdleConnTimeout is the duration after which the idle
+	// connections of the transports of the affinity keys are closed,
+	// unless the base transport sets its own.
+	affinityIdleConnTimeout = 90 * time.Second
+)
+
+// affinityMaxKeys is the number of affinity keys whose transports are
+// kept, the least recently used being discarded first.
+var affinityMaxKeys = 1024
+
+// affinityKey is the context key for the affinity key of a request.
+type affinityKey struct{}
+
+// ContextWithAffinityKey returns a copy of the context carrying the
+// specified affinity key. When the pool is created with
+// WithConnectionAffinity, the requests sent with contexts carrying the
+// same key share the same connection.
+func ContextWithAffinityKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, affinityKey{}, key)
+}
+
+// WithConnectionAffinity makes the clients of the pool send the requests
+// carrying the same affinity key, see ContextWithAffinityKey, over the
+// same connection to a host, for backends sharding their sessions by
+// connection. Every key is given its own copy of the transport, limited
+// to a single connection per host, so requests with distinct keys use
+// distinct connections and concurrent requests with the same key wait
+// for each other. Requests without a key use the transport of the pool
+// as usual.
+//
+// Keys are tied to the transport they were first used with, and are
+// discarded when the transport settings of the pool change. The
+// transports of up to 1024 keys are kept, those of the least recently
+// used keys being discarded first, and their idle connections are
+// closed after 90 seconds unless the transport sets its own
+// IdleConnTimeout. Affinity is
+// only supported by transports of type *http.Transport; other transports
+// are used as is.
+func WithConnectionAffinity() Option {
+	return func(c *ClientPool) {
+		c.affinity = newConnAffinity()
+		c.resetClients()
+	}
+}
+
+// affinityTransportKey identifies the transport of an affinity key,
+// copied from a base transport.
+type affinityTransportKey struct {
+	base *http.Transport
+	key  string
+}
+
+// affinityEntry is the transport of an affinity key.
+type affinityEntry struct {
+	key       affinityTransportKey
+	transport *http.Transport
+}
+
+// connAffinity holds the transports of the affinity keys.
+type connAffinity struct {
+	mtx        sync.Mutex
+	transports map[affinityTransportKey]*list.Element
+
+	// used holds the entries of the transports, the most recently used
+	// first.
+	used *list.List
+}
+
+func newConnAffinity() *connAffinity {
+	return &connAffinity{
+		transports: make(map[affinityTransportKey]*list.Element),
+		used:       list.New(),
+	}
+}
+
+// transport returns the transport of the affinity key, copied from the
+// base transport, discarding the transport of the least recently used
+// key if too many are kept.
+func (a *connAffinity) transport(base *http.Transport, key string) *http.Transport {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	k := affinityTransportKey{base: base, key: key}
+	if e := a.transports[k]; e != nil {
+		a.used.MoveToFront(e)
+		return e.Value.(*affinityEntry).transport
+	}
+
+	t := base.Clone()
+	t.MaxConnsPerHost = 1
+	t.MaxIdleConnsPerHost = 1
+	if t.IdleConnTimeout == 0 {
+		t.IdleConnTimeout = affinityIdleConnTimeout
+	}
+	a.transports[k] = a.used.PushFront(&affinityEntry{key: k, transport: t})
+
+	for a.used.Len() > affinityMaxKeys {
+		entry := a.used.Remove(a.used.Back()).(*affinityEntry)
+		delete(a.transports, entry.key)
+		entry.transport.CloseIdleConnections()
+	}
+	return t
+}
+
+// reset discards the transports of the affinity keys, closing their idle
+// connections.
+func (a *connAffinity) reset() {
+	a.mtx.Lock()
+	{
+		for e := a.used.Front(); e != nil; e = e.Next() {
+			e.Value.(*affinityEntry).transport.CloseIdleConnections()
+		}
+		a.transports = make(map[affinityTransportKey]*list.Element)
+		a.used.Init()
+	}
+	a.mtx.Unlock()
+}
+
+// closeIdle closes the idle connections of the transports of the
+// affinity keys copied from the base transport.
+func (a *connAffinity) closeIdle(base *http.Transport) {
+	a.mtx.Lock()
+	{
+		for k, e := range a.transports {
+			if k.base == base {
+				e.Value.(*affinityEntry).transport.CloseIdleConnections()
+			}
+		}
+	}
+	a.mtx.Unlock()
+}
+
+// affinityTransport sends the requests carrying an affinity key through
+// the transport of their key, copied from the base transport, and the
+// other requests through the next transport.
+type affinityTransport struct {
+	next     http.RoundTripper
+	base     *http.Transport
+	affinity *connAffinity
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *affinityTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key, ok := req.Context().Value(affinityKey{}).(string)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+	return t.affinity.transport(t.base, key).RoundTrip(req)
+}
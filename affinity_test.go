@@ -0,0 +1,114 @@
+package http_test
+
+import (
+	"context"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// connAddr sends a GET request with the context and returns the local
+// address of the connection it was sent over.
+func connAddr(t *testing.T, client *nethttp.Client, ctx context.Context, url string) string {
+	t.Helper()
+
+	var addr string
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			addr = info.Conn.LocalAddr().String()
+		},
+	})
+	req, _ := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, url, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return addr
+}
+
+func TestConnectionAffinity(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	defer server.Close()
+
+	cp := http.NewClientPool(http.WithConnectionAffinity())
+	cp.SetTransport(&nethttp.Transport{})
+	client := cp.GetClient(time.Second)
+
+	a := http.ContextWithAffinityKey(context.Background(), "a")
+	b := http.ContextWithAffinityKey(context.Background(), "b")
+
+	first := connAddr(t, client, a, server.URL)
+	other := connAddr(t, client, b, server.URL)
+	unkeyed := connAddr(t, client, context.Background(), server.URL)
+	again := connAddr(t, client, a, server.URL)
+
+	if first != again {
+		t.Errorf("requests with the same key used connections %s and %s", first, again)
+	}
+	if first == other {
+		t.Errorf("requests with distinct keys shared connection %s", first)
+	}
+	if unkeyed == first || unkeyed == other {
+		t.Errorf("unkeyed request used the connection %s of a key", unkeyed)
+	}
+
+	// Clients for other timeouts use the same connections.
+	if addr := connAddr(t, cp.GetClient(2*time.Second), b, server.URL); addr != other {
+		t.Errorf("request with the same key used connections %s and %s across timeouts", other, addr)
+	}
+}
+
+func TestConnectionAffinityMaxKeys(t *testing.T) {
+	defer http.SetAffinityMaxKeys(2)()
+
+	var mtx sync.Mutex
+	closed := 0
+	server := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	server.Config.ConnState = func(_ net.Conn, state nethttp.ConnState) {
+		if state == nethttp.StateClosed {
+			mtx.Lock()
+			closed++
+			mtx.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	cp := http.NewClientPool(http.WithConnectionAffinity())
+	cp.SetTransport(&nethttp.Transport{})
+	client := cp.GetClient(time.Second)
+
+	ctx := func(key string) context.Context {
+		return http.ContextWithAffinityKey(context.Background(), key)
+	}
+	first := connAddr(t, client, ctx("a"), server.URL)
+	connAddr(t, client, ctx("b"), server.URL)
+	if connAddr(t, client, ctx("a"), server.URL) != first {
+		t.Fatal("recently used key changed connection")
+	}
+
+	// The third key discards the least recently used one, b, and
+	// closes its connection.
+	connAddr(t, client, ctx("c"), server.URL)
+	if connAddr(t, client, ctx("a"), server.URL) != first {
+		t.Fatal("recently used key changed connection")
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		mtx.Lock()
+		n := closed
+		mtx.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("closed %d connections, want the one of the discarded key", n)
+		}
+	}
+}
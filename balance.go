@@ -0,0 +1,119 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoEndpoints is returned by DoBalanced when no endpoint is set.
+var ErrNoEndpoints = errors.New("http: no endpoints")
+
+// WeightedEndpoint is an endpoint used by DoBalanced.
+type WeightedEndpoint struct {
+	// URL is the base URL of the endpoint.
+	URL string
+
+	// Weight is the share of the requests sent to the endpoint,
+	// relative to the weights of the others. Endpoints with a
+	// non-positive weight are only used for failover.
+	Weight int
+}
+
+// SetEndpoints sets the equivalent endpoints across which DoBalanced
+// distributes the requests.
+func (c *ClientPool) SetEndpoints(endpoints []WeightedEndpoint) {
+	b := &balancer{
+		endpoints: append([]WeightedEndpoint(nil), endpoints...),
+		current:   make([]int, len(endpoints)),
+	}
+
+	c.mtx.Lock()
+	{
+		c.balancer = b
+	}
+	c.mtx.Unlock()
+}
+
+// DoBalanced sends a request to one of the endpoints set with
+// SetEndpoints, using a client of the pool with the specified timeout.
+// Endpoints are picked by smooth weighted round-robin, and the path is
+// appended to the base URL of the chosen endpoint. If the request fails
+// to connect to the endpoint, it is sent to the next endpoints in turn,
+// provided its body can be sent again: bodies of type *bytes.Buffer,
+// *bytes.Reader and *strings.Reader can. Failures are returned as a
+// *RequestError.
+func (c *ClientPool) DoBalanced(ctx context.Context, method, path string, body io.Reader, timeout time.Duration) (*http.Response, error) {
+	c.mtx.RLock()
+	b := c.balancer
+	c.mtx.RUnlock()
+
+	if b == nil || len(b.endpoints) == 0 {
+		return nil, ErrNoEndpoints
+	}
+
+	first := b.next()
+
+	var req *http.Request
+	var err error
+	for i := range b.endpoints {
+		endpoint := b.endpoints[(first+i)%len(b.endpoints)]
+		target := strings.TrimSuffix(endpoint.URL, "/") + "/" + strings.TrimPrefix(path, "/")
+
+		if req == nil {
+			req, err = http.NewRequestWithContext(ctx, method, target, body)
+		} else {
+			req, err = rewindRequest(req)
+			if err == nil {
+				req.URL, err = req.URL.Parse(target)
+				req.Host = ""
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var resp *http.Response
+		resp, err = c.sendTimeout(timeout, req, c.do)
+		if err == nil || !isDialError(err) || !canRewind(req) {
+			return resp, err
+		}
+	}
+	return nil, err
+}
+
+// balancer picks endpoints by smooth weighted round-robin.
+type balancer struct {
+	endpoints []WeightedEndpoint
+
+	mtx     sync.Mutex
+	current []int
+}
+
+// next returns the index of the next endpoint.
+func (b *balancer) next() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	best, total := -1, 0
+	for i, endpoint := range b.endpoints {
+		if endpoint.Weight <= 0 {
+			continue
+		}
+		b.current[i] += endpoint.Weight
+		total += endpoint.Weight
+		if best < 0 || b.current[i] > b.current[best] {
+			best = i
+		}
+	}
+	if best < 0 {
+		return 0
+	}
+
+	b.current[best] -= total
+	return best
+}
@@ -0,0 +1,98 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	nethttp "net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// hostRecorder records the URLs of the requests, failing to connect to
+// the hosts named "down".
+type hostRecorder struct {
+	mtx    sync.Mutex
+	urls   []string
+	bodies []string
+}
+
+func (r *hostRecorder) RoundTrip(req *nethttp.Request) (*nethttp.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+		req.Body.Close()
+	}
+
+	r.mtx.Lock()
+	r.urls = append(r.urls, req.URL.String())
+	r.bodies = append(r.bodies, string(body))
+	r.mtx.Unlock()
+
+	if strings.HasPrefix(req.URL.Host, "down") {
+		return nil, errFailing
+	}
+	return statusResponse(req, nethttp.StatusOK), nil
+}
+
+func TestDoBalancedWeights(t *testing.T) {
+	recorder := &hostRecorder{}
+	cp := http.NewClientPool()
+	cp.SetTransport(recorder)
+	cp.SetEndpoints([]http.WeightedEndpoint{
+		{URL: "http://a.example.com/api/", Weight: 3},
+		{URL: "http://b.example.com", Weight: 1},
+		{URL: "http://spare.example.com", Weight: 0},
+	})
+
+	for i := 0; i < 8; i++ {
+		resp, err := cp.DoBalanced(context.Background(), nethttp.MethodGet, "/users?page=1", nil, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	counts := make(map[string]int)
+	for _, url := range recorder.urls {
+		counts[url]++
+	}
+	if counts["http://a.example.com/api/users?page=1"] != 6 || counts["http://b.example.com/users?page=1"] != 2 {
+		t.Fatalf("got requests %v, want 6 to a and 2 to b", counts)
+	}
+}
+
+func TestDoBalancedFailover(t *testing.T) {
+	recorder := &hostRecorder{}
+	cp := http.NewClientPool()
+	cp.SetTransport(recorder)
+	cp.SetEndpoints([]http.WeightedEndpoint{
+		{URL: "http://down.example.com", Weight: 1},
+		{URL: "http://up.example.com", Weight: 0},
+	})
+
+	resp, err := cp.DoBalanced(context.Background(), nethttp.MethodPost, "items", strings.NewReader("payload"), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	want := []string{"http://down.example.com/items", "http://up.example.com/items"}
+	if len(recorder.urls) != 2 || recorder.urls[0] != want[0] || recorder.urls[1] != want[1] {
+		t.Fatalf("sent requests to %q, want %q", recorder.urls, want)
+	}
+	if recorder.bodies[1] != "payload" {
+		t.Fatalf("failover sent body %q, want the original body", recorder.bodies[1])
+	}
+}
+
+func TestDoBalancedNoEndpoints(t *testing.T) {
+	cp := http.NewClientPool()
+	if _, err := cp.DoBalanced(context.Background(), nethttp.MethodGet, "/", nil, time.Second); !errors.Is(err, http.ErrNoEndpoints) {
+		t.Fatalf("error = %v, want %v", err, http.ErrNoEndpoints)
+	}
+}
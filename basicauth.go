@@ -0,0 +1,64 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// SetBasicAuth makes the clients of the pool authenticate their requests
+// with HTTP Basic authentication, using the specified credentials for
+// the requests without an Authorization header. The requests setting the
+// header themselves, or carrying credentials in their URL, keep their
+// own. The credentials are not sent with the requests following a
+// redirect to another origin than the one of the original request, so
+// that they are never leaked to another server. Calling it with an empty
+// user and password removes the credentials.
+func (c *ClientPool) SetBasicAuth(user, pass string) {
+	c.mtx.Lock()
+	{
+		if user == "" && pass == "" {
+			c.basicAuth = nil
+		} else {
+			c.basicAuth = url.UserPassword(user, pass)
+		}
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+	c.mtx.Unlock()
+}
+
+// basicAuthTransport sets the Basic authentication credentials on the
+// requests sent through the next transport.
+type basicAuthTransport struct {
+	next http.RoundTripper
+	auth *url.Userinfo
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" || !sameOrigin(req.URL, originalURL(req)) {
+		return t.next.RoundTrip(req)
+	}
+
+	pass, _ := t.auth.Password()
+	areq := req.Clone(req.Context())
+	areq.SetBasicAuth(t.auth.Username(), pass)
+	return t.next.RoundTrip(areq)
+}
+
+// originalURL returns the URL of the request that led to the request
+// through redirects, or the URL of the request if it is not a redirect.
+func originalURL(req *http.Request) *url.URL {
+	for req.Response != nil && req.Response.Request != nil {
+		req = req.Response.Request
+	}
+	return req.URL
+}
+
+// sameOrigin reports whether the URLs have the same scheme, host and
+// port.
+func sameOrigin(a, b *url.URL) bool {
+	return a.Scheme == b.Scheme && canonicalAddr(a) == canonicalAddr(b)
+}
@@ -0,0 +1,105 @@
+package http_test
+
+import (
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// newAuthEchoServer returns a server answering with the user of the
+// Basic authentication credentials of the requests, if any.
+func newAuthEchoServer() *httptest.Server {
+	return httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if user, _, ok := r.BasicAuth(); ok {
+			io.WriteString(w, user)
+		}
+	}))
+}
+
+// getUser returns the body of the response to a GET request, the user
+// seen by the server.
+func getUser(t *testing.T, client *nethttp.Client, req *nethttp.Request) string {
+	t.Helper()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	user, _ := io.ReadAll(resp.Body)
+	return string(user)
+}
+
+func TestBasicAuth(t *testing.T) {
+	server := newAuthEchoServer()
+	defer server.Close()
+
+	pool := http.NewClientPool()
+	pool.SetBasicAuth("tool", "secret")
+	client := pool.GetClient(time.Second)
+
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, server.URL, nil)
+	if user := getUser(t, client, req); user != "tool" {
+		t.Errorf("user = %q, want %q", user, "tool")
+	}
+
+	// The credentials of the caller win.
+	req, _ = nethttp.NewRequest(nethttp.MethodGet, server.URL, nil)
+	req.SetBasicAuth("caller", "other")
+	if user := getUser(t, client, req); user != "caller" {
+		t.Errorf("user = %q, want %q", user, "caller")
+	}
+	req, _ = nethttp.NewRequest(nethttp.MethodGet, strings.Replace(server.URL, "://", "://url:pass@", 1), nil)
+	if user := getUser(t, client, req); user != "url" {
+		t.Errorf("user = %q, want %q", user, "url")
+	}
+
+	pool.SetBasicAuth("", "")
+	req, _ = nethttp.NewRequest(nethttp.MethodGet, server.URL, nil)
+	if user := getUser(t, pool.GetClient(time.Second), req); user != "" {
+		t.Errorf("user = %q, want no credentials once removed", user)
+	}
+}
+
+func TestBasicAuthRedirect(t *testing.T) {
+	other := newAuthEchoServer()
+	defer other.Close()
+
+	var origin *httptest.Server
+	origin = httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		switch r.URL.Path {
+		case "/same":
+			nethttp.Redirect(w, r, origin.URL+"/echo", nethttp.StatusFound)
+		case "/cross":
+			nethttp.Redirect(w, r, other.URL, nethttp.StatusFound)
+		default:
+			if user, _, ok := r.BasicAuth(); ok {
+				io.WriteString(w, user)
+			}
+		}
+	}))
+	defer origin.Close()
+
+	pool := http.NewClientPool()
+	pool.SetBasicAuth("tool", "secret")
+	client := pool.GetClient(time.Second)
+
+	for _, test := range []struct {
+		path string
+		user string
+	}{
+		{"/same", "tool"},
+		{"/cross", ""},
+	} {
+		req, _ := nethttp.NewRequest(nethttp.MethodGet, origin.URL+test.path, nil)
+		if user := getUser(t, client, req); user != test.user {
+			t.Errorf("%s: user = %q, want %q", test.path, user, test.user)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package http
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for requests to a host whose circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("http: circuit breaker is open")
+
+// State is the state of the circuit breaker of a host.
+type State int
+
+const (
+	// StateClosed lets requests through.
+	StateClosed State = iota
+
+	// StateOpen fails requests without sending them.
+	StateOpen
+
+	// StateHalfOpen lets a single request through to probe whether
+	// the host has recovered.
+	StateHalfOpen
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// hostBreaker is the circuit breaker of a single host.
+type hostBreaker struct {
+	state    State
+	failures int
+	openedAt time.Time
+
+	// probing is set while the request probing a half-open breaker is
+	// in flight.
+	probing bool
+
+	// tripped is set when the breaker was opened manually, in which
+	// case it stays open until reset.
+	tripped bool
+
+	// failureWeight and totalWeight are the exponentially decayed
+	// counts of the failed and of all the requests, as of updatedAt,
+	// and samples the number of requests recorded, for the breakers
+	// opening on the failure rate.
+	failureWeight float64
+	totalWeight   float64
+	updatedAt     time.Time
+	samples       int
+}
+
+// ewmaMinSamples is the number of requests recorded before the failure
+// rate can open a circuit, so that a few early failures do not.
+const ewmaMinSamples = 10
+
+// CircuitBreaker tracks the failures of the requests to every host and
+// opens the circuit of a host after too many consecutive failures, so
+// that its requests fail fast with ErrCircuitOpen. Once the cooldown
+// has elapsed, the circuit is half-open and a single request is let
+// through: if it succeeds the circuit is closed, otherwise it opens
+// again. Requests failing with an error or a 5xx status code count as
+// failures.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	// rateThreshold is the failure rate opening a circuit, and
+	// halfLife the decay of the past requests, if the breaker opens on
+	// the failure rate rather than on consecutive failures.
+	rateThreshold float64
+	halfLife      time.Duration
+
+	mtx   sync.Mutex
+	hosts map[string]*hostBreaker
+
+	// clock is the clock of the pool the breaker is installed in, or
+	// nil for the system clock.
+	clock clock
+}
+
+// NewCircuitBreaker returns a CircuitBreaker opening the circuit of a
+// host after threshold consecutive failures, for the cooldown duration.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		hosts:     make(map[string]*hostBreaker),
+	}
+}
+
+// NewEWMACircuitBreaker returns a CircuitBreaker opening the circuit of
+// a host once its recent failure rate reaches threshold, between 0 and
+// 1, rather than after consecutive failures, so that a host failing
+// intermittently is detected. The failure rate is an exponentially
+// weighted moving average whose past requests weigh half as much every
+// halfLife, computed once 10 requests to the host have been recorded.
+// The circuit stays open for halfLife before being probed, and the
+// failure rate is cleared once it closes.
+func NewEWMACircuitBreaker(threshold float64, halfLife time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		cooldown:      halfLife,
+		rateThreshold: threshold,
+		halfLife:      halfLife,
+		hosts:         make(map[string]*hostBreaker),
+	}
+}
+
+// host returns the breaker of a host. Must be called while holding the
+// lock.
+func (b *CircuitBreaker) host(host string) *hostBreaker {
+	hb := b.hosts[host]
+	if hb == nil {
+		hb = &hostBreaker{}
+		b.hosts[host] = hb
+	}
+	return hb
+}
+
+// now returns the current time of the clock of the breaker. Must be
+// called while holding the lock.
+func (b *CircuitBreaker) now() time.Time {
+	if b.clock == nil {
+		return systemClock.Now()
+	}
+	return b.clock.Now()
+}
+
+// State returns the state of the circuit of a host.
+func (b *CircuitBreaker) State(host string) State {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	hb := b.hosts[host]
+	if hb == nil {
+		return StateClosed
+	}
+	if hb.state == StateOpen && !hb.tripped && b.now().Sub(hb.openedAt) >= b.cooldown {
+		return StateHalfOpen
+	}
+	return hb.state
+}
+
+// Trip opens the circuit of a host until it is reset, regardless of the
+// outcome of the requests.
+func (b *CircuitBreaker) Trip(host string) {
+	b.mtx.Lock()
+	{
+		hb := b.host(host)
+		hb.state = StateOpen
+		hb.openedAt = b.now()
+		hb.tripped = true
+	}
+	b.mtx.Unlock()
+}
+
+// Reset closes the circuit of a host and clears its failures.
+func (b *CircuitBreaker) Reset(host string) {
+	b.mtx.Lock()
+	{
+		delete(b.hosts, host)
+	}
+	b.mtx.Unlock()
+}
+
+// allow reports whether a request to the host can be sent, and whether
+// it probes a half-open circuit.
+func (b *CircuitBreaker) allow(host string) (allowed, probe bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	hb := b.hosts[host]
+	if hb == nil || hb.state == StateClosed {
+		return true, false
+	}
+	if hb.tripped {
+		return false, false
+	}
+
+	if hb.state == StateOpen {
+		if b.now().Sub(hb.openedAt) < b.cooldown {
+			return false, false
+		}
+		hb.state = StateHalfOpen
+	}
+
+	// Only one request probes a half-open circuit at a time.
+	if hb.probing {
+		return false, false
+	}
+	hb.probing = true
+	return true, true
+}
+
+// record records the outcome of a request to the host, which probed its
+// half-open circuit if probe is set.
+func (b *CircuitBreaker) record(host string, failed, probe bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	hb := b.host(host)
+	if hb.tripped {
+		return
+	}
+	if probe {
+		hb.probing = false
+	} else if hb.state != StateClosed {
+		// A request sent before the circuit opened, whose outcome
+		// tells nothing about the recovery of the host.
+		return
+	}
+
+	if b.halfLife > 0 {
+		b.recordRate(hb, failed)
+		return
+	}
+
+	if !failed {
+		hb.state = StateClosed
+		hb.failures = 0
+		return
+	}
+
+	hb.failures++
+	if hb.state == StateHalfOpen || hb.failures >= b.threshold {
+		hb.state = StateOpen
+		hb.openedAt = b.now()
+	}
+}
+
+// recordRate records the outcome of a request in the failure rate of a
+// host. Must be called while holding the lock.
+func (b *CircuitBreaker) recordRate(hb *hostBreaker, failed bool) {
+	now := b.now()
+	if hb.state == StateHalfOpen {
+		if failed {
+			hb.state = StateOpen
+			hb.openedAt = now
+		} else {
+			*hb = hostBreaker{}
+		}
+		return
+	}
+
+	decay := 1.0
+	if !hb.updatedAt.IsZero() {
+		decay = math.Exp2(-float64(now.Sub(hb.updatedAt)) / float64(b.halfLife))
+	}
+	hb.failureWeight *= decay
+	hb.totalWeight = hb.totalWeight*decay + 1
+	if failed {
+		hb.failureWeight++
+	}
+	hb.updatedAt = now
+	hb.samples++
+
+	if hb.samples >= ewmaMinSamples && hb.failureWeight/hb.totalWeight >= b.rateThreshold {
+		hb.state = StateOpen
+		hb.openedAt = now
+	}
+}
+
+// breakerTransport fails fast the requests to the hosts whose circuit is
+// open and records the outcome of the others.
+type breakerTransport struct {
+	next    http.RoundTripper
+	breaker *CircuitBreaker
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	allowed, probe := t.breaker.allow(host)
+	if !allowed {
+		closeRequestBody(req)
+		return nil, ErrCircuitOpen
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	t.breaker.record(host, err != nil || resp.StatusCode >= http.StatusInternalServerError, probe)
+	return resp, err
+}
+
+// WithCircuitBreaker installs a CircuitBreaker in the pool, opening the
+// circuit of a host after threshold consecutive failures, for the
+// cooldown duration. The state of the breaker can be inspected and
+// controlled through the BreakerState, TripBreaker and ResetBreaker
+// methods of the pool.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return withBreaker(NewCircuitBreaker(threshold, cooldown))
+}
+
+// WithEWMABreaker installs a CircuitBreaker in the pool opening the
+// circuit of a host once its recent failure rate reaches threshold, see
+// NewEWMACircuitBreaker. The state of the breaker can be inspected and
+// controlled as with WithCircuitBreaker.
+func WithEWMABreaker(threshold float64, halfLife time.Duration) Option {
+	return withBreaker(NewEWMACircuitBreaker(threshold, halfLife))
+}
+
+// withBreaker installs the circuit breaker in the pool.
+func withBreaker(breaker *CircuitBreaker) Option {
+	return func(c *ClientPool) {
+		c.breaker = breaker
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			breaker.mtx.Lock()
+			{
+				breaker.clock = c.timeSource()
+			}
+			breaker.mtx.Unlock()
+
+			return &breakerTransport{
+				next:    next,
+				breaker: breaker,
+			}
+		})
+	}
+}
+
+// BreakerState returns the state of the circuit breaker of a host. It
+// returns StateClosed if no circuit breaker is installed in the pool.
+func (c *ClientPool) BreakerState(host string) State {
+	if c.breaker == nil {
+		return StateClosed
+	}
+	return c.breaker.State(host)
+}
+
+// TripBreaker opens the circuit breaker of a host until it is reset. It
+// does nothing if no circuit breaker is installed in the pool.
+func (c *ClientPool) TripBreaker(host string) {
+	if c.breaker != nil {
+		c.breaker.Trip(host)
+	}
+}
+
+// ResetBreaker closes the circuit breaker of a host. It does nothing if
+// no circuit breaker is installed in the pool.
+func (c *ClientPool) ResetBreaker(host string) {
+	if c.breaker != nil {
+		c.breaker.Reset(host)
+	}
+}
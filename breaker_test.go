@@ -0,0 +1,244 @@
+package http_test
+
+import (
+	"errors"
+	nethttp "net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool(http.WithCircuitBreaker(3, 50*time.Millisecond))
+	cp.SetTransport(failingTransport(3, nethttp.StatusInternalServerError, &calls))
+	client := cp.GetClient(time.Second)
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get("http://example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if state := cp.BreakerState("example.com"); state != http.StateOpen {
+		t.Fatalf("state = %v, want open", state)
+	}
+	if _, err := client.Get("http://example.com/"); !errors.Is(err, http.ErrCircuitOpen) {
+		t.Fatalf("error = %v, want ErrCircuitOpen", err)
+	}
+
+	// A successful probe closes the circuit once the cooldown elapsed.
+	time.Sleep(60 * time.Millisecond)
+	if state := cp.BreakerState("example.com"); state != http.StateHalfOpen {
+		t.Fatalf("state = %v, want half-open", state)
+	}
+	resp, err := client.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if state := cp.BreakerState("example.com"); state != http.StateClosed {
+		t.Fatalf("state = %v, want closed", state)
+	}
+}
+
+func TestCircuitBreakerManualControl(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool(http.WithCircuitBreaker(3, time.Millisecond))
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+	client := cp.GetClient(time.Second)
+
+	cp.TripBreaker("example.com")
+	time.Sleep(5 * time.Millisecond)
+
+	// A tripped breaker stays open beyond the cooldown.
+	if _, err := client.Get("http://example.com/"); !errors.Is(err, http.ErrCircuitOpen) {
+		t.Fatalf("error = %v, want ErrCircuitOpen", err)
+	}
+	if calls != 0 {
+		t.Fatalf("transport called %d times, want 0", calls)
+	}
+
+	// Other hosts are not affected.
+	resp, err := client.Get("http://other.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	cp.ResetBreaker("example.com")
+	if state := cp.BreakerState("example.com"); state != http.StateClosed {
+		t.Fatalf("state = %v, want closed", state)
+	}
+	resp, err = client.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if calls != 2 {
+		t.Fatalf("transport called %d times, want 2", calls)
+	}
+}
+
+func TestBreakerWithoutOption(t *testing.T) {
+	cp := http.NewClientPool()
+	cp.TripBreaker("example.com")
+
+	if state := cp.BreakerState("example.com"); state != http.StateClosed {
+		t.Fatalf("state = %v, want closed", state)
+	}
+}
+
+// alternatingTransport fails every other request with a 500 status code.
+func alternatingTransport() nethttp.RoundTripper {
+	var calls int32
+	return roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		if atomic.AddInt32(&calls, 1)%2 == 0 {
+			return statusResponse(req, nethttp.StatusInternalServerError), nil
+		}
+		return statusResponse(req, nethttp.StatusOK), nil
+	})
+}
+
+// sendAll sends n requests to example.com, returning the number of them
+// refused by an open circuit.
+func sendAll(t *testing.T, client *nethttp.Client, n int) int {
+	t.Helper()
+
+	refused := 0
+	for i := 0; i < n; i++ {
+		resp, err := client.Get("http://example.com/")
+		if errors.Is(err, http.ErrCircuitOpen) {
+			refused++
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	return refused
+}
+
+func TestEWMABreakerOpensOnFailureRate(t *testing.T) {
+	// The consecutive failures never reach the threshold.
+	cp := http.NewClientPool(http.WithCircuitBreaker(2, time.Minute))
+	cp.SetTransport(alternatingTransport())
+	if refused := sendAll(t, cp.GetClient(time.Second), 20); refused != 0 {
+		t.Errorf("consecutive breaker refused %d requests, want 0", refused)
+	}
+
+	cp = http.NewClientPool(http.WithEWMABreaker(0.4, time.Minute))
+	cp.SetTransport(alternatingTransport())
+	client := cp.GetClient(time.Second)
+
+	// The failure rate is only considered after 10 requests.
+	if refused := sendAll(t, client, 10); refused != 0 {
+		t.Errorf("refused %d requests before the circuit opened, want 0", refused)
+	}
+	if state := cp.BreakerState("example.com"); state != http.StateOpen {
+		t.Fatalf("state = %v, want open", state)
+	}
+	if refused := sendAll(t, client, 5); refused != 5 {
+		t.Errorf("refused %d requests once open, want 5", refused)
+	}
+}
+
+func TestEWMABreakerBelowThreshold(t *testing.T) {
+	cp := http.NewClientPool(http.WithEWMABreaker(0.6, time.Minute))
+	cp.SetTransport(alternatingTransport())
+
+	if refused := sendAll(t, cp.GetClient(time.Second), 50); refused != 0 {
+		t.Errorf("refused %d requests, want 0", refused)
+	}
+	if state := cp.BreakerState("example.com"); state != http.StateClosed {
+		t.Errorf("state = %v, want closed", state)
+	}
+}
+
+func TestEWMABreakerDecay(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool(http.WithEWMABreaker(0.5, 20*time.Millisecond))
+	cp.SetTransport(failingTransport(5, nethttp.StatusInternalServerError, &calls))
+	client := cp.GetClient(time.Second)
+
+	// The early failures have decayed by the time the successes are
+	// recorded.
+	sendAll(t, client, 5)
+	time.Sleep(100 * time.Millisecond)
+	if refused := sendAll(t, client, 10); refused != 0 {
+		t.Errorf("refused %d requests, want 0", refused)
+	}
+}
+
+func TestCircuitBreakerInFlightRequests(t *testing.T) {
+	clock := http.NewFakeClock(time.Now())
+	release := map[string]chan struct{}{
+		"/first":  make(chan struct{}),
+		"/second": make(chan struct{}),
+		"/probe":  make(chan struct{}),
+	}
+	cp := http.NewClientPool(http.WithClock(clock), http.WithCircuitBreaker(1, time.Minute))
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		if req.URL.Path == "/fail" {
+			return statusResponse(req, nethttp.StatusInternalServerError), nil
+		}
+		if ch := release[req.URL.Path]; ch != nil {
+			<-ch
+		}
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+	client := cp.GetClient(5 * time.Second)
+
+	get := func(path string) <-chan error {
+		done := make(chan error, 1)
+		go func() {
+			resp, err := client.Get("http://example.com" + path)
+			if err == nil {
+				resp.Body.Close()
+			}
+			done <- err
+		}()
+		time.Sleep(20 * time.Millisecond)
+		return done
+	}
+
+	// The requests sent before the circuit opened do not close it.
+	first := get("/first")
+	second := get("/second")
+	if err := <-get("/fail"); err != nil {
+		t.Fatal(err)
+	}
+	close(release["/first"])
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+	if state := cp.BreakerState("example.com"); state != http.StateOpen {
+		t.Fatalf("state = %v, want open", state)
+	}
+
+	// Nor do they let another request through while the circuit is
+	// probed.
+	clock.Advance(time.Minute)
+	probe := get("/probe")
+	close(release["/second"])
+	if err := <-second; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-get("/"); !errors.Is(err, http.ErrCircuitOpen) {
+		t.Fatalf("error = %v while probing, want %v", err, http.ErrCircuitOpen)
+	}
+	close(release["/probe"])
+	if err := <-probe; err != nil {
+		t.Fatal(err)
+	}
+	if state := cp.BreakerState("example.com"); state != http.StateClosed {
+		t.Fatalf("state = %v after the probe, want closed", state)
+	}
+}
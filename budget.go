@@ -0,0 +1,90 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// ErrMemoryBudgetExceeded is returned when admitting a response would
+// exceed the memory budget of the pool.
+var ErrMemoryBudgetExceeded = errors.New("http: memory budget exceeded")
+
+// unknownLengthEstimate is the number of bytes accounted for a response
+// whose length is unknown.
+const unknownLengthEstimate = 256 << 10
+
+// memoryBudget accounts the bytes of the response bodies in flight.
+type memoryBudget struct {
+	max int64
+
+	mtx  sync.Mutex
+	used int64
+}
+
+// reserve reserves n bytes and reports whether they fit in the budget.
+func (b *memoryBudget) reserve(n int64) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.used+n > b.max {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+// release returns n bytes to the budget.
+func (b *memoryBudget) release(n int64) {
+	b.mtx.Lock()
+	b.used -= n
+	b.mtx.Unlock()
+}
+
+// WithMemoryBudget caps the total size of the response bodies that can
+// be in flight through the clients of the pool. Every response accounts
+// for its Content-Length, or for 256KiB if it is unknown, until its body
+// is closed. Responses that would exceed the budget are discarded and
+// their requests fail with ErrMemoryBudgetExceeded.
+func WithMemoryBudget(maxBytes int64) Option {
+	budget := &memoryBudget{max: maxBytes}
+
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			return &budgetTransport{
+				next:   next,
+				budget: budget,
+			}
+		})
+	}
+}
+
+// budgetTransport admits the responses of the next transport within a
+// memory budget.
+type budgetTransport struct {
+	next   http.RoundTripper
+	budget *memoryBudget
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	size := resp.ContentLength
+	if size < 0 {
+		size = unknownLengthEstimate
+	}
+	if !t.budget.reserve(size) {
+		resp.Body.Close()
+		return nil, ErrMemoryBudgetExceeded
+	}
+
+	resp.Body = &releaseBody{
+		ReadCloser: resp.Body,
+		release:    func() { t.budget.release(size) },
+	}
+	return resp, nil
+}
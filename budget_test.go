@@ -0,0 +1,85 @@
+package http_test
+
+import (
+	"errors"
+	"io"
+	nethttp "net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// sizedTransport answers every request with a body of the specified
+// size, advertised in the Content-Length unless unknown is set.
+func sizedTransport(size int, unknown bool) nethttp.RoundTripper {
+	return roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		resp := statusResponse(req, nethttp.StatusOK)
+		resp.Body = io.NopCloser(strings.NewReader(strings.Repeat("x", size)))
+		resp.ContentLength = int64(size)
+		if unknown {
+			resp.ContentLength = -1
+		}
+		return resp, nil
+	})
+}
+
+func TestMemoryBudget(t *testing.T) {
+	cp := http.NewClientPool(http.WithMemoryBudget(100))
+	cp.SetTransport(sizedTransport(40, false))
+	client := cp.GetClient(time.Second)
+
+	var (
+		wg       sync.WaitGroup
+		mtx      sync.Mutex
+		bodies   []io.Closer
+		rejected int
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp, err := client.Get("http://example.com/")
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			switch {
+			case errors.Is(err, http.ErrMemoryBudgetExceeded):
+				rejected++
+			case err != nil:
+				t.Error(err)
+			default:
+				bodies = append(bodies, resp.Body)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(bodies) != 2 || rejected != 8 {
+		t.Fatalf("admitted %d and rejected %d responses, want 2 and 8", len(bodies), rejected)
+	}
+
+	// Closing the bodies releases the budget.
+	for _, body := range bodies {
+		body.Close()
+		body.Close()
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Get("http://example.com/"); err != nil {
+			t.Fatalf("request %d after release: %v", i, err)
+		}
+	}
+}
+
+func TestMemoryBudgetUnknownLength(t *testing.T) {
+	cp := http.NewClientPool(http.WithMemoryBudget(100 << 10))
+	cp.SetTransport(sizedTransport(10, true))
+
+	// A small response of unknown length is accounted conservatively.
+	if _, err := cp.GetClient(time.Second).Get("http://example.com/"); !errors.Is(err, http.ErrMemoryBudgetExceeded) {
+		t.Fatalf("error = %v, want ErrMemoryBudgetExceeded", err)
+	}
+}
@@ -0,0 +1,192 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Request builds and sends requests through the clients of a pool. Its
+// methods set a part of the request and return the builder so that they
+// can be chained:
+//
+//	resp, err := pool.NewRequest().
+//		Method("POST").
+//		URL("https://api.example.com").
+//		Path("/items").
+//		Query("dry_run", "true").
+//		JSONBody(item).
+//		Do(ctx)
+//
+// A builder can be sent several times, and copied with Clone to derive
+// requests from a common template. It must not be modified concurrently.
+type Request struct {
+	pool    *ClientPool
+	method  string
+	base    string
+	path    string
+	query   url.Values
+	header  http.Header
+	body    []byte
+	timeout time.Duration
+
+	// err is the first error that occurred while building the request,
+	// returned by Do.
+	err error
+}
+
+// NewRequest returns a builder of GET requests sent through the clients
+// of the pool.
+func (c *ClientPool) NewRequest() *Request {
+	return &Request{
+		pool:   c,
+		method: http.MethodGet,
+		query:  make(url.Values),
+		header: make(http.Header),
+	}
+}
+
+// Clone returns a copy of the builder that can be modified independently.
+func (r *Request) Clone() *Request {
+	clone := *r
+	clone.query = make(url.Values, len(r.query))
+	for key, values := range r.query {
+		clone.query[key] = append([]string(nil), values...)
+	}
+	clone.header = r.header.Clone()
+	return &clone
+}
+
+// Method sets the method of the request.
+func (r *Request) Method(method string) *Request {
+	r.method = method
+	return r
+}
+
+// URL sets the URL of the request, to which the path set with Path is
+// appended.
+func (r *Request) URL(rawURL string) *Request {
+	r.base = rawURL
+	return r
+}
+
+// Path sets the path appended to the URL of the request.
+func (r *Request) Path(path string) *Request {
+	r.path = path
+	return r
+}
+
+// Query adds a value to the query parameter, in addition to the query
+// of the URL, if any.
+func (r *Request) Query(key, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+// Header adds a value to the header.
+func (r *Request) Header(key, value string) *Request {
+	r.header.Add(key, value)
+	return r
+}
+
+// Body sets the body of the request, read once and kept so that the
+// request can be sent again.
+func (r *Request) Body(body io.Reader) *Request {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		r.setErr(fmt.Errorf("http: reading the request body: %w", err))
+	}
+	r.body = data
+	return r
+}
+
+// JSONBody sets the body of the request to the JSON encoding of v, and
+// its Content-Type header to application/json unless already set.
+// Encoding errors are returned by Do.
+func (r *Request) JSONBody(v interface{}) *Request {
+	data, err := json.Marshal(v)
+	if err != nil {
+		r.setErr(fmt.Errorf("http: encoding the request body: %w", err))
+	}
+	r.body = data
+	if r.header.Get("Content-Type") == "" {
+		r.header.Set("Content-Type", "application/json")
+	}
+	return r
+}
+
+// Timeout sets the timeout of the client sending the request. If not
+// set, the client is selected as by the Do method of the pool.
+func (r *Request) Timeout(d time.Duration) *Request {
+	r.timeout = d
+	return r
+}
+
+// setErr records the first error that occurred while building.
+func (r *Request) setErr(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// Build returns the request built with the specified context, or the
+// first error that occurred while building it.
+func (r *Request) Build(ctx context.Context) (*http.Request, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	u, err := url.Parse(r.base)
+	if err != nil {
+		return nil, err
+	}
+	if r.path != "" {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(r.path, "/")
+		u.RawPath = ""
+	}
+	if len(r.query) > 0 {
+		query := u.Query()
+		for key, values := range r.query {
+			query[key] = append(query[key], values...)
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range r.header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+	}
+	return req, nil
+}
+
+// Do builds the request with the specified context and sends it. Errors
+// that occurred while building the request are returned as is, and the
+// failures to send it as a *RequestError.
+func (r *Request) Do(ctx context.Context) (*http.Response, error) {
+	req, err := r.Build(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := r.timeout
+	if timeout == 0 {
+		timeout = r.pool.requestTimeout(req)
+	}
+	return r.pool.sendTimeout(timeout, req, r.pool.do)
+}
@@ -0,0 +1,132 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// requestEcho is the description of a request sent back by the echo
+// server.
+type requestEcho struct {
+	Method      string
+	Path        string
+	Query       string
+	ContentType string
+	Trace       string
+	Body        string
+}
+
+func newRequestEchoServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.NewEncoder(w).Encode(requestEcho{
+			Method:      r.Method,
+			Path:        r.URL.Path,
+			Query:       r.URL.RawQuery,
+			ContentType: r.Header.Get("Content-Type"),
+			Trace:       r.Header.Get("X-Trace"),
+			Body:        string(body),
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// doEcho sends the request and decodes the echoed request.
+func doEcho(t *testing.T, r *http.Request) requestEcho {
+	t.Helper()
+
+	resp, err := r.Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var echo requestEcho
+	if err := json.NewDecoder(resp.Body).Decode(&echo); err != nil {
+		t.Fatal(err)
+	}
+	return echo
+}
+
+func TestRequestBuilder(t *testing.T) {
+	server := newRequestEchoServer(t)
+	cp := http.NewClientPool()
+
+	echo := doEcho(t, cp.NewRequest().
+		Method(nethttp.MethodPost).
+		URL(server.URL+"/api/?version=2").
+		Path("/items").
+		Query("name", "a b&c").
+		Query("tag", "x").
+		Query("tag", "y").
+		JSONBody(map[string]int{"count": 3}).
+		Header("X-Trace", "abc").
+		Timeout(time.Second))
+
+	want := requestEcho{
+		Method:      nethttp.MethodPost,
+		Path:        "/api/items",
+		Query:       "name=a+b%26c&tag=x&tag=y&version=2",
+		ContentType: "application/json",
+		Trace:       "abc",
+		Body:        `{"count":3}`,
+	}
+	if echo != want {
+		t.Errorf("got request %+v, want %+v", echo, want)
+	}
+}
+
+func TestRequestBuilderClone(t *testing.T) {
+	server := newRequestEchoServer(t)
+	cp := http.NewClientPool()
+
+	base := cp.NewRequest().URL(server.URL).Query("page", "1").Header("X-Trace", "base")
+	derived := base.Clone().Method(nethttp.MethodPut).Path("/other").Query("page", "2").Body(strings.NewReader("data"))
+
+	// The template is sent twice, unchanged by the derived request.
+	for i := 0; i < 2; i++ {
+		if echo := doEcho(t, base); echo.Method != nethttp.MethodGet || echo.Path != "/" || echo.Query != "page=1" || echo.Body != "" {
+			t.Errorf("got template request %+v", echo)
+		}
+	}
+	if echo := doEcho(t, derived); echo.Method != nethttp.MethodPut || echo.Path != "/other" || echo.Query != "page=1&page=2" || echo.Body != "data" {
+		t.Errorf("got derived request %+v", echo)
+	}
+}
+
+func TestRequestBuilderJSONError(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool()
+	cp.SetTransport(failingTransport(0, 0, &calls))
+
+	_, err := cp.NewRequest().URL("http://example.com/").JSONBody(func() {}).Do(context.Background())
+	var jsonErr *json.UnsupportedTypeError
+	if !errors.As(err, &jsonErr) {
+		t.Fatalf("error = %v, want a JSON encoding error", err)
+	}
+	if calls != 0 {
+		t.Errorf("got %d requests sent, want 0", calls)
+	}
+}
+
+func TestRequestBuilderRequestError(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool()
+	cp.SetTransport(failingTransport(1, 0, &calls))
+
+	_, err := cp.NewRequest().URL("http://example.com/").Do(context.Background())
+	var reqErr *http.RequestError
+	if !errors.As(err, &reqErr) || !errors.Is(err, errFailing) {
+		t.Fatalf("error = %v, want a *RequestError wrapping %v", err, errFailing)
+	}
+}
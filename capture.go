@@ -0,0 +1,161 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// maxCaptureBytes is the number of bytes of every body kept in a
+// Capture.
+const maxCaptureBytes = 64 << 10
+
+// Capture holds the bodies of a request and its response, captured by
+// WithBodyCapture.
+type Capture struct {
+	// Request is the request, whose body has been consumed.
+	Request     *http.Request
+	RequestBody []byte
+
+	StatusCode      int
+	ResponseHeaders http.Header
+	ResponseBody    []byte
+
+	// Truncated is set if a body was longer than the 64KiB kept.
+	Truncated bool
+}
+
+// WithBodyCapture makes the clients of the pool capture the bodies of a
+// sample of the requests and of their responses, for debugging. The
+// fraction of the requests sampled never exceeds sampleRate, between 0
+// and 1. The bodies are copied as they are sent and read, without
+// affecting them, and the first 64KiB of each are kept. The capture is
+// delivered to the sink once the response body is closed, with the part
+// of the response body that was read. Failed requests are not captured.
+func WithBodyCapture(sampleRate float64, sink func(Capture)) Option {
+	sampler := &sampler{rate: sampleRate}
+
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			return &captureTransport{
+				next:    next,
+				sampler: sampler,
+				sink:    sink,
+			}
+		})
+	}
+}
+
+// sampler samples a fraction of the requests, evenly spread.
+type sampler struct {
+	rate float64
+	seen int64
+}
+
+// sample reports whether the next request is sampled: a request is
+// sampled whenever the number of requests times the rate reaches a new
+// integer.
+func (s *sampler) sample() bool {
+	n := atomic.AddInt64(&s.seen, 1)
+	return int64(float64(n)*s.rate) > int64(float64(n-1)*s.rate)
+}
+
+// captureTransport captures the bodies of a sample of the requests sent
+// through the next transport.
+type captureTransport struct {
+	next    http.RoundTripper
+	sampler *sampler
+	sink    func(Capture)
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.sampler.sample() {
+		return t.next.RoundTrip(req)
+	}
+
+	reqBuf := &captureBuffer{}
+	if req.Body != nil && req.Body != http.NoBody {
+		body := req.Body
+		req = req.Clone(req.Context())
+		req.Body = &captureBody{ReadCloser: body, buf: reqBuf}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBuf := &captureBuffer{}
+	resp.Body = &captureBody{
+		ReadCloser: resp.Body,
+		buf:        respBuf,
+		done: func() {
+			reqBody, reqTruncated := reqBuf.bytes()
+			respBody, respTruncated := respBuf.bytes()
+			t.sink(Capture{
+				Request:         req,
+				RequestBody:     reqBody,
+				StatusCode:      resp.StatusCode,
+				ResponseHeaders: resp.Header.Clone(),
+				ResponseBody:    respBody,
+				Truncated:       reqTruncated || respTruncated,
+			})
+		},
+	}
+	return resp, nil
+}
+
+// captureBuffer keeps the first bytes written to it. The request body
+// may be written while the response body is read, hence the lock.
+type captureBuffer struct {
+	mtx       sync.Mutex
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (b *captureBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if room := maxCaptureBytes - b.buf.Len(); len(p) > room {
+		b.buf.Write(p[:room])
+		b.truncated = true
+	} else {
+		b.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// bytes returns a copy of the bytes kept.
+func (b *captureBuffer) bytes() ([]byte, bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return append([]byte(nil), b.buf.Bytes()...), b.truncated
+}
+
+// captureBody copies the bytes read from a body to a buffer, calling
+// done once closed.
+type captureBody struct {
+	io.ReadCloser
+	buf  *captureBuffer
+	done func()
+	once sync.Once
+}
+
+func (b *captureBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	return n, err
+}
+
+func (b *captureBody) Close() error {
+	err := b.ReadCloser.Close()
+	if b.done != nil {
+		b.once.Do(b.done)
+	}
+	return err
+}
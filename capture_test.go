@@ -0,0 +1,115 @@
+package http_test
+
+import (
+	"io"
+	nethttp "net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// echoTransport answers every request with its body.
+var echoTransport = roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body.Close()
+
+	resp := statusResponse(req, nethttp.StatusOK)
+	resp.Header.Set("X-Echo", "true")
+	resp.Body = io.NopCloser(strings.NewReader("echo: " + string(body)))
+	return resp, nil
+})
+
+func TestBodyCapture(t *testing.T) {
+	var captures []http.Capture
+	cp := http.NewClientPool(http.WithBodyCapture(1, func(c http.Capture) {
+		captures = append(captures, c)
+	}))
+	cp.SetTransport(echoTransport)
+
+	resp, err := cp.GetClient(time.Second).Post("http://example.com/", "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "echo: hello" {
+		t.Fatalf("read body %q, want the body intact", body)
+	}
+
+	if len(captures) != 1 {
+		t.Fatalf("got %d captures, want 1", len(captures))
+	}
+	c := captures[0]
+	if string(c.RequestBody) != "hello" || string(c.ResponseBody) != "echo: hello" {
+		t.Errorf("captured %q and %q", c.RequestBody, c.ResponseBody)
+	}
+	if c.StatusCode != nethttp.StatusOK || c.ResponseHeaders.Get("X-Echo") != "true" || c.Truncated {
+		t.Errorf("captured status %d, headers %v and truncated %t", c.StatusCode, c.ResponseHeaders, c.Truncated)
+	}
+	if c.Request.URL.String() != "http://example.com/" {
+		t.Errorf("captured request to %s", c.Request.URL)
+	}
+}
+
+func TestBodyCaptureTruncated(t *testing.T) {
+	var capture http.Capture
+	cp := http.NewClientPool(http.WithBodyCapture(1, func(c http.Capture) { capture = c }))
+	cp.SetTransport(echoTransport)
+
+	large := strings.Repeat("x", 100<<10)
+	resp, err := cp.GetClient(time.Second).Post("http://example.com/", "text/plain", strings.NewReader(large))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if string(body) != "echo: "+large {
+		t.Fatal("expected the body to be intact")
+	}
+	if !capture.Truncated || len(capture.RequestBody) != 64<<10 || len(capture.ResponseBody) != 64<<10 {
+		t.Fatalf("captured %d and %d bytes, truncated %t, want 64KiB each", len(capture.RequestBody), len(capture.ResponseBody), capture.Truncated)
+	}
+}
+
+func TestBodyCaptureSampleRate(t *testing.T) {
+	var mtx sync.Mutex
+	var captured int
+	cp := http.NewClientPool(http.WithBodyCapture(0.1, func(http.Capture) {
+		mtx.Lock()
+		captured++
+		mtx.Unlock()
+	}))
+	cp.SetTransport(okTransport)
+	client := cp.GetClient(time.Second)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				resp, err := client.Get("http://example.com/")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if captured != 100 {
+		t.Fatalf("captured %d of 1000 requests, want 100", captured)
+	}
+}
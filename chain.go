@@ -0,0 +1,46 @@
+package http
+
+import "net/http"
+
+// TransportChain is an ordered stack of middleware wrapping a transport,
+// the first middleware being the outermost wrapper. A chain is immutable
+// once created, so it can be shared between pools.
+type TransportChain struct {
+	middleware []func(http.RoundTripper) http.RoundTripper
+}
+
+// NewTransportChain returns a chain of the specified middleware, the
+// first being the outermost wrapper.
+func NewTransportChain(middleware ...func(http.RoundTripper) http.RoundTripper) *TransportChain {
+	return &TransportChain{
+		middleware: append([]func(http.RoundTripper) http.RoundTripper(nil), middleware...),
+	}
+}
+
+// Then returns the base transport wrapped by the middleware of the chain.
+func (ch *TransportChain) Then(base http.RoundTripper) http.RoundTripper {
+	if ch == nil {
+		return base
+	}
+	for i := len(ch.middleware) - 1; i >= 0; i-- {
+		base = ch.middleware[i](base)
+	}
+	return base
+}
+
+// SetMiddlewareStack replaces the stack of middleware wrapping the
+// transport of the clients of the pool by the chain, at once. The stack
+// wraps the transport below the middleware installed by the options of
+// the pool, which are kept. A nil chain removes the stack. Clients
+// requested from the pool before the call keep using the previous stack.
+func (c *ClientPool) SetMiddlewareStack(chain *TransportChain) {
+	c.mtx.Lock()
+	{
+		c.stack = chain
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+	c.mtx.Unlock()
+}
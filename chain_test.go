@@ -0,0 +1,60 @@
+package http_test
+
+import (
+	nethttp "net/http"
+	"reflect"
+	"testing"
+
+	"github.com/Updater/http"
+)
+
+// tagging returns a middleware appending the tag to the X-Stack header
+// of the requests.
+func tagging(tag string) func(nethttp.RoundTripper) nethttp.RoundTripper {
+	return func(next nethttp.RoundTripper) nethttp.RoundTripper {
+		return roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+			req = req.Clone(req.Context())
+			req.Header.Add("X-Stack", tag)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+func TestSetMiddlewareStack(t *testing.T) {
+	var stack []string
+	cp := http.NewClientPool()
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		stack = req.Header.Values("X-Stack")
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+
+	get := func() []string {
+		t.Helper()
+		stack = nil
+		resp, err := cp.GetClient(0).Get("http://example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return stack
+	}
+
+	cp.SetMiddlewareStack(http.NewTransportChain(tagging("old")))
+	old := cp.GetClient(0)
+	if got, want := get(), []string{"old"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got stack %q, want %q", got, want)
+	}
+
+	cp.SetMiddlewareStack(http.NewTransportChain(tagging("outer"), tagging("inner")))
+	if cp.GetClient(0) == old {
+		t.Fatal("cached client was not replaced")
+	}
+	if got, want := get(), []string{"outer", "inner"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got stack %q, want %q", got, want)
+	}
+
+	cp.SetMiddlewareStack(nil)
+	if got := get(); len(got) != 0 {
+		t.Fatalf("got stack %q after removing it", got)
+	}
+}
@@ -0,0 +1,134 @@
+package http
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrChaosConnectionDropped is returned by a ChaosTransport when it
+// simulates a dropped connection.
+var ErrChaosConnectionDropped = errors.New("http: chaos: connection dropped")
+
+// ChaosConfig specifies the failures injected by a ChaosTransport. Each
+// rate is a probability in the range [0, 1] evaluated independently for
+// every request.
+type ChaosConfig struct {
+	// Seed seeds the random source so that a sequence of injected
+	// failures can be reproduced.
+	Seed int64
+
+	// LatencyRate is the probability of delaying a request by Latency
+	// before it is sent to the inner transport.
+	LatencyRate float64
+	Latency     time.Duration
+
+	// ErrorRate is the probability of answering a request with a
+	// synthetic 500 response without sending it.
+	ErrorRate float64
+
+	// DropRate is the probability of failing a request with
+	// ErrChaosConnectionDropped without sending it.
+	DropRate float64
+}
+
+// chaosSource is a random source safe for concurrent use. It is shared
+// by all the transports built from the same configuration so that the
+// sequence of injected failures stays deterministic for a seed.
+type chaosSource struct {
+	mtx sync.Mutex
+	rnd *rand.Rand
+}
+
+func (s *chaosSource) hit(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+
+	s.mtx.Lock()
+	v := s.rnd.Float64()
+	s.mtx.Unlock()
+
+	return v < rate
+}
+
+// ChaosTransport is a RoundTripper that injects latency, synthetic server
+// errors and dropped connections into the requests sent through an inner
+// transport. It is designed for resilience testing and should not be
+// used in production.
+type ChaosTransport struct {
+	inner  http.RoundTripper
+	config ChaosConfig
+	source *chaosSource
+}
+
+// NewChaosTransport returns a ChaosTransport wrapping the specified
+// transport. If inner is nil, http.DefaultTransport is used.
+func NewChaosTransport(inner http.RoundTripper, config ChaosConfig) *ChaosTransport {
+	return newChaosTransport(inner, config, &chaosSource{
+		rnd: rand.New(rand.NewSource(config.Seed)),
+	})
+}
+
+func newChaosTransport(inner http.RoundTripper, config ChaosConfig, source *chaosSource) *ChaosTransport {
+	if inner == nil {
+		inner = http.DefaultTransport
+	}
+	return &ChaosTransport{
+		inner:  inner,
+		config: config,
+		source: source,
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *ChaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.source.hit(t.config.DropRate) {
+		closeRequestBody(req)
+		return nil, ErrChaosConnectionDropped
+	}
+
+	if t.source.hit(t.config.ErrorRate) {
+		closeRequestBody(req)
+		return &http.Response{
+			Status:     "500 Internal Server Error",
+			StatusCode: http.StatusInternalServerError,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     make(http.Header),
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	}
+
+	if t.source.hit(t.config.LatencyRate) {
+		timer := time.NewTimer(t.config.Latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			closeRequestBody(req)
+			return nil, req.Context().Err()
+		}
+	}
+
+	return t.inner.RoundTrip(req)
+}
+
+// WithChaos wraps the transport of every client in the pool with a
+// ChaosTransport using the specified configuration. All the clients in
+// the pool share the same random source.
+func WithChaos(config ChaosConfig) Option {
+	source := &chaosSource{
+		rnd: rand.New(rand.NewSource(config.Seed)),
+	}
+
+	return func(c *ClientPool) {
+		c.use(func(inner http.RoundTripper) http.RoundTripper {
+			return newChaosTransport(inner, config, source)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package http_test
+
+import (
+	"errors"
+	"math"
+	nethttp "net/http"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestChaosRates(t *testing.T) {
+	const requests = 10000
+
+	cp := http.NewClientPool(http.WithChaos(http.ChaosConfig{
+		Seed:      42,
+		ErrorRate: 0.2,
+		DropRate:  0.1,
+	}))
+	cp.SetTransport(okTransport)
+	client := cp.GetClient(time.Second)
+
+	var errs, drops int
+	for i := 0; i < requests; i++ {
+		resp, err := client.Get("http://example.com/")
+		switch {
+		case errors.Is(err, http.ErrChaosConnectionDropped):
+			drops++
+		case err != nil:
+			t.Fatalf("unexpected error: %v", err)
+		case resp.StatusCode == nethttp.StatusInternalServerError:
+			errs++
+		}
+	}
+
+	// Errors are only rolled for requests that were not dropped.
+	assertRate(t, "drop", drops, requests, 0.1)
+	assertRate(t, "error", errs, requests-drops, 0.2)
+}
+
+func TestChaosDeterministic(t *testing.T) {
+	run := func() []bool {
+		transport := http.NewChaosTransport(okTransport, http.ChaosConfig{
+			Seed:     7,
+			DropRate: 0.5,
+		})
+
+		var dropped []bool
+		for i := 0; i < 100; i++ {
+			req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/", nil)
+			_, err := transport.RoundTrip(req)
+			dropped = append(dropped, err != nil)
+		}
+		return dropped
+	}
+
+	first, second := run(), run()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("request %d: sequences differ for the same seed", i)
+		}
+	}
+}
+
+func TestChaosLatency(t *testing.T) {
+	transport := http.NewChaosTransport(okTransport, http.ChaosConfig{
+		LatencyRate: 1,
+		Latency:     20 * time.Millisecond,
+	})
+
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/", nil)
+	start := time.Now()
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("request took %v, want at least 20ms", elapsed)
+	}
+}
+
+func TestChaosDisabled(t *testing.T) {
+	transport := http.NewChaosTransport(okTransport, http.ChaosConfig{})
+
+	for i := 0; i < 1000; i++ {
+		req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/", nil)
+		resp, err := transport.RoundTrip(req)
+		if err != nil || resp.StatusCode != nethttp.StatusOK {
+			t.Fatalf("unexpected injection: %v %v", resp, err)
+		}
+	}
+}
+
+// assertRate fails the test if hits/total deviates from want by more
+// than two percentage points.
+func assertRate(t *testing.T, name string, hits, total int, want float64) {
+	t.Helper()
+
+	got := float64(hits) / float64(total)
+	if math.Abs(got-want) > 0.02 {
+		t.Errorf("%s rate = %.3f, want %.3f", name, got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package http
+
+import "time"
+
+// clock provides the current time and the timers of the pool, so that
+// tests can control the passing of time.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) timer
+}
+
+// timer is a timer created by a clock.
+type timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// systemClock is the clock of the time package, used by default.
+var systemClock clock = realClock{}
+
+// realClock is a clock relying on the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (realClock) NewTimer(d time.Duration) timer { return realTimer{time.NewTimer(d)} }
+
+// realTimer is a timer of the time package.
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time { return t.t.C }
+
+func (t realTimer) Stop() bool { return t.t.Stop() }
+
+// timeSource returns the clock of the pool. Must be called while holding
+// the lock.
+func (c *ClientPool) timeSource() clock {
+	if c.clock == nil {
+		return systemClock
+	}
+	return c.clock
+}
@@ -0,0 +1,187 @@
+package http_test
+
+import (
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestFakeClockBackoff(t *testing.T) {
+	clock := http.NewFakeClock(time.Now())
+	var calls int32
+	cp := http.NewClientPool(http.WithClock(clock), http.WithRetry(2, time.Hour))
+	cp.SetTransport(failingTransport(1, nethttp.StatusServiceUnavailable, &calls))
+
+	done := make(chan error, 1)
+	go func() {
+		resp, err := cp.GetClient(0).Get("http://example.com/")
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+
+	// Wait for the retry transport to wait for the backoff.
+	for clock.Timers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.Advance(59 * time.Minute)
+	select {
+	case err := <-done:
+		t.Fatalf("request completed before the backoff elapsed: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clock.Advance(time.Minute)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("%d attempts, want 2", n)
+	}
+}
+
+func TestFakeClockLRUReaper(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	defer server.Close()
+
+	clock := http.NewFakeClock(time.Now())
+	cp := http.NewClientPool(http.WithClock(clock), http.WithLRUConnReuse())
+	client := cp.GetClient(time.Second)
+
+	reused := func() bool {
+		var reused bool
+		trace := &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused },
+		}
+		req, _ := nethttp.NewRequest(nethttp.MethodGet, server.URL, nil)
+		resp, err := client.Do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return reused
+	}
+
+	reused()
+	clock.Advance(89 * time.Second)
+	if !reused() {
+		t.Error("expected the idle connection to be reused before its timeout")
+	}
+
+	// The idle connection is closed once its timeout elapsed.
+	clock.Advance(91 * time.Second)
+	if reused() {
+		t.Error("expected a new connection once the idle timeout elapsed")
+	}
+}
+
+func TestFakeClockBreakerCooldown(t *testing.T) {
+	clock := http.NewFakeClock(time.Now())
+	var calls int32
+	cp := http.NewClientPool(http.WithClock(clock), http.WithCircuitBreaker(1, time.Hour))
+	cp.SetTransport(failingTransport(1, nethttp.StatusInternalServerError, &calls))
+	client := cp.GetClient(time.Second)
+
+	resp, err := client.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	clock.Advance(59 * time.Minute)
+	if state := cp.BreakerState("example.com"); state != http.StateOpen {
+		t.Fatalf("state = %v before the cooldown elapsed, want open", state)
+	}
+
+	clock.Advance(time.Minute)
+	if state := cp.BreakerState("example.com"); state != http.StateHalfOpen {
+		t.Fatalf("state = %v after the cooldown, want half-open", state)
+	}
+	resp, err = client.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if state := cp.BreakerState("example.com"); state != http.StateClosed {
+		t.Fatalf("state = %v, want closed", state)
+	}
+}
+
+func TestFakeClockCompressionReset(t *testing.T) {
+	plain := newEncodingServer(false)
+	defer plain.Close()
+
+	clock := http.NewFakeClock(time.Now())
+	cp := http.NewClientPool(http.WithClock(clock), http.WithAdaptiveCompression())
+	cp.SetTransport(&nethttp.Transport{})
+	client := cp.GetClient(time.Second)
+
+	for i := 0; i < 4; i++ {
+		getDiscard(t, client, plain.URL)
+	}
+	if plain.last() != "identity" {
+		t.Fatalf("plain host got Accept-Encoding %q, want identity", plain.last())
+	}
+
+	clock.Advance(9 * time.Minute)
+	getDiscard(t, client, plain.URL)
+	if plain.last() != "identity" {
+		t.Fatalf("plain host got Accept-Encoding %q before the reset, want identity", plain.last())
+	}
+
+	clock.Advance(2 * time.Minute)
+	getDiscard(t, client, plain.URL)
+	if plain.last() != "gzip" {
+		t.Fatalf("plain host got Accept-Encoding %q after the reset, want gzip", plain.last())
+	}
+}
+
+func TestFakeClockKeepWarmRecency(t *testing.T) {
+	var heads int32
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.Method == nethttp.MethodHead {
+			atomic.AddInt32(&heads, 1)
+		}
+	}))
+	defer server.Close()
+
+	clock := http.NewFakeClock(time.Now())
+	cp := http.NewClientPool(http.WithClock(clock))
+	cp.SetTransport(&nethttp.Transport{})
+	cp.SetMinIdleConnsPerHost(1)
+	defer cp.Close()
+
+	resp, err := cp.GetClient(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// Wait for the keep-warm goroutine to wait for the next round.
+	waitTimer := func() {
+		for clock.Timers() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	waitTimer()
+	clock.Advance(30 * time.Second)
+	waitTimer()
+	if n := atomic.LoadInt32(&heads); n != 1 {
+		t.Fatalf("%d keep-warm requests, want 1", n)
+	}
+
+	// The host was not used within the last five minutes.
+	clock.Advance(5 * time.Minute)
+	waitTimer()
+	if n := atomic.LoadInt32(&heads); n != 1 {
+		t.Fatalf("%d keep-warm requests after the host went unused, want 1", n)
+	}
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Clone returns a new pool with the same settings and options as the
+// pool, and no clients yet. The clone shares with the pool the state of
+// its options, such as the circuit breaker, its observers and the TLS
+// session cache set with WithSharedSessionCache, but not its
+// connections: the clone creates its own default
+// transport, unless a transport was set with SetTransport or
+// SetGlobalDefaultTransport. Connections are not kept warm by the clone
+// until SetMinIdleConnsPerHost is called on it.
+func (c *ClientPool) Clone() *ClientPool {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	clone := &ClientPool{
+		transport:             c.transport,
+		tlsConfig:             c.tlsConfig.Clone(),
+		tlsProfile:            c.tlsProfile,
+		sessionCache:          c.sessionCache,
+		pins:                  c.pins,
+		certSelector:          c.certSelector,
+		defaultTimeout:        atomic.LoadInt64(&c.defaultTimeout),
+		proxy:                 c.proxy,
+		proxyAuth:             c.proxyAuth,
+		basicAuth:             c.basicAuth,
+		socks5:                c.socks5,
+		jumpHost:              c.jumpHost,
+		onNewConn:             c.onNewConn,
+		maxRequestHeaderBytes: c.maxRequestHeaderBytes,
+		queueCapacity:         c.queueCapacity,
+		closeOnHeader:         c.closeOnHeader,
+		closingConns:          c.closingConns,
+		preserveBody:          c.preserveBody,
+		fastFail:              c.fastFail,
+		ipv6Fallback:          c.ipv6Fallback,
+		deadlinePropagation:   c.deadlinePropagation,
+		lruReuse:              c.lruReuse,
+		recycleThreshold:      c.recycleThreshold,
+		contextTimeoutsOnly:   c.contextTimeoutsOnly,
+		clock:                 c.clock,
+		maxRedirectsPerHost:   c.maxRedirectsPerHost,
+		errorDecoder:          c.errorDecoder,
+		balancer:              c.balancer,
+		middleware:            append([]func(http.RoundTripper) http.RoundTripper(nil), c.middleware...),
+		stack:                 c.stack,
+		idempotency:           c.idempotency,
+		requestInterceptor:    c.requestInterceptor,
+		sentCapture:           c.sentCapture,
+		responseInterceptor:   c.responseInterceptor,
+		panicRecovery:         c.panicRecovery,
+		onPanic:               c.onPanic,
+		observers:             append([]Observer(nil), c.observers...),
+		routeClassifier:       c.routeClassifier,
+		streamReadTimeout:     c.streamReadTimeout,
+		breaker:               c.breaker,
+		warmupURLs:            append([]string(nil), c.warmupURLs...),
+		onWarmupError:         c.onWarmupError,
+	}
+
+	if c.tlsProfiles != nil {
+		clone.tlsProfiles = make(map[string]TLSProfile, len(c.tlsProfiles))
+		for name, profile := range c.tlsProfiles {
+			clone.tlsProfiles[name] = profile
+		}
+	}
+	if c.methodTimeouts != nil {
+		clone.methodTimeouts = make(map[string]time.Duration, len(c.methodTimeouts))
+		for method, d := range c.methodTimeouts {
+			clone.methodTimeouts[method] = d
+		}
+	}
+	if c.interceptors != nil {
+		clone.interceptors = &statusInterceptors{
+			fns: make(map[int][]func(*http.Request, *http.Response) error),
+		}
+		c.interceptors.mtx.RLock()
+		for code, fns := range c.interceptors.fns {
+			clone.interceptors.fns[code] = append([]func(*http.Request, *http.Response) error(nil), fns...)
+		}
+		c.interceptors.mtx.RUnlock()
+	}
+	if c.dialSlots != nil {
+		clone.dialSlots = make(chan struct{}, cap(c.dialSlots))
+	}
+	if c.connReport != nil {
+		clone.connReport = &connReport{
+			hosts: make(map[string]*HostConnStats),
+		}
+	}
+	if c.hostMetrics != nil {
+		clone.hostMetrics = &hostMetrics{
+			hosts: make(map[string]*latencyHistogram),
+		}
+	}
+	if c.live != nil {
+		clone.live = &liveStats{}
+	}
+	if c.errorSamples != nil {
+		clone.errorSamples = newErrorSamples(len(c.errorSamples.samples))
+	}
+	if c.tenants != nil {
+		clone.tenants = &tenantTransports{
+			transports: make(map[tenantTransportKey]*http.Transport),
+		}
+	}
+	if c.affinity != nil {
+		clone.affinity = newConnAffinity()
+	}
+	if c.softLimit != nil {
+		clone.softLimit = &softConnLimit{
+			conns:      c.softLimit.conns,
+			transports: make(map[*http.Transport]*softLimitTransport),
+		}
+	}
+
+	clone.resetClients()
+	return clone
+}
@@ -0,0 +1,48 @@
+package http_test
+
+import (
+	nethttp "net/http"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestClone(t *testing.T) {
+	var calls int32
+	parent := http.NewClientPool(http.WithRetry(3, time.Millisecond))
+	parent.SetTransport(failingTransport(2, nethttp.StatusServiceUnavailable, &calls))
+	parent.SetDefaultTimeout(5 * time.Second)
+	parent.SetMethodTimeout(nethttp.MethodGet, time.Second)
+
+	clone := parent.Clone()
+	if got := clone.GetClient(0).Timeout; got != 5*time.Second {
+		t.Errorf("clone default timeout = %v, want 5s", got)
+	}
+
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/", nil)
+	resp, err := clone.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != nethttp.StatusOK || calls != 3 {
+		t.Errorf("got status %d after %d calls, want the retries of the parent", resp.StatusCode, calls)
+	}
+	if _, ok := clone.SnapshotClients()[time.Second]; !ok {
+		t.Error("clone did not use the method timeouts of the parent")
+	}
+
+	// Changing the clone does not affect the parent.
+	clone.SetMethodTimeout(nethttp.MethodGet, 2*time.Second)
+	clone.SetTransport(okTransport)
+	if _, err := parent.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := parent.SnapshotClients()[2*time.Second]; ok {
+		t.Error("method timeouts of the clone leaked to the parent")
+	}
+	if len(parent.SnapshotClients()) != 1 {
+		t.Errorf("got %d clients in the parent, want 1", len(parent.SnapshotClients()))
+	}
+}
@@ -0,0 +1,142 @@
+package http
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+)
+
+// SetCloseOnHeader makes the clients of the pool close the connection of
+// every response carrying the specified header, whatever its value, so
+// that it is not reused by the next requests, for servers signaling that
+// they are draining with a custom header rather than Connection: close.
+// The connection is closed once the body of the response has been read
+// or closed, or once the transport hands it to another request for the
+// responses without a body, in which case the transport sends that
+// request again over a new connection if its body can be sent again. An
+// empty header removes the setting.
+//
+// HTTP/2 connections, shared by concurrent requests, are never closed,
+// and only transports supporting the httptrace package, as the default
+// transport does, report the connections to close.
+func (c *ClientPool) SetCloseOnHeader(header string) {
+	c.mtx.Lock()
+	{
+		c.closeOnHeader = http.CanonicalHeaderKey(header)
+		if c.closingConns == nil {
+			c.closingConns = &closingConns{conns: make(map[net.Conn]struct{})}
+		}
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+	c.mtx.Unlock()
+}
+
+// closingConns holds the connections to close once their response is
+// done.
+type closingConns struct {
+	mtx   sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// add marks the connection to be closed.
+func (cc *closingConns) add(conn net.Conn) {
+	cc.mtx.Lock()
+	{
+		cc.conns[conn] = struct{}{}
+	}
+	cc.mtx.Unlock()
+}
+
+// close closes the connection if it was marked, and reports whether it
+// was.
+func (cc *closingConns) close(conn net.Conn) bool {
+	cc.mtx.Lock()
+	_, ok := cc.conns[conn]
+	delete(cc.conns, conn)
+	cc.mtx.Unlock()
+
+	if ok {
+		conn.Close()
+	}
+	return ok
+}
+
+// closeOnHeaderTransport closes the connections of the responses of the
+// next transport carrying the header.
+type closeOnHeaderTransport struct {
+	next    http.RoundTripper
+	header  string
+	closing *closingConns
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *closeOnHeaderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The transport may get a second connection if the first one fails,
+	// in which case the response comes from the last one.
+	var mtx sync.Mutex
+	var conn net.Conn
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			// The transport may reuse a connection to close before
+			// the body of its previous response is done. Closing it
+			// before the request is written makes the transport send
+			// the request again over a new connection.
+			if info.Reused && t.closing.close(info.Conn) {
+				return
+			}
+
+			mtx.Lock()
+			conn = info.Conn
+			mtx.Unlock()
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	mtx.Lock()
+	c := conn
+	mtx.Unlock()
+
+	if _, ok := resp.Header[t.header]; ok && c != nil && resp.ProtoMajor < 2 {
+		t.closing.add(c)
+
+		// The connection of a response without a body is idle
+		// already, and possibly used by another request: it is closed
+		// when next reused instead.
+		if resp.Body != http.NoBody {
+			resp.Body = &closeConnBody{ReadCloser: resp.Body, conn: c, closing: t.closing}
+		}
+	}
+	return resp, nil
+}
+
+// closeConnBody is a response body closing the connection it is read
+// from once read entirely or closed.
+type closeConnBody struct {
+	io.ReadCloser
+	conn    net.Conn
+	closing *closingConns
+}
+
+func (b *closeConnBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.closing.close(b.conn)
+	}
+	return n, err
+}
+
+func (b *closeConnBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.closing.close(b.conn)
+	return err
+}
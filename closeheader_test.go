@@ -0,0 +1,108 @@
+package http_test
+
+import (
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestCloseOnHeader(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/drain" {
+			w.Header().Set("X-Drain", "1")
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	pool := http.NewClientPool()
+	pool.SetCloseOnHeader("x-drain")
+	client := pool.GetClient(time.Second)
+
+	// reused reports whether the request was sent over a reused
+	// connection.
+	reused := func(path string) bool {
+		var reused bool
+		trace := &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused },
+		}
+		req, _ := nethttp.NewRequest(nethttp.MethodGet, server.URL+path, nil)
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return reused
+	}
+
+	for i, step := range []struct {
+		path   string
+		reused bool
+	}{
+		{"/", false},
+		{"/", true},
+		{"/drain", true},
+		{"/", false},
+		{"/", true},
+	} {
+		if got := reused(step.path); got != step.reused {
+			t.Errorf("request %d to %s: reused = %t, want %t", i, step.path, got, step.reused)
+		}
+	}
+}
+
+func TestCloseOnHeaderConcurrent(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.Copy(io.Discard, r.Body)
+		switch r.URL.Path {
+		case "/drain":
+			w.Header().Set("X-Drain", "1")
+			io.WriteString(w, "ok")
+		case "/drain-empty":
+			w.Header().Set("X-Drain", "1")
+		default:
+			io.WriteString(w, "ok")
+		}
+	}))
+	defer server.Close()
+
+	pool := http.NewClientPool()
+	pool.SetCloseOnHeader("X-Drain")
+	client := pool.GetClient(5 * time.Second)
+
+	var wg sync.WaitGroup
+	paths := []string{"/", "/drain", "/drain-empty"}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 30; j++ {
+				path := paths[(i+j)%len(paths)]
+				resp, err := client.Post(server.URL+path, "text/plain", strings.NewReader("body"))
+				if err != nil {
+					t.Errorf("request to %s: %v", path, err)
+					return
+				}
+				body, err := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err != nil {
+					t.Errorf("request to %s: %v", path, err)
+					return
+				}
+				if path != "/drain-empty" && string(body) != "ok" {
+					t.Errorf("request to %s: body = %q, want ok", path, body)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// adaptiveCompressionMisses is the number of consecutive uncompressed
+// responses after which a host is considered not to support
+// compression.
+const adaptiveCompressionMisses = 3
+
+// adaptiveCompressionReset is the duration after which compression is
+// requested again from a host that did not support it. It is a variable
+// so that tests can shorten it.
+var adaptiveCompressionReset = 10 * time.Minute
+
+// WithAdaptiveCompression makes the clients of the pool stop requesting
+// compressed responses from the hosts that do not honor it. The core
+// http package transparently requests gzip-compressed responses; after
+// three consecutive responses from a host that were not compressed, the
+// requests to that host are sent with "Accept-Encoding: identity"
+// instead, for ten minutes after which compression is requested again.
+// Requests that already carry an Accept-Encoding header are sent as is.
+func WithAdaptiveCompression() Option {
+	ac := &adaptiveCompression{
+		hosts: make(map[string]*compressionHost),
+	}
+
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			return &compressionTransport{
+				next:        next,
+				compression: ac,
+				clock:       c.timeSource(),
+			}
+		})
+	}
+}
+
+// compressionHost tracks whether a host compresses its responses.
+type compressionHost struct {
+	misses        int
+	disabledUntil time.Time
+}
+
+// adaptiveCompression tracks the hosts not supporting compression.
+type adaptiveCompression struct {
+	mtx   sync.Mutex
+	hosts map[string]*compressionHost
+}
+
+// disabled reports whether compression should not be requested from the
+// host at the specified time.
+func (a *adaptiveCompression) disabled(host string, now time.Time) bool {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	h := a.hosts[host]
+	if h == nil || h.disabledUntil.IsZero() {
+		return false
+	}
+	if now.After(h.disabledUntil) {
+		delete(a.hosts, host)
+		return false
+	}
+	return true
+}
+
+// record records whether a response of the host, received at the
+// specified time, was compressed.
+func (a *adaptiveCompression) record(host string, compressed bool, now time.Time) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if compressed {
+		delete(a.hosts, host)
+		return
+	}
+
+	h := a.hosts[host]
+	if h == nil {
+		h = &compressionHost{}
+		a.hosts[host] = h
+	}
+	if h.misses++; h.misses >= adaptiveCompressionMisses {
+		h.disabledUntil = now.Add(adaptiveCompressionReset)
+	}
+}
+
+// compressionTransport requests compressed responses from the next
+// transport only for the hosts supporting compression.
+type compressionTransport struct {
+	next        http.RoundTripper
+	compression *adaptiveCompression
+	clock       clock
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *compressionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept-Encoding") != "" || req.Method == http.MethodHead {
+		return t.next.RoundTrip(req)
+	}
+
+	host := req.URL.Host
+	if t.compression.disabled(host, t.clock.Now()) {
+		ireq := req.Clone(req.Context())
+		ireq.Header.Set("Accept-Encoding", "identity")
+		return t.next.RoundTrip(ireq)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Responses without a body tell nothing about compression.
+	if resp.ContentLength != 0 && resp.StatusCode == http.StatusOK {
+		compressed := resp.Uncompressed || resp.Header.Get("Content-Encoding") != ""
+		t.compression.record(host, compressed, t.clock.Now())
+	}
+	return resp, nil
+}
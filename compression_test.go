@@ -0,0 +1,112 @@
+package http_test
+
+import (
+	"compress/gzip"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// encodingServer records the Accept-Encoding header of the requests,
+// and compresses its responses if asked to and gzip is accepted.
+type encodingServer struct {
+	*httptest.Server
+
+	mtx       sync.Mutex
+	encodings []string
+}
+
+func newEncodingServer(compress bool) *encodingServer {
+	s := &encodingServer{}
+	s.Server = httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		s.mtx.Lock()
+		s.encodings = append(s.encodings, r.Header.Get("Accept-Encoding"))
+		s.mtx.Unlock()
+
+		if compress && strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			io.WriteString(zw, "compressed")
+			zw.Close()
+			return
+		}
+		io.WriteString(w, "plain")
+	}))
+	return s
+}
+
+func (s *encodingServer) last() string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.encodings[len(s.encodings)-1]
+}
+
+func getDiscard(t *testing.T, client *nethttp.Client, url string) {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+func TestAdaptiveCompression(t *testing.T) {
+	compressing := newEncodingServer(true)
+	defer compressing.Close()
+	plain := newEncodingServer(false)
+	defer plain.Close()
+
+	cp := http.NewClientPool(http.WithAdaptiveCompression())
+	cp.SetTransport(&nethttp.Transport{})
+	client := cp.GetClient(time.Second)
+
+	for i := 0; i < 3; i++ {
+		getDiscard(t, client, compressing.URL)
+		getDiscard(t, client, plain.URL)
+		if plain.last() != "gzip" {
+			t.Fatalf("request %d to the plain host accepted %q, want gzip until observed", i, plain.last())
+		}
+	}
+
+	getDiscard(t, client, compressing.URL)
+	getDiscard(t, client, plain.URL)
+	if compressing.last() != "gzip" {
+		t.Errorf("compressing host got Accept-Encoding %q, want gzip", compressing.last())
+	}
+	if plain.last() != "identity" {
+		t.Errorf("plain host got Accept-Encoding %q, want identity", plain.last())
+	}
+}
+
+func TestAdaptiveCompressionReset(t *testing.T) {
+	defer http.SetAdaptiveCompressionReset(50 * time.Millisecond)()
+
+	plain := newEncodingServer(false)
+	defer plain.Close()
+
+	cp := http.NewClientPool(http.WithAdaptiveCompression())
+	cp.SetTransport(&nethttp.Transport{})
+	client := cp.GetClient(time.Second)
+
+	for i := 0; i < 4; i++ {
+		getDiscard(t, client, plain.URL)
+	}
+	if plain.last() != "identity" {
+		t.Fatalf("plain host got Accept-Encoding %q, want identity", plain.last())
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	getDiscard(t, client, plain.URL)
+	if plain.last() != "gzip" {
+		t.Fatalf("plain host got Accept-Encoding %q after the reset, want gzip", plain.last())
+	}
+}
@@ -0,0 +1,34 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// GetConditional sends a conditional GET request to the specified URL,
+// using a client of the pool with the specified timeout, and reports
+// whether the resource changed since it was last retrieved. The request
+// carries an If-None-Match header with the etag, if not empty, and an
+// If-Modified-Since header with modifiedSince, if not zero. The resource
+// is reported unchanged if the server answers with a 304 status code, in
+// which case the response has no body, and changed otherwise. In both
+// cases the caller must close the body of the response.
+func (c *ClientPool) GetConditional(ctx context.Context, url, etag string, modifiedSince time.Time, timeout time.Duration) (*http.Response, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if !modifiedSince.IsZero() {
+		req.Header.Set("If-Modified-Since", modifiedSince.UTC().Format(http.TimeFormat))
+	}
+
+	resp, err := c.sendTimeout(timeout, req, c.send)
+	if err != nil {
+		return nil, false, err
+	}
+	return resp, resp.StatusCode != http.StatusNotModified, nil
+}
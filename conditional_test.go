@@ -0,0 +1,48 @@
+package http_test
+
+import (
+	"context"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestGetConditional(t *testing.T) {
+	modified := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Header().Set("ETag", `"v2"`)
+		nethttp.ServeContent(w, r, "", modified, strings.NewReader("content"))
+	}))
+	defer server.Close()
+
+	pool := http.NewClientPool()
+	for _, test := range []struct {
+		name          string
+		etag          string
+		modifiedSince time.Time
+		changed       bool
+		body          string
+	}{
+		{"no validators", "", time.Time{}, true, "content"},
+		{"stale etag", `"v1"`, time.Time{}, true, "content"},
+		{"current etag", `"v2"`, time.Time{}, false, ""},
+		{"modified", "", modified.Add(-time.Hour), true, "content"},
+		{"not modified", "", modified, false, ""},
+	} {
+		resp, changed, err := pool.GetConditional(context.Background(), server.URL, test.etag, test.modifiedSince, time.Second)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if changed != test.changed || string(body) != test.body {
+			t.Errorf("%s: changed = %t with body %q, want %t with %q", test.name, changed, body, test.changed, test.body)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+)
+
+// HostConnStats holds the number of connections to a host, as reported
+// by ConnectionReport.
+type HostConnStats struct {
+	// Idle is the number of connections kept open for reuse.
+	Idle int
+
+	// Active is the number of connections carrying a request whose
+	// response body has not been closed yet.
+	Active int
+}
+
+// WithConnectionReport makes the pool account the connections used by
+// its clients, per host, see ConnectionReport.
+func WithConnectionReport() Option {
+	return func(c *ClientPool) {
+		c.connReport = &connReport{
+			hosts: make(map[string]*HostConnStats),
+		}
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+}
+
+// ConnectionReport returns the number of idle and active connections
+// held by the clients of the pool, by host of the request URLs, port
+// included if specified. It returns nil if the pool was not created
+// with WithConnectionReport.
+//
+// The counts are derived from the connection events of the requests,
+// and are approximate: idle connections closed by the transport, for
+// instance after their idle timeout, are still counted until they are
+// reused. HTTP/2 connections are never reported as idle, and count as
+// active once per request in flight. The counts are reset when the
+// transport settings of the pool change.
+func (c *ClientPool) ConnectionReport() map[string]HostConnStats {
+	c.mtx.RLock()
+	r := c.connReport
+	c.mtx.RUnlock()
+
+	if r == nil {
+		return nil
+	}
+	return r.snapshot()
+}
+
+// connReport accounts the connections used by the requests, per host.
+type connReport struct {
+	mtx   sync.Mutex
+	hosts map[string]*HostConnStats
+}
+
+// add adds the deltas to the counts of the host, and returns the counts.
+func (r *connReport) add(host string, idle, active int) *HostConnStats {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	stats := r.hosts[host]
+	if stats == nil {
+		stats = &HostConnStats{}
+		r.hosts[host] = stats
+	}
+	stats.Idle += idle
+	stats.Active += active
+	if stats.Idle < 0 {
+		stats.Idle = 0
+	}
+	return stats
+}
+
+// release releases an active connection from the counts it was added
+// to, which are discarded already if the report was reset since.
+func (r *connReport) release(stats *HostConnStats) {
+	r.mtx.Lock()
+	{
+		stats.Active--
+	}
+	r.mtx.Unlock()
+}
+
+// snapshot returns a copy of the counts.
+func (r *connReport) snapshot() map[string]HostConnStats {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	report := make(map[string]HostConnStats, len(r.hosts))
+	for host, stats := range r.hosts {
+		report[host] = *stats
+	}
+	return report
+}
+
+// reset discards the counts.
+func (r *connReport) reset() {
+	r.mtx.Lock()
+	r.hosts = make(map[string]*HostConnStats)
+	r.mtx.Unlock()
+}
+
+// connReportTransport accounts the connections used by the requests
+// sent through the next transport.
+type connReportTransport struct {
+	next   http.RoundTripper
+	report *connReport
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *connReportTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+
+	// held holds the counts the connection of the request was added to,
+	// if it holds one. The transport may get a second connection if the
+	// first one fails, in which case the request still holds a single
+	// connection.
+	var mtx sync.Mutex
+	var held *HostConnStats
+	release := func() {
+		mtx.Lock()
+		stats := held
+		held = nil
+		mtx.Unlock()
+
+		if stats != nil {
+			t.report.release(stats)
+		}
+	}
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			idle := 0
+			if info.WasIdle {
+				idle = -1
+			}
+
+			mtx.Lock()
+			if held != nil {
+				t.report.add(host, idle, 0)
+			} else {
+				held = t.report.add(host, idle, 1)
+			}
+			mtx.Unlock()
+		},
+		PutIdleConn: func(err error) {
+			if err == nil {
+				t.report.add(host, 1, 0)
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
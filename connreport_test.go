@@ -0,0 +1,116 @@
+package http_test
+
+import (
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// waitReport waits for the connection report of the pool to match the
+// expected one.
+func waitReport(t *testing.T, pool *http.ClientPool, want map[string]http.HostConnStats) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		got := pool.ConnectionReport()
+		if reflect.DeepEqual(got, want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got report %v, want %v", got, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestConnectionReport(t *testing.T) {
+	handler := nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, "ok")
+	})
+	a := httptest.NewServer(handler)
+	defer a.Close()
+	b := httptest.NewServer(handler)
+	defer b.Close()
+	hostA := strings.TrimPrefix(a.URL, "http://")
+	hostB := strings.TrimPrefix(b.URL, "http://")
+
+	pool := http.NewClientPool(http.WithConnectionReport())
+	client := pool.GetClient(time.Second)
+
+	// Two sequential requests to the first host reuse the same
+	// connection, while the body of the request to the second host is
+	// kept open.
+	getDiscard(t, client, a.URL)
+	getDiscard(t, client, a.URL)
+	resp, err := client.Get(b.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitReport(t, pool, map[string]http.HostConnStats{
+		hostA: {Idle: 1},
+		hostB: {Active: 1},
+	})
+
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	waitReport(t, pool, map[string]http.HostConnStats{
+		hostA: {Idle: 1},
+		hostB: {Idle: 1},
+	})
+}
+
+func TestConnectionReportFailedRequest(t *testing.T) {
+	pool := http.NewClientPool(http.WithConnectionReport())
+	pool.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		// The connection fails once obtained.
+		if trace := httptrace.ContextClientTrace(req.Context()); trace != nil && trace.GotConn != nil {
+			trace.GotConn(httptrace.GotConnInfo{})
+		}
+		return nil, errFailing
+	}))
+
+	if _, err := pool.GetClient(time.Second).Get("http://example.com/"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if report := pool.ConnectionReport(); report["example.com"].Active != 0 {
+		t.Errorf("got report %v, want no active connection", report)
+	}
+}
+
+func TestConnectionReportDisabled(t *testing.T) {
+	if report := http.NewClientPool().ConnectionReport(); report != nil {
+		t.Errorf("got report %v, want nil", report)
+	}
+}
+
+func TestConnectionReportReset(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	pool := http.NewClientPool(http.WithConnectionReport())
+	resp, err := pool.GetClient(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The request in flight when the counts are reset is not released
+	// from the new counts, while its connection still goes idle.
+	pool.SetDefaultTLSConfig(nil)
+	getDiscard(t, pool.GetClient(time.Second), server.URL)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	waitReport(t, pool, map[string]http.HostConnStats{
+		host: {Idle: 2},
+	})
+}
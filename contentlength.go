@@ -0,0 +1,102 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// nopCloserTypes are the types of the readers returned by io.NopCloser,
+// which depend on whether the reader it wraps implements io.WriterTo.
+var nopCloserTypes = map[reflect.Type]bool{
+	reflect.TypeOf(io.NopCloser(nil)):                   true,
+	reflect.TypeOf(io.NopCloser(strings.NewReader(""))): true,
+}
+
+// WithAutoContentLength makes the clients of the pool compute the length
+// of the requests whose body is held in memory, when their ContentLength
+// is not set. Without it such requests are sent with chunked encoding,
+// which some servers reject. GetBody is also set so the requests can be
+// sent again. A body is held in memory if it has the Len and Bytes
+// methods of a *bytes.Buffer, or the Len, Size and Seek methods of a
+// *bytes.Reader or *strings.Reader, for instance a type embedding one of
+// them with a Close method, possibly wrapped by io.NopCloser. Requests
+// with other bodies are left untouched.
+func WithAutoContentLength() Option {
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			return &contentLengthTransport{next: next}
+		})
+	}
+}
+
+// contentLengthTransport sets the length of the in-memory request bodies
+// before sending them through the next transport.
+type contentLengthTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *contentLengthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.ContentLength != 0 || req.Body == nil || req.Body == http.NoBody {
+		return t.next.RoundTrip(req)
+	}
+
+	length, getBody := inMemoryBody(req.Body)
+	if getBody == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	sized := req.Clone(req.Context())
+	sized.ContentLength = length
+	if sized.GetBody == nil {
+		sized.GetBody = getBody
+	}
+	return t.next.RoundTrip(sized)
+}
+
+// inMemoryBody returns the length of a body held in memory and a
+// function returning a new reader of its content. The function is nil
+// if the body is not held in memory.
+func inMemoryBody(body io.ReadCloser) (int64, func() (io.ReadCloser, error)) {
+	var r io.Reader = body
+	if v := reflect.ValueOf(body); nopCloserTypes[v.Type()] {
+		// The reader wrapped by io.NopCloser is its only field.
+		if f := v.Field(0); f.CanInterface() {
+			if inner, ok := f.Interface().(io.Reader); ok {
+				r = inner
+			}
+		}
+	}
+
+	switch r := r.(type) {
+	case interface {
+		Len() int
+		Bytes() []byte
+	}:
+		buf := r.Bytes()
+		return int64(len(buf)), func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(buf)), nil
+		}
+	case interface {
+		io.ReadSeeker
+		Len() int
+		Size() int64
+	}:
+		return int64(r.Len()), seekBody(r, r.Size()-int64(r.Len()))
+	}
+	return 0, nil
+}
+
+// seekBody returns a function rewinding the reader to the offset and
+// returning it as a body.
+func seekBody(r io.ReadSeeker, offset int64) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return io.NopCloser(r), nil
+	}
+}
@@ -0,0 +1,102 @@
+package http_test
+
+import (
+	"bytes"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// closingBuffer is a body held in a *bytes.Buffer.
+type closingBuffer struct {
+	*bytes.Buffer
+}
+
+func (closingBuffer) Close() error { return nil }
+
+// closingReader is a body held in a *strings.Reader.
+type closingReader struct {
+	*strings.Reader
+}
+
+func (closingReader) Close() error { return nil }
+
+// closingBytesReader is a body held in a *bytes.Reader.
+type closingBytesReader struct {
+	*bytes.Reader
+}
+
+func (closingBytesReader) Close() error { return nil }
+
+func TestAutoContentLength(t *testing.T) {
+	type sent struct {
+		length  int64
+		getBody bool
+	}
+	var got sent
+
+	cp := http.NewClientPool(http.WithAutoContentLength())
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		got = sent{req.ContentLength, req.GetBody != nil}
+		if req.GetBody != nil {
+			// The body can be read again from the start.
+			body, _ := req.GetBody()
+			if content, _ := io.ReadAll(body); string(content) != "payload" {
+				t.Errorf("GetBody returned %q, want %q", content, "payload")
+			}
+		}
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+	client := cp.GetClient(time.Second)
+
+	tests := []struct {
+		name string
+		body io.ReadCloser
+		want sent
+	}{
+		{"bytes.Reader", closingBytesReader{bytes.NewReader([]byte("payload"))}, sent{7, true}},
+		{"bytes.Buffer", closingBuffer{bytes.NewBufferString("payload")}, sent{7, true}},
+		{"strings.Reader", closingReader{strings.NewReader("payload")}, sent{7, true}},
+		{"NopCloser bytes.Reader", io.NopCloser(bytes.NewReader([]byte("payload"))), sent{7, true}},
+		{"NopCloser bytes.Buffer", io.NopCloser(bytes.NewBufferString("payload")), sent{7, true}},
+		{"NopCloser strings.Reader", io.NopCloser(strings.NewReader("payload")), sent{7, true}},
+		{"stream", io.NopCloser(io.MultiReader(strings.NewReader("payload"))), sent{0, false}},
+	}
+	for _, tt := range tests {
+		req, _ := nethttp.NewRequest(nethttp.MethodPost, "http://example.com/", nil)
+		req.Body = tt.body
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if got != tt.want {
+			t.Errorf("%s: sent %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAutoContentLengthServer(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.ContentLength != 7 || len(r.TransferEncoding) != 0 {
+			t.Errorf("received length %d with encoding %v, want 7 without encoding", r.ContentLength, r.TransferEncoding)
+		}
+	}))
+	defer server.Close()
+
+	req, _ := nethttp.NewRequest(nethttp.MethodPost, server.URL, nil)
+	req.Body = io.NopCloser(strings.NewReader("payload"))
+
+	resp, err := http.NewClientPool(http.WithAutoContentLength()).GetClient(time.Second).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
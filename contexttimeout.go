@@ -0,0 +1,52 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// WithContextTimeoutsOnly makes GetClient return a single client with no
+// timeout for all timeouts, and the helpers of the pool which take a
+// timeout, such as Do, DoAndDrain and DownloadFile, enforce it through
+// the context of the request instead. Requests sent directly with the
+// client, through its Do method, have no timeout in this mode, unless
+// their context has a deadline.
+func WithContextTimeoutsOnly() Option {
+	return func(c *ClientPool) {
+		c.contextTimeoutsOnly = true
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+}
+
+// sendTimeout sends the request with send using the client of the pool
+// for the timeout, or, when the pool enforces timeouts through the
+// context, using the shared client with the context of the request
+// bounded by the timeout until the response body is closed.
+func (c *ClientPool) sendTimeout(timeout time.Duration, req *http.Request, send func(*http.Client, *http.Request) (*http.Response, error)) (*http.Response, error) {
+	if !c.contextTimeoutsOnly {
+		return send(c.GetClient(timeout), req)
+	}
+
+	if timeout <= 0 {
+		timeout = time.Duration(atomic.LoadInt64(&c.defaultTimeout))
+	}
+	client := c.GetClient(0)
+	if timeout <= 0 {
+		return send(client, req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := send(client, req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
@@ -0,0 +1,63 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	nethttp "net/http"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestContextTimeoutsOnlySharedClient(t *testing.T) {
+	cp := http.NewClientPool(http.WithContextTimeoutsOnly())
+	cp.SetDefaultTimeout(time.Minute)
+
+	client := cp.GetClient(time.Second)
+	for _, timeout := range []time.Duration{0, time.Millisecond, 5 * time.Second, time.Hour} {
+		if got := cp.GetClient(timeout); got != client {
+			t.Errorf("GetClient(%v) returned another client", timeout)
+		}
+	}
+	if client.Timeout != 0 {
+		t.Errorf("got client timeout %v, want 0", client.Timeout)
+	}
+	if clone := cp.Clone(); clone.GetClient(time.Second) != clone.GetClient(time.Minute) {
+		t.Error("clone returned distinct clients")
+	}
+}
+
+func TestContextTimeoutsOnlyDo(t *testing.T) {
+	cp := http.NewClientPool(http.WithContextTimeoutsOnly())
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		if _, ok := req.Context().Deadline(); !ok {
+			return statusResponse(req, nethttp.StatusOK), nil
+		}
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}))
+	cp.SetMethodTimeout(nethttp.MethodGet, 20*time.Millisecond)
+
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/", nil)
+	start := time.Now()
+	if _, err := cp.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Do returned after %v", elapsed)
+	}
+
+	err := cp.DoAndDrain(context.Background(), req, 20*time.Millisecond, func(*nethttp.Response) error { return nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// Without a timeout the request has no deadline.
+	req, _ = nethttp.NewRequest(nethttp.MethodPost, "http://example.com/", nil)
+	resp, err := cp.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// ClientForContext returns a HTTP Client whose timeout matches the time
+// remaining until the deadline of the specified context. The remaining
+// time is rounded down to two significant digits to limit the number
+// of clients created by the pool. If the deadline has already expired a
+// client with a timeout of one nanosecond is returned, and if the
+// context has no deadline the client for the default timeout is
+// returned.
+func (c *ClientPool) ClientForContext(ctx context.Context) *http.Client {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return c.GetClient(0)
+	}
+
+	return c.GetClient(bucketTimeout(time.Until(deadline)))
+}
+
+// bucketTimeout rounds the specified timeout down to two significant
+// digits. Non-positive timeouts are mapped to the smallest positive one
+// so that they are not mistaken for no timeout.
+func bucketTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return time.Nanosecond
+	}
+
+	granularity := time.Duration(1)
+	for granularity*100 <= timeout {
+		granularity *= 10
+	}
+	return timeout.Truncate(granularity)
+}
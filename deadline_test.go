@@ -0,0 +1,52 @@
+package http_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestClientForContextDeadline(t *testing.T) {
+	cp := http.NewClientPool()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3750*time.Millisecond)
+	defer cancel()
+
+	client := cp.ClientForContext(ctx)
+	if client.Timeout <= 3600*time.Millisecond || client.Timeout > 3750*time.Millisecond {
+		t.Fatalf("timeout = %v, want about 3.7s", client.Timeout)
+	}
+	if client.Timeout%(100*time.Millisecond) != 0 {
+		t.Fatalf("timeout = %v, want a 100ms bucket", client.Timeout)
+	}
+
+	// Contexts with close deadlines share the same bucket.
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 3750*time.Millisecond)
+	defer cancel2()
+	if cp.ClientForContext(ctx2) != client {
+		t.Fatal("expected contexts with close deadlines to share a client")
+	}
+}
+
+func TestClientForContextExpired(t *testing.T) {
+	cp := http.NewClientPool()
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	client := cp.ClientForContext(ctx)
+	if client.Timeout != time.Nanosecond {
+		t.Fatalf("timeout = %v, want 1ns", client.Timeout)
+	}
+}
+
+func TestClientForContextNoDeadline(t *testing.T) {
+	cp := http.NewClientPool()
+
+	client := cp.ClientForContext(context.Background())
+	if client != cp.GetClient(0) {
+		t.Fatal("expected the default timeout client")
+	}
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"context"
+	"net"
+)
+
+// SetMaxConcurrentDials limits the number of connections the default
+// transport establishes at the same time across all the clients of the
+// pool to n, to protect the resolver and the connection tracking of the
+// host from bursts of new connections. Dials beyond the limit wait for
+// a dial in progress to complete, or for their context to be done. The
+// limit applies to the dials rather than to the connections, which are
+// limited per host by the transport. A non-positive n removes the limit.
+// Transports set with SetTransport are not affected.
+func (c *ClientPool) SetMaxConcurrentDials(n int) {
+	c.mtx.Lock()
+	{
+		c.dialSlots = nil
+		if n > 0 {
+			c.dialSlots = make(chan struct{}, n)
+		}
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+	c.mtx.Unlock()
+}
+
+// limitDials returns the dial function waiting for a dial slot, or the
+// function as is if the dials are not limited. Must be called while
+// holding the lock.
+func (c *ClientPool) limitDials(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	slots := c.dialSlots
+	if slots == nil {
+		return dial
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		defer func() { <-slots }()
+
+		return dial(ctx, network, addr)
+	}
+}
@@ -0,0 +1,120 @@
+package http_test
+
+import (
+	"context"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// dialTracker tracks the dials of the default transport made with the
+// dial function it installs.
+type dialTracker struct {
+	dial func(d *net.Dialer, ctx context.Context, network, addr string) (net.Conn, error)
+
+	inProgress, maxInProgress, dials int32
+}
+
+// trackDials installs the dial function of the tracker until the end of
+// the test, once all the dials completed.
+func trackDials(t *testing.T, dial func(d *net.Dialer, ctx context.Context, network, addr string) (net.Conn, error)) *dialTracker {
+	tracker := &dialTracker{dial: dial}
+	restore := http.SetDialContext(tracker.track)
+	t.Cleanup(func() {
+		tracker.wait()
+		restore()
+	})
+	return tracker
+}
+
+func (tr *dialTracker) track(d *net.Dialer, ctx context.Context, network, addr string) (net.Conn, error) {
+	n := atomic.AddInt32(&tr.inProgress, 1)
+	defer atomic.AddInt32(&tr.inProgress, -1)
+	atomic.AddInt32(&tr.dials, 1)
+	for {
+		max := atomic.LoadInt32(&tr.maxInProgress)
+		if n <= max || atomic.CompareAndSwapInt32(&tr.maxInProgress, max, n) {
+			break
+		}
+	}
+
+	return tr.dial(d, ctx, network, addr)
+}
+
+// wait waits for the dials to complete. Dials may complete after their
+// requests, which can use connections established for other requests,
+// and may also start once a dial slot is released.
+func (tr *dialTracker) wait() {
+	for idle := 0; idle < 5; idle++ {
+		if atomic.LoadInt32(&tr.inProgress) > 0 {
+			idle = 0
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMaxConcurrentDials(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	defer server.Close()
+
+	tracker := trackDials(t, func(d *net.Dialer, ctx context.Context, network, addr string) (net.Conn, error) {
+		time.Sleep(10 * time.Millisecond)
+		return d.DialContext(ctx, network, addr)
+	})
+
+	cp := http.NewClientPool()
+	cp.SetMaxConcurrentDials(3)
+	client := cp.GetClient(5 * time.Second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			getDiscard(t, client, server.URL)
+		}()
+	}
+	wg.Wait()
+	tracker.wait()
+
+	if max := atomic.LoadInt32(&tracker.maxInProgress); max > 3 {
+		t.Errorf("got %d dials in progress at the same time, want at most 3", max)
+	}
+	if dials := atomic.LoadInt32(&tracker.dials); dials < 4 {
+		t.Errorf("got %d dials, want a burst of more than 3", dials)
+	}
+}
+
+func TestMaxConcurrentDialsContext(t *testing.T) {
+	release := make(chan struct{})
+	trackDials(t, func(d *net.Dialer, ctx context.Context, network, addr string) (net.Conn, error) {
+		<-release
+		return nil, context.Canceled
+	})
+	defer close(release)
+
+	cp := http.NewClientPool()
+	cp.SetMaxConcurrentDials(1)
+	client := cp.GetClient(time.Second)
+
+	// The first request holds the only dial slot.
+	go client.Get("http://first.example.com/")
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, "http://second.example.com/", nil)
+	start := time.Now()
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("expected an error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second/2 {
+		t.Errorf("the waiting dial took %v, want it to stop with its context", elapsed)
+	}
+}
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SetMethodTimeout sets the timeout of the client used by Do for the
+// requests with the specified method, for instance a short timeout for
+// GET and HEAD requests and a longer one for POST and PUT requests. A
+// non-positive duration removes the timeout of the method, in which case
+// its requests use the client for the default timeout.
+func (c *ClientPool) SetMethodTimeout(method string, d time.Duration) {
+	c.mtx.Lock()
+	{
+		method = strings.ToUpper(method)
+		if d <= 0 {
+			delete(c.methodTimeouts, method)
+		} else {
+			if c.methodTimeouts == nil {
+				c.methodTimeouts = make(map[string]time.Duration)
+			}
+			c.methodTimeouts[method] = d
+		}
+	}
+	c.mtx.Unlock()
+}
+
+// Do sends the request using a client of the pool whose timeout is
+// selected based on the request method, see SetMethodTimeout. Requests
+// whose method has no timeout use the client for the default timeout.
+// Failures are returned as a *RequestError.
+func (c *ClientPool) Do(req *http.Request) (*http.Response, error) {
+	return c.sendTimeout(c.requestTimeout(req), req, c.do)
+}
+
+// requestTimeout returns the timeout of the client used to send the
+// request when the caller does not specify one.
+func (c *ClientPool) requestTimeout(req *http.Request) time.Duration {
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	return c.methodTimeouts[method]
+}
+
+// GetClientWithCancel returns the HTTP Client for the specified timeout
+// along with a context derived from parent and its cancel function, to
+// be used for the requests sent with the client. The caller must call
+// cancel once done with the requests; DoWithCancel does it automatically.
+func (c *ClientPool) GetClientWithCancel(parent context.Context, timeout time.Duration) (*http.Client, context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	return c.GetClient(timeout), ctx, cancel
+}
+
+// DoWithCancel sends the request like Do, with a context derived from
+// the context of the request that is cancelled once the response body is
+// closed, or when the request fails. This ties the lifetime of the
+// context to the lifetime of the body, so that it cannot be leaked.
+func (c *ClientPool) DoWithCancel(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+
+	resp, err := c.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// DoAndDrain sends the request with the specified context using the
+// client of the pool for the specified timeout, and calls handle with
+// the response. Once handle returns, the remainder of the body is read,
+// up to 64KiB, and the body is closed, so that the connection can be
+// reused even if handle returned early or read only part of the body.
+// It returns the error of handle, the failure to send the request as a
+// *RequestError, or the error of the decoder set with SetErrorDecoder,
+// in which case handle is not called.
+func (c *ClientPool) DoAndDrain(ctx context.Context, req *http.Request, timeout time.Duration, handle func(*http.Response) error) error {
+	resp, err := c.sendTimeout(timeout, req.WithContext(ctx), c.do)
+	if err != nil {
+		return err
+	}
+	defer discardBody(resp)
+
+	return handle(resp)
+}
+
+// DoWithTotalTimeout sends the request like Do, bounding the whole
+// operation, retries and redirects included, by the total duration. The
+// deadline is shared with the retry transport, which does not start a
+// retry that cannot complete before it and returns the outcome of the
+// last attempt instead. When the deadline expires while an attempt is in
+// flight, the returned *RequestError wraps context.DeadlineExceeded. The
+// deadline also applies to reading the response body, and is released
+// once the body is closed.
+func (c *ClientPool) DoWithTotalTimeout(ctx context.Context, req *http.Request, total time.Duration) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, total)
+
+	resp, err := c.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
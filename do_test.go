@@ -0,0 +1,210 @@
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestSetMethodTimeout(t *testing.T) {
+	cp := http.NewClientPool()
+	cp.SetTransport(okTransport)
+	cp.SetMethodTimeout(nethttp.MethodGet, time.Second)
+	cp.SetMethodTimeout("post", 10*time.Second)
+
+	tests := []struct {
+		method string
+		want   time.Duration
+	}{
+		{nethttp.MethodGet, time.Second},
+		{"", time.Second},
+		{nethttp.MethodPost, 10 * time.Second},
+		{nethttp.MethodDelete, 0},
+	}
+	for _, tt := range tests {
+		req, _ := nethttp.NewRequest(tt.method, "http://example.com/", nil)
+		resp, err := cp.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if _, ok := cp.SnapshotClients()[tt.want]; !ok {
+			t.Errorf("%q request did not use the client for %v", tt.method, tt.want)
+		}
+	}
+	if n := len(cp.SnapshotClients()); n != 3 {
+		t.Fatalf("got %d clients, want 3", n)
+	}
+}
+
+func TestDoWithCancel(t *testing.T) {
+	var ctx context.Context
+	cp := http.NewClientPool()
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		ctx = req.Context()
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/", nil)
+	resp, err := cp.DoWithCancel(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("context cancelled before the body was closed")
+	}
+
+	resp.Body.Close()
+	if ctx.Err() != context.Canceled {
+		t.Fatalf("context error = %v after the body was closed, want %v", ctx.Err(), context.Canceled)
+	}
+}
+
+func TestDoWithCancelError(t *testing.T) {
+	var ctx context.Context
+	cp := http.NewClientPool()
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		ctx = req.Context()
+		return nil, errors.New("failed")
+	}))
+
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/", nil)
+	if _, err := cp.DoWithCancel(req); err == nil {
+		t.Fatal("expected the request to fail")
+	}
+	if ctx.Err() != context.Canceled {
+		t.Fatalf("context error = %v after the request failed, want %v", ctx.Err(), context.Canceled)
+	}
+}
+
+func TestGetClientWithCancel(t *testing.T) {
+	cp := http.NewClientPool()
+	client, ctx, cancel := cp.GetClientWithCancel(context.Background(), time.Second)
+	if client != cp.GetClient(time.Second) {
+		t.Fatal("expected the client for the timeout")
+	}
+	cancel()
+	if ctx.Err() != context.Canceled {
+		t.Fatal("expected cancel to cancel the context")
+	}
+}
+
+func TestDoAndDrain(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Write(bytes.Repeat([]byte("x"), 32<<10))
+	}))
+	defer server.Close()
+
+	cp := http.NewClientPool()
+	errStop := errors.New("stop")
+
+	var reused []bool
+	for i := 0; i < 3; i++ {
+		trace := &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) { reused = append(reused, info.Reused) },
+		}
+		ctx := httptrace.WithClientTrace(context.Background(), trace)
+		req, _ := nethttp.NewRequest(nethttp.MethodGet, server.URL, nil)
+
+		// The handler reads part of the body and returns early.
+		err := cp.DoAndDrain(ctx, req, time.Second, func(resp *nethttp.Response) error {
+			_, err := resp.Body.Read(make([]byte, 10))
+			if err != nil {
+				return err
+			}
+			return errStop
+		})
+		if !errors.Is(err, errStop) {
+			t.Fatalf("error = %v, want %v", err, errStop)
+		}
+	}
+
+	if len(reused) != 3 || reused[0] || !reused[1] || !reused[2] {
+		t.Errorf("got connection reuse %v, want [false true true]", reused)
+	}
+}
+
+func TestDoAndDrainError(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool()
+	cp.SetTransport(failingTransport(1, 0, &calls))
+
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/", nil)
+	err := cp.DoAndDrain(context.Background(), req, time.Second, func(*nethttp.Response) error {
+		t.Error("handle called for a failed request")
+		return nil
+	})
+	var reqErr *http.RequestError
+	if !errors.As(err, &reqErr) {
+		t.Errorf("error = %v, want a *RequestError", err)
+	}
+}
+
+func TestDoWithTotalTimeout(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool(http.WithRetry(10, 30*time.Millisecond))
+	cp.SetTransport(failingTransport(10, 0, &calls))
+
+	// The retries would take 30ms, 60ms, then 120ms: the third one
+	// cannot complete within the total timeout.
+	start := time.Now()
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/", nil)
+	_, err := cp.DoWithTotalTimeout(context.Background(), req, 150*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("request took %v, want at most 150ms", elapsed)
+	}
+
+	// The error of the last attempt is returned.
+	var reqErr *http.RequestError
+	if !errors.As(err, &reqErr) || !errors.Is(err, errFailing) {
+		t.Fatalf("error = %v, want a *RequestError wrapping %v", err, errFailing)
+	}
+	if calls != 3 || reqErr.Attempts != 3 {
+		t.Errorf("got %d attempts, %d reported, want 3", calls, reqErr.Attempts)
+	}
+}
+
+func TestDoWithTotalTimeoutInFlight(t *testing.T) {
+	cp := http.NewClientPool(http.WithRetry(3, time.Millisecond))
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}))
+
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/", nil)
+	start := time.Now()
+	_, err := cp.DoWithTotalTimeout(context.Background(), req, 50*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second/2 {
+		t.Errorf("request took %v, want it to stop at the deadline", elapsed)
+	}
+}
+
+func TestDoWithTotalTimeoutBody(t *testing.T) {
+	cp := http.NewClientPool()
+	cp.SetTransport(okTransport)
+
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/", nil)
+	resp, err := cp.DoWithTotalTimeout(context.Background(), req, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := resp.Request.Context()
+	if ctx.Err() != nil {
+		t.Fatal("context done before the body was closed")
+	}
+	resp.Body.Close()
+	if ctx.Err() == nil {
+		t.Error("context not released once the body was closed")
+	}
+}
@@ -0,0 +1,36 @@
+package http
+
+import (
+	"context"
+	"net/http"
+)
+
+// Doer sends HTTP requests and returns their responses, as *http.Client
+// does.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// doerKey is the context key for the Doer overriding the clients of the
+// pools.
+type doerKey struct{}
+
+// ContextWithDoer returns a copy of the context carrying the specified
+// Doer, which the helpers of the pools, such as Do, NewRequest,
+// DownloadFile or StreamSSE, use in place of their clients to send the
+// requests made with the context, for instance to stub the calls of a
+// part of the code in tests. The responses and errors of the Doer are
+// handled as those of the clients would be. The clients returned by
+// GetClient and the other getters are not affected.
+func ContextWithDoer(ctx context.Context, doer Doer) context.Context {
+	return context.WithValue(ctx, doerKey{}, doer)
+}
+
+// contextDoer returns the Doer carried by the context, if any, or the
+// client.
+func contextDoer(ctx context.Context, client *http.Client) Doer {
+	if doer, ok := ctx.Value(doerKey{}).(Doer); ok {
+		return doer
+	}
+	return client
+}
@@ -0,0 +1,89 @@
+package http_test
+
+import (
+	"context"
+	nethttp "net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// doerFunc is a Doer calling a function.
+type doerFunc func(*nethttp.Request) (*nethttp.Response, error)
+
+func (f doerFunc) Do(req *nethttp.Request) (*nethttp.Response, error) { return f(req) }
+
+func TestContextWithDoer(t *testing.T) {
+	var real, stubbed int32
+	pool := http.NewClientPool()
+	pool.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		atomic.AddInt32(&real, 1)
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+	stub := doerFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		atomic.AddInt32(&stubbed, 1)
+		return statusResponse(req, nethttp.StatusAccepted), nil
+	})
+	ctx := http.ContextWithDoer(context.Background(), stub)
+
+	for _, test := range []struct {
+		name   string
+		ctx    context.Context
+		status int
+	}{
+		{"unscoped", context.Background(), nethttp.StatusOK},
+		{"scoped", ctx, nethttp.StatusAccepted},
+	} {
+		atomic.StoreInt32(&real, 0)
+		atomic.StoreInt32(&stubbed, 0)
+
+		req, _ := nethttp.NewRequestWithContext(test.ctx, nethttp.MethodGet, "http://example.com/", nil)
+		resp, err := pool.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: Do: status = %d, want %d", test.name, resp.StatusCode, test.status)
+		}
+
+		resp, err = pool.NewRequest().URL("http://example.com/").Do(test.ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: NewRequest: status = %d, want %d", test.name, resp.StatusCode, test.status)
+		}
+
+		resp, _, err = pool.GetConditional(test.ctx, "http://example.com/", `"v1"`, time.Time{}, time.Second)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: GetConditional: status = %d, want %d", test.name, resp.StatusCode, test.status)
+		}
+
+		// The clients of the pool are never overridden.
+		req, _ = nethttp.NewRequestWithContext(test.ctx, nethttp.MethodGet, "http://example.com/", nil)
+		resp, err = pool.GetClient(time.Second).Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		resp.Body.Close()
+
+		want := int32(3)
+		if test.ctx == ctx {
+			want = 0
+		}
+		if n := atomic.LoadInt32(&real); n != want+1 {
+			t.Errorf("%s: %d requests sent by the pool, want %d", test.name, n, want+1)
+		}
+		if n := atomic.LoadInt32(&stubbed); n != 3-want {
+			t.Errorf("%s: %d requests sent by the stub, want %d", test.name, n, 3-want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// downloadAttempts is the number of times DownloadFile tries to resume
+// an interrupted download before giving up.
+const downloadAttempts = 3
+
+// DownloadFile downloads the resource at the specified URL to the file
+// dest, using a client of the pool with the specified timeout for every
+// attempt. If dest already exists, it is considered a partial download
+// and the download is resumed with a Range request, appending to the
+// file when the server answers with a 206 status code and starting over
+// when it answers with a 200 status code. Interrupted downloads are
+// resumed up to three times, using If-Range with the ETag of the
+// previous attempt so that a resource modified in the meantime is
+// downloaded again from the start.
+//
+// When the download is interrupted for good by a network error, the
+// partial file is kept so it can be resumed by a later call. When the
+// server rejects the request or answers inconsistently, the partial
+// file is removed.
+func (c *ClientPool) DownloadFile(ctx context.Context, url, dest string, timeout time.Duration) error {
+	file, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	d := download{
+		send: func(req *http.Request) (*http.Response, error) {
+			return c.sendTimeout(timeout, req, c.send)
+		},
+		url:   url,
+		file:  file,
+		total: -1,
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = d.attempt(ctx)
+		if err == nil || attempt >= downloadAttempts || ctx.Err() != nil {
+			break
+		}
+		if _, ok := err.(*downloadError); ok {
+			break
+		}
+	}
+
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if _, ok := err.(*downloadError); ok {
+		os.Remove(dest)
+	}
+	return err
+}
+
+// downloadError is an error after which a download cannot be resumed.
+type downloadError struct {
+	msg string
+}
+
+func (e *downloadError) Error() string {
+	return "http: download: " + e.msg
+}
+
+// download holds the state of a download across attempts.
+type download struct {
+	send func(*http.Request) (*http.Response, error)
+	url  string
+	file *os.File
+
+	// etag and total are the ETag and the size of the resource as
+	// reported by the previous attempts. The total is -1 if unknown.
+	etag  string
+	total int64
+}
+
+// attempt downloads the rest of the resource to the file. Network errors
+// are returned as is, so the download can be resumed.
+func (d *download) attempt(ctx context.Context) error {
+	info, err := d.file.Stat()
+	if err != nil {
+		return err
+	}
+	offset := info.Size()
+	if d.total >= 0 && offset == d.total {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+		if d.etag != "" {
+			req.Header.Set("If-Range", d.etag)
+		}
+	}
+
+	resp, err := d.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		offset = 0
+		d.etag, d.total = resp.Header.Get("ETag"), resp.ContentLength
+
+	case http.StatusPartialContent:
+		start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			return &downloadError{msg: fmt.Sprintf("unexpected Content-Range %q for offset %d", resp.Header.Get("Content-Range"), offset)}
+		}
+		etag := resp.Header.Get("ETag")
+		if (d.etag != "" && etag != d.etag) || (d.total >= 0 && total >= 0 && total != d.total) {
+			return &downloadError{msg: "resource changed while resuming"}
+		}
+		d.etag, d.total = etag, total
+
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial file may already hold the whole resource.
+		if _, total, ok := parseContentRange(resp.Header.Get("Content-Range")); ok && total == offset {
+			d.total = total
+			return nil
+		}
+		return &downloadError{msg: "partial file does not match the resource"}
+
+	default:
+		return &downloadError{msg: "unexpected status " + resp.Status}
+	}
+
+	if err := d.file.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := d.file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	written, err := io.Copy(d.file, resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if d.total >= 0 && offset+written != d.total {
+		return &downloadError{msg: fmt.Sprintf("downloaded %d bytes, want %d", offset+written, d.total)}
+	}
+	return nil
+}
+
+// parseContentRange parses a Content-Range header of the form
+// "bytes start-end/total" or "bytes */total". The total is -1 if the
+// header specifies it as unknown.
+func parseContentRange(header string) (start, total int64, ok bool) {
+	spec := strings.TrimPrefix(header, "bytes ")
+	if spec == header {
+		return 0, 0, false
+	}
+	rng, size, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+
+	total = -1
+	if size != "*" {
+		var err error
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	if rng == "*" {
+		return 0, total, true
+	}
+
+	first, _, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, total, true
+}
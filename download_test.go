@@ -0,0 +1,148 @@
+package http_test
+
+import (
+	"bytes"
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+var downloadContent = []byte(strings.Repeat("0123456789", 1000))
+
+// downloadServer serves content with its ETag, interrupting the first
+// response halfway if interrupt is set. It records the Range headers of
+// the requests.
+type downloadServer struct {
+	interrupt bool
+
+	mtx     sync.Mutex
+	content []byte
+	etag    string
+	ranges  []string
+}
+
+func (s *downloadServer) ServeHTTP(w nethttp.ResponseWriter, r *nethttp.Request) {
+	s.mtx.Lock()
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	content, etag, interrupt := s.content, s.etag, s.interrupt
+	s.interrupt = false
+	s.mtx.Unlock()
+
+	if interrupt {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Length", "10000")
+		w.Write(content[:len(content)/2])
+		w.(nethttp.Flusher).Flush()
+		panic(nethttp.ErrAbortHandler)
+	}
+
+	w.Header().Set("ETag", etag)
+	nethttp.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+}
+
+func (s *downloadServer) set(content []byte, etag string) {
+	s.mtx.Lock()
+	s.content, s.etag = content, etag
+	s.mtx.Unlock()
+}
+
+func TestDownloadFile(t *testing.T) {
+	handler := &downloadServer{content: downloadContent, etag: `"v1"`}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "file")
+	if err := http.NewClientPool().DownloadFile(context.Background(), server.URL, dest, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, dest, downloadContent)
+}
+
+func TestDownloadFileInterrupted(t *testing.T) {
+	handler := &downloadServer{content: downloadContent, etag: `"v1"`, interrupt: true}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "file")
+	if err := http.NewClientPool().DownloadFile(context.Background(), server.URL, dest, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, dest, downloadContent)
+
+	if len(handler.ranges) != 2 || handler.ranges[1] != "bytes=5000-" {
+		t.Fatalf("Range headers = %q, want a resume from byte 5000", handler.ranges)
+	}
+}
+
+func TestDownloadFileResumesPartialFile(t *testing.T) {
+	handler := &downloadServer{content: downloadContent, etag: `"v1"`}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(dest, downloadContent[:1234], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := http.NewClientPool().DownloadFile(context.Background(), server.URL, dest, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, dest, downloadContent)
+
+	if len(handler.ranges) != 1 || handler.ranges[0] != "bytes=1234-" {
+		t.Fatalf("Range headers = %q, want a resume from byte 1234", handler.ranges)
+	}
+}
+
+func TestDownloadFileRestartsOnChange(t *testing.T) {
+	handler := &downloadServer{content: downloadContent, etag: `"v1"`, interrupt: true}
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		// The resource changes once the first attempt is interrupted.
+		defer handler.set([]byte("new content"), `"v2"`)
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "file")
+	if err := http.NewClientPool().DownloadFile(context.Background(), server.URL, dest, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, dest, []byte("new content"))
+}
+
+func TestDownloadFileUnrecoverable(t *testing.T) {
+	server := httptest.NewServer(nethttp.NotFoundHandler())
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(dest, []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := http.NewClientPool().DownloadFile(context.Background(), server.URL, dest, 5*time.Second); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatalf("expected the partial file to be removed, got %v", err)
+	}
+}
+
+func assertFile(t *testing.T, path string, want []byte) {
+	t.Helper()
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("file has %d bytes, want %d matching bytes", len(got), len(want))
+	}
+}
@@ -0,0 +1,40 @@
+package http
+
+import (
+	"net/http"
+	"time"
+)
+
+// SetDraining enables or disables the drain mode of the pool. While
+// draining, GetClient keeps returning the clients already created but
+// no longer creates new ones: a timeout without a client gets the
+// existing client with the closest timeout instead. A client is only
+// created if the pool has none at all. This avoids building transports
+// while shutting down.
+func (c *ClientPool) SetDraining(draining bool) {
+	c.mtx.Lock()
+	{
+		c.draining = draining
+	}
+	c.mtx.Unlock()
+}
+
+// closestClient returns the existing client with the timeout closest to
+// the specified one, preferring the shorter timeout on ties. Must be
+// called while holding the lock.
+func (c *ClientPool) closestClient(timeout time.Duration) *http.Client {
+	var (
+		closest *http.Client
+		best    time.Duration
+	)
+	for t, client := range c.loadClients() {
+		diff := t - timeout
+		if diff < 0 {
+			diff = -diff
+		}
+		if closest == nil || diff < best || (diff == best && t < closest.Timeout) {
+			closest, best = client, diff
+		}
+	}
+	return closest
+}
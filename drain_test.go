@@ -0,0 +1,47 @@
+package http_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestDrainingReusesExisting(t *testing.T) {
+	cp := http.NewClientPool()
+	client := cp.GetClient(time.Second)
+
+	cp.SetDraining(true)
+	if cp.GetClient(time.Second) != client {
+		t.Fatal("expected the existing client while draining")
+	}
+}
+
+func TestDrainingFallsBackToClosest(t *testing.T) {
+	cp := http.NewClientPool()
+	short := cp.GetClient(time.Second)
+	long := cp.GetClient(10 * time.Second)
+
+	cp.SetDraining(true)
+	if got := cp.GetClient(2 * time.Second); got != short {
+		t.Fatalf("got client with timeout %v, want 1s", got.Timeout)
+	}
+	if got := cp.GetClient(8 * time.Second); got != long {
+		t.Fatalf("got client with timeout %v, want 10s", got.Timeout)
+	}
+
+	// No client was created for the new timeouts.
+	cp.SetDraining(false)
+	if cp.GetClient(2*time.Second).Timeout != 2*time.Second {
+		t.Fatal("expected a new client once draining stops")
+	}
+}
+
+func TestDrainingEmptyPool(t *testing.T) {
+	cp := http.NewClientPool()
+	cp.SetDraining(true)
+
+	if client := cp.GetClient(time.Second); client == nil || client.Timeout != time.Second {
+		t.Fatal("expected a client to be created for an empty pool")
+	}
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"net/http"
+	"sync"
+)
+
+// WithDuplicateRequestDetection makes the pool track the requests in
+// flight through its clients by method and URL, and call onDuplicate
+// every time a request is sent while identical requests are still in
+// flight, with the number of identical requests in flight including the
+// new one, to find redundant concurrent calls. Duplicates are only
+// reported, never coalesced. A request is in flight until its response
+// body is closed. The function is called before the request is sent,
+// from the goroutine sending it, and must be safe for concurrent use.
+// URLs are reported with their password redacted.
+func WithDuplicateRequestDetection(onDuplicate func(method, url string, count int)) Option {
+	inFlight := &inFlightRequests{
+		counts: make(map[inFlightKey]int),
+	}
+
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			return &duplicateTransport{
+				next:        next,
+				inFlight:    inFlight,
+				onDuplicate: onDuplicate,
+			}
+		})
+	}
+}
+
+// inFlightKey identifies identical requests.
+type inFlightKey struct {
+	method string
+	url    string
+}
+
+// inFlightRequests counts the requests in flight.
+type inFlightRequests struct {
+	mtx    sync.Mutex
+	counts map[inFlightKey]int
+}
+
+// add adds delta to the number of requests in flight for the key and
+// returns it.
+func (r *inFlightRequests) add(key inFlightKey, delta int) int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	n := r.counts[key] + delta
+	if n <= 0 {
+		delete(r.counts, key)
+	} else {
+		r.counts[key] = n
+	}
+	return n
+}
+
+// duplicateTransport reports the duplicate requests in flight through
+// the next transport.
+type duplicateTransport struct {
+	next        http.RoundTripper
+	inFlight    *inFlightRequests
+	onDuplicate func(method, url string, count int)
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *duplicateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	key := inFlightKey{method: method, url: req.URL.String()}
+	if n := t.inFlight.add(key, 1); n > 1 {
+		t.onDuplicate(method, req.URL.Redacted(), n)
+	}
+	release := func() { t.inFlight.add(key, -1) }
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
@@ -0,0 +1,63 @@
+package http_test
+
+import (
+	"fmt"
+	nethttp "net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestDuplicateRequestDetection(t *testing.T) {
+	var (
+		mtx        sync.Mutex
+		duplicates []string
+	)
+	pool := http.NewClientPool(http.WithDuplicateRequestDetection(func(method, url string, count int) {
+		mtx.Lock()
+		duplicates = append(duplicates, fmt.Sprintf("%s %s %d", method, url, count))
+		mtx.Unlock()
+	}))
+
+	block := make(chan struct{})
+	pool.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		<-block
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+	client := pool.GetClient(time.Second)
+
+	// Three identical requests and a distinct one are in flight at the
+	// same time.
+	var wg sync.WaitGroup
+	for _, url := range []string{"http://example.com/a", "http://example.com/a", "http://example.com/b", "http://example.com/a"} {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			resp, err := client.Get(url)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}(url)
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(block)
+	wg.Wait()
+
+	// Once completed, the requests are no longer duplicates.
+	resp, err := client.Get("http://example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	sort.Strings(duplicates)
+	want := "[GET http://example.com/a 2 GET http://example.com/a 3]"
+	if got := fmt.Sprint(duplicates); got != want {
+		t.Errorf("duplicates = %s, want %s", got, want)
+	}
+}
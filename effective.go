@@ -0,0 +1,28 @@
+package http
+
+import (
+	"net/http"
+	"time"
+)
+
+// EffectiveTransport returns the transport used by the client returned
+// by GetClient for the specified timeout, creating the client if needed.
+// The transport is returned as it was set in the pool or created by it,
+// without the middleware of the pool, so that its settings can be
+// inspected. It is a *http.Transport unless a transport of another type
+// was set.
+func (c *ClientPool) EffectiveTransport(timeout time.Duration) http.RoundTripper {
+	for {
+		client := c.GetClient(timeout)
+
+		c.mtx.RLock()
+		base, ok := c.bases[client]
+		c.mtx.RUnlock()
+
+		// The clients may have been reset since the client was
+		// returned, in which case a new one is requested.
+		if ok {
+			return base
+		}
+	}
+}
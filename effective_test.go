@@ -0,0 +1,40 @@
+package http_test
+
+import (
+	nethttp "net/http"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestEffectiveTransport(t *testing.T) {
+	cp := http.NewClientPool(http.WithRetry(3, time.Millisecond))
+	cp.SetTransport(&nethttp.Transport{
+		MaxIdleConnsPerHost: 7,
+		MaxConnsPerHost:     3,
+	})
+
+	transport, ok := cp.EffectiveTransport(time.Second).(*nethttp.Transport)
+	if !ok {
+		t.Fatalf("got %T, want *http.Transport", cp.EffectiveTransport(time.Second))
+	}
+	if transport.MaxIdleConnsPerHost != 7 || transport.MaxConnsPerHost != 3 {
+		t.Fatalf("got limits %d/%d, want 7/3", transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost)
+	}
+	if _, ok := cp.GetClient(time.Second).Transport.(*nethttp.Transport); ok {
+		t.Fatal("expected the client transport to be wrapped by the retries")
+	}
+}
+
+func TestEffectiveTransportDefault(t *testing.T) {
+	cp := http.NewClientPool()
+
+	transport, ok := cp.EffectiveTransport(time.Second).(*nethttp.Transport)
+	if !ok {
+		t.Fatalf("got %T, want *http.Transport", cp.EffectiveTransport(time.Second))
+	}
+	if transport.TLSHandshakeTimeout != 10*time.Second {
+		t.Fatalf("TLSHandshakeTimeout = %v, want 10s", transport.TLSHandshakeTimeout)
+	}
+}
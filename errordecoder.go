@@ -0,0 +1,42 @@
+package http
+
+import (
+	"net/http"
+)
+
+// SetErrorDecoder sets the function turning the responses with a non-2xx
+// status code received by Do and the other helpers of the pool sending
+// requests into errors, for instance to parse the structured errors
+// returned by an API. GetConditional, DownloadFile and StreamSSE, which
+// handle the status codes they receive themselves, do not use it. When the decoder returns an error, the helper
+// returns it instead of the response, and the decoder is responsible for
+// reading and closing the response body. When it returns nil, the
+// response is returned as is. If nil, which is the default, non-2xx
+// responses are returned as is.
+func (c *ClientPool) SetErrorDecoder(fn func(*http.Response) error) {
+	c.mtx.Lock()
+	{
+		c.errorDecoder = fn
+	}
+	c.mtx.Unlock()
+}
+
+// decodeError returns the error decoded from a non-2xx response, if any,
+// or the response.
+func (c *ClientPool) decodeError(resp *http.Response) (*http.Response, error) {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	c.mtx.RLock()
+	decode := c.errorDecoder
+	c.mtx.RUnlock()
+
+	if decode == nil {
+		return resp, nil
+	}
+	if err := decode(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
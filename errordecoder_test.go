@@ -0,0 +1,92 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// apiError is the structured error returned by the API.
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func decodeAPIError(resp *nethttp.Response) error {
+	defer resp.Body.Close()
+
+	apiErr := &apiError{}
+	if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil {
+		return err
+	}
+	return apiErr
+}
+
+func TestErrorDecoder(t *testing.T) {
+	cp := http.NewClientPool()
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		if req.URL.Path == "/missing" {
+			resp := statusResponse(req, nethttp.StatusNotFound)
+			resp.Body = io.NopCloser(strings.NewReader(`{"code":"not_found","message":"no such item"}`))
+			return resp, nil
+		}
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/missing", nil)
+	resp, err := cp.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != nethttp.StatusNotFound {
+		t.Fatalf("status = %d without a decoder, want 404", resp.StatusCode)
+	}
+
+	cp.SetErrorDecoder(decodeAPIError)
+
+	_, err = cp.Do(req)
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("error = %v, want an *apiError", err)
+	}
+	if apiErr.Code != "not_found" || apiErr.Message != "no such item" {
+		t.Fatalf("got %+v", apiErr)
+	}
+
+	ok, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/ok", nil)
+	resp, err = cp.Do(ok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestErrorDecoderPostMultipart(t *testing.T) {
+	errDecoded := errors.New("decoded")
+	cp := http.NewClientPool()
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		return statusResponse(req, nethttp.StatusBadRequest), nil
+	}))
+	cp.SetErrorDecoder(func(resp *nethttp.Response) error {
+		resp.Body.Close()
+		return errDecoded
+	})
+
+	_, err := cp.PostMultipart(context.Background(), "http://example.com/", map[string]string{"a": "b"}, nil, time.Second)
+	if !errors.Is(err, errDecoded) {
+		t.Errorf("error = %v, want %v", err, errDecoded)
+	}
+}
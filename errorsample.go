@@ -0,0 +1,104 @@
+package http
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrorSample describes a request that failed with a transport error,
+// as reported by RecentErrors.
+type ErrorSample struct {
+	Host  string
+	Time  time.Time
+	Error string
+}
+
+// WithErrorSampling makes the pool keep the last n transport errors of
+// the requests sent by its clients, see RecentErrors. Every attempt of a
+// retried request is sampled.
+func WithErrorSampling(n int) Option {
+	return func(c *ClientPool) {
+		c.errorSamples = newErrorSamples(n)
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+}
+
+// RecentErrors returns the last transport errors of the requests sent by
+// the clients of the pool, the oldest first. It returns nil if the pool
+// was not created with WithErrorSampling.
+func (c *ClientPool) RecentErrors() []ErrorSample {
+	c.mtx.RLock()
+	s := c.errorSamples
+	c.mtx.RUnlock()
+
+	if s == nil {
+		return nil
+	}
+	return s.snapshot()
+}
+
+// errorSamples is a ring buffer of the last transport errors.
+type errorSamples struct {
+	mtx     sync.Mutex
+	samples []ErrorSample
+	count   int
+	next    int
+}
+
+// newErrorSamples returns a ring buffer of n errors.
+func newErrorSamples(n int) *errorSamples {
+	if n < 1 {
+		n = 1
+	}
+	return &errorSamples{samples: make([]ErrorSample, n)}
+}
+
+// add records an error, replacing the oldest one if the buffer is full.
+func (s *errorSamples) add(sample ErrorSample) {
+	s.mtx.Lock()
+	{
+		s.samples[s.next] = sample
+		s.next = (s.next + 1) % len(s.samples)
+		if s.count < len(s.samples) {
+			s.count++
+		}
+	}
+	s.mtx.Unlock()
+}
+
+// snapshot returns a copy of the errors, the oldest first.
+func (s *errorSamples) snapshot() []ErrorSample {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	samples := make([]ErrorSample, 0, s.count)
+	start := (s.next - s.count + len(s.samples)) % len(s.samples)
+	for i := 0; i < s.count; i++ {
+		samples = append(samples, s.samples[(start+i)%len(s.samples)])
+	}
+	return samples
+}
+
+// errorSamplingTransport samples the errors of the requests sent through
+// the next transport.
+type errorSamplingTransport struct {
+	next    http.RoundTripper
+	samples *errorSamples
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *errorSamplingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.samples.add(ErrorSample{
+			Host:  req.URL.Host,
+			Time:  time.Now(),
+			Error: err.Error(),
+		})
+	}
+	return resp, err
+}
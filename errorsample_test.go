@@ -0,0 +1,52 @@
+package http_test
+
+import (
+	"fmt"
+	nethttp "net/http"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestErrorSampling(t *testing.T) {
+	pool := http.NewClientPool(http.WithErrorSampling(3))
+	pool.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		if req.URL.Path == "/" {
+			return statusResponse(req, nethttp.StatusOK), nil
+		}
+		return nil, fmt.Errorf("failure %s", req.URL.Path)
+	}))
+	client := pool.GetClient(time.Second)
+
+	if samples := pool.RecentErrors(); len(samples) != 0 {
+		t.Errorf("got samples %v before any error", samples)
+	}
+
+	start := time.Now()
+	for _, url := range []string{"http://a.example.com/1", "http://a.example.com/", "http://b.example.com/2", "http://a.example.com/3", "http://b.example.com/4", "http://c.example.com/5"} {
+		if resp, err := client.Get(url); err == nil {
+			resp.Body.Close()
+		}
+	}
+
+	// The last 3 errors are kept, the oldest first.
+	samples := pool.RecentErrors()
+	want := []http.ErrorSample{
+		{Host: "a.example.com", Error: "failure /3"},
+		{Host: "b.example.com", Error: "failure /4"},
+		{Host: "c.example.com", Error: "failure /5"},
+	}
+	if len(samples) != len(want) {
+		t.Fatalf("got %d samples, want %d", len(samples), len(want))
+	}
+	for i, sample := range samples {
+		if sample.Host != want[i].Host || sample.Error != want[i].Error || sample.Time.Before(start) {
+			t.Errorf("sample %d = %+v, want %+v", i, sample, want[i])
+		}
+	}
+
+	if samples := http.NewClientPool().RecentErrors(); samples != nil {
+		t.Errorf("samples = %v, want nil when disabled", samples)
+	}
+}
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// SetDialContext replaces the function establishing the connections of
+// the default transport and returns a function restoring it.
+func SetDialContext(fn func(d *net.Dialer, ctx context.Context, network, addr string) (net.Conn, error)) (restore func()) {
+	previous := dialContext
+	dialContext = fn
+	return func() { dialContext = previous }
+}
+
+// SetAdaptiveCompressionReset replaces the duration after which
+// compression is requested again from a host, and returns a function
+// restoring it.
+func SetAdaptiveCompressionReset(d time.Duration) (restore func()) {
+	previous := adaptiveCompressionReset
+	adaptiveCompressionReset = d
+	return func() { adaptiveCompressionReset = previous }
+}
+
+// SetLookupIPAddr replaces the function resolving the hosts pinned with
+// PinHost and returns a function restoring it.
+func SetLookupIPAddr(fn func(ctx context.Context, host string) ([]net.IPAddr, error)) (restore func()) {
+	previous := lookupIPAddr
+	lookupIPAddr = fn
+	return func() { lookupIPAddr = previous }
+}
+
+// Clock provides the time to a pool, see WithClock.
+type Clock = clock
+
+// WithClock makes the pool use the specified clock in place of the clock
+// of the time package.
+func WithClock(clk Clock) Option {
+	return func(c *ClientPool) {
+		c.clock = clk
+		c.resetClients()
+	}
+}
+
+// FakeClock is a Clock whose time only passes when advanced.
+type FakeClock struct {
+	mtx    sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock returns a new FakeClock set to the specified time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) timer {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	t := &fakeTimer{clock: c, at: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the time of the clock forward, firing the timers that
+// expire in the meantime.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.c <- c.now
+	}
+	c.timers = pending
+}
+
+// Timers returns the number of timers of the clock that have not fired
+// nor been stopped yet.
+func (c *FakeClock) Timers() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return len(c.timers)
+}
+
+// fakeTimer is a timer of a FakeClock.
+type fakeTimer struct {
+	clock *FakeClock
+	at    time.Time
+	c     chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mtx.Lock()
+	defer t.clock.mtx.Unlock()
+
+	for i, pending := range t.clock.timers {
+		if pending == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// SetMaxPages replaces the number of pages followed by GetAllPages and
+// returns a function restoring it.
+func SetMaxPages(n int) (restore func()) {
+	previous := maxPages
+	maxPages = n
+	return func() { maxPages = previous }
+}
+
+// SetFastFailMaxHosts replaces the number of hosts remembered by
+// WithFirstRequestFastFail and returns a function restoring it.
+func SetFastFailMaxHosts(n int) (restore func()) {
+	previous := fastFailMaxHosts
+	fastFailMaxHosts = n
+	return func() { fastFailMaxHosts = previous }
+}
+
+// SetAffinityMaxKeys replaces the number of affinity keys whose
+// transports are kept and returns a function restoring it.
+func SetAffinityMaxKeys(n int) (restore func()) {
+	previous := affinityMaxKeys
+	affinityMaxKeys = n
+	return func() { affinityMaxKeys = previous }
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// dialContext establishes the connections of the default transport with
+// the specified dialer. It is a variable so that tests can observe the
+// dials.
+var dialContext = func(d *net.Dialer, ctx context.Context, network, addr string) (net.Conn, error) {
+	return d.DialContext(ctx, network, addr)
+}
+
+// fastFailMaxHosts is the number of hosts remembered as already dialed
+// by WithFirstRequestFastFail, the oldest being forgotten first.
+var fastFailMaxHosts = 4096
+
+// WithFirstRequestFastFail makes the default transport use a dial
+// timeout of d instead of the usual 30 seconds for the connections to
+// the hosts it has not connected to yet, so that a misconfigured
+// endpoint is reported quickly on cold start. Once a host has been
+// dialed, whether the dial succeeded or not, the later dials to it use
+// the normal timeout, so that a host slower to connect to than d can
+// still be reached. Up to 4096 hosts are remembered. Transports set with
+// SetTransport are not affected.
+func WithFirstRequestFastFail(d time.Duration) Option {
+	ff := &fastFail{
+		timeout: d,
+		hosts:   make(map[string]bool),
+	}
+
+	return func(c *ClientPool) {
+		c.fastFail = ff
+		c.resetClients()
+	}
+}
+
+// fastFail records the hosts that were dialed, in the order of their
+// first dial.
+type fastFail struct {
+	timeout time.Duration
+
+	mtx   sync.Mutex
+	hosts map[string]bool
+	order []string
+	next  int
+}
+
+func (f *fastFail) known(addr string) bool {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	return f.hosts[addr]
+}
+
+// add records the host, forgetting the oldest one if too many hosts are
+// recorded.
+func (f *fastFail) add(addr string) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if f.hosts[addr] {
+		return
+	}
+	if len(f.order) < fastFailMaxHosts {
+		f.order = append(f.order, addr)
+	} else {
+		delete(f.hosts, f.order[f.next])
+		f.order[f.next] = addr
+		f.next = (f.next + 1) % len(f.order)
+	}
+	f.hosts[addr] = true
+}
+
+// dialFunc returns the dial function of the default transport. Must be
+// called while holding the lock.
+func (c *ClientPool) dialFunc(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	ff := c.fastFail
+	if ff == nil {
+		return func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialContext(dialer, ctx, network, addr)
+		}
+	}
+
+	fast := *dialer
+	fast.Timeout = ff.timeout
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if ff.known(addr) {
+			return dialContext(dialer, ctx, network, addr)
+		}
+
+		conn, err := dialContext(&fast, ctx, network, addr)
+		ff.add(addr)
+		return conn, err
+	}
+}
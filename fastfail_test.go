@@ -0,0 +1,102 @@
+package http_test
+
+import (
+	"context"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestWithFirstRequestFastFail(t *testing.T) {
+	var mtx sync.Mutex
+	timeouts := make(map[string][]time.Duration)
+	defer http.SetDialContext(func(d *net.Dialer, ctx context.Context, network, addr string) (net.Conn, error) {
+		mtx.Lock()
+		timeouts[addr] = append(timeouts[addr], d.Timeout)
+		mtx.Unlock()
+		return d.DialContext(ctx, network, addr)
+	})()
+
+	handler := nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {})
+	first := httptest.NewServer(handler)
+	defer first.Close()
+	second := httptest.NewServer(handler)
+	defer second.Close()
+
+	cp := http.NewClientPool(http.WithFirstRequestFastFail(50 * time.Millisecond))
+	cp.SetProxy(func(*nethttp.Request) (*url.URL, error) { return nil, nil })
+	client := cp.GetClient(time.Second)
+
+	for _, url := range []string{first.URL, first.URL, second.URL} {
+		req, _ := nethttp.NewRequest(nethttp.MethodGet, url, nil)
+
+		// Every request opens a new connection.
+		req.Close = true
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	firstTimeouts := timeouts[first.Listener.Addr().String()]
+	if len(firstTimeouts) != 2 || firstTimeouts[0] != 50*time.Millisecond || firstTimeouts[1] != 30*time.Second {
+		t.Fatalf("dials to the first host used %v, want [50ms 30s]", firstTimeouts)
+	}
+	secondTimeouts := timeouts[second.Listener.Addr().String()]
+	if len(secondTimeouts) != 1 || secondTimeouts[0] != 50*time.Millisecond {
+		t.Fatalf("dials to the second host used %v, want [50ms]", secondTimeouts)
+	}
+}
+
+func TestWithFirstRequestFastFailSlowHost(t *testing.T) {
+	var used []time.Duration
+	defer http.SetDialContext(func(d *net.Dialer, ctx context.Context, network, addr string) (net.Conn, error) {
+		used = append(used, d.Timeout)
+		return nil, &net.OpError{Op: "dial", Net: network, Err: context.DeadlineExceeded}
+	})()
+
+	cp := http.NewClientPool(http.WithFirstRequestFastFail(50 * time.Millisecond))
+	cp.SetProxy(func(*nethttp.Request) (*url.URL, error) { return nil, nil })
+	for i := 0; i < 2; i++ {
+		if _, err := cp.GetClient(time.Second).Get("http://unreachable.invalid/"); err == nil {
+			t.Fatal("expected the dial to fail")
+		}
+	}
+	if len(used) != 2 || used[0] != 50*time.Millisecond || used[1] != 30*time.Second {
+		t.Fatalf("dials used %v, want the normal timeout once the host was dialed", used)
+	}
+}
+
+func TestWithFirstRequestFastFailMaxHosts(t *testing.T) {
+	defer http.SetFastFailMaxHosts(2)()
+
+	used := make(map[string][]time.Duration)
+	defer http.SetDialContext(func(d *net.Dialer, ctx context.Context, network, addr string) (net.Conn, error) {
+		used[addr] = append(used[addr], d.Timeout)
+		return nil, &net.OpError{Op: "dial", Net: network, Err: context.DeadlineExceeded}
+	})()
+
+	cp := http.NewClientPool(http.WithFirstRequestFastFail(50 * time.Millisecond))
+	cp.SetProxy(func(*nethttp.Request) (*url.URL, error) { return nil, nil })
+
+	// The first host is forgotten once the third one is dialed.
+	for _, host := range []string{"a", "b", "c", "a", "c"} {
+		cp.GetClient(time.Second).Get("http://" + host + ".invalid/")
+	}
+	if got := used["a.invalid:80"]; len(got) != 2 || got[1] != 50*time.Millisecond {
+		t.Errorf("dials to the forgotten host used %v, want [50ms 50ms]", got)
+	}
+	if got := used["c.invalid:80"]; len(got) != 2 || got[1] != 30*time.Second {
+		t.Errorf("dials to the remembered host used %v, want [50ms 30s]", got)
+	}
+}
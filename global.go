@@ -0,0 +1,56 @@
+package http
+
+import (
+	"net/http"
+	"sync"
+)
+
+var (
+	globalMtx       sync.RWMutex
+	globalTransport http.RoundTripper
+)
+
+// SetGlobalDefaultTransport sets a transport shared by every pool that
+// has no transport of its own, instead of each pool building its own
+// default transport. Sharing a transport shares its connection pool,
+// which avoids fragmenting connections and file descriptors across
+// pools, at the cost of the pools no longer being isolated from each
+// other: a burst of requests in one pool can exhaust the connections
+// used by the others. If nil, the pools build their own default
+// transport.
+//
+// Pools with settings of their own default transport keep building it,
+// since the global transport would ignore them: a default TLS
+// configuration or profile, a TLS session cache, pinned public keys, a
+// client certificate selector, a proxy or its credentials, a SOCKS5
+// proxy, a jump host, a dial limit, a preference for IPv6, fast-failing
+// first dials or the reuse of the least recently used connections.
+//
+// Only clients created after this call use the new transport, so it
+// should be set before any client is requested from the pools.
+func SetGlobalDefaultTransport(transport http.RoundTripper) {
+	globalMtx.Lock()
+	{
+		globalTransport = transport
+	}
+	globalMtx.Unlock()
+}
+
+// customizesDefaultTransport reports whether the pool has settings of its
+// own default transport, which the global transport does not honor.
+// Must be called while holding the lock.
+func (c *ClientPool) customizesDefaultTransport() bool {
+	return c.tlsConfig != nil || c.sessionCache != nil || len(c.pins) > 0 ||
+		c.certSelector != nil || c.proxy != nil || c.proxyAuth != nil ||
+		c.socks5 != nil || c.jumpHost != nil || c.dialSlots != nil ||
+		c.ipv6Fallback > 0 || c.fastFail != nil || c.lruReuse
+}
+
+// globalDefaultTransport returns the transport set by
+// SetGlobalDefaultTransport, if any.
+func globalDefaultTransport() http.RoundTripper {
+	globalMtx.RLock()
+	defer globalMtx.RUnlock()
+
+	return globalTransport
+}
@@ -0,0 +1,75 @@
+package http_test
+
+import (
+	"crypto/tls"
+	nethttp "net/http"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestGlobalDefaultTransport(t *testing.T) {
+	shared := &nethttp.Transport{}
+	http.SetGlobalDefaultTransport(shared)
+	defer http.SetGlobalDefaultTransport(nil)
+
+	first := http.NewClientPool().GetClient(time.Second)
+	second := http.NewClientPool().GetClient(2 * time.Second)
+
+	if first.Transport != shared || second.Transport != shared {
+		t.Fatal("expected both pools to use the global transport")
+	}
+}
+
+func TestGlobalDefaultTransportOwnTransport(t *testing.T) {
+	http.SetGlobalDefaultTransport(&nethttp.Transport{})
+	defer http.SetGlobalDefaultTransport(nil)
+
+	own := &nethttp.Transport{}
+	cp := http.NewClientPool()
+	cp.SetTransport(own)
+
+	if cp.GetClient(time.Second).Transport != own {
+		t.Fatal("expected the pool transport to take precedence")
+	}
+}
+
+func TestGlobalDefaultTransportUnset(t *testing.T) {
+	first := http.NewClientPool().GetClient(time.Second)
+	second := http.NewClientPool().GetClient(time.Second)
+
+	if first.Transport == second.Transport {
+		t.Fatal("expected pools to build their own transports")
+	}
+}
+
+func TestGlobalDefaultTransportPoolSettings(t *testing.T) {
+	shared := &nethttp.Transport{}
+	http.SetGlobalDefaultTransport(shared)
+	defer http.SetGlobalDefaultTransport(nil)
+
+	tests := []struct {
+		name  string
+		opts  []http.Option
+		setup func(*http.ClientPool)
+	}{
+		{name: "TLS config", setup: func(cp *http.ClientPool) { cp.SetDefaultTLSConfig(&tls.Config{}) }},
+		{name: "pins", setup: func(cp *http.ClientPool) { cp.SetPinnedPublicKeys("example.com", [][]byte{{1}}) }},
+		{name: "proxy", setup: func(cp *http.ClientPool) { cp.SetProxy(nethttp.ProxyFromEnvironment) }},
+		{name: "SOCKS5 proxy", setup: func(cp *http.ClientPool) { cp.SetSOCKS5Proxy("127.0.0.1:1080", nil) }},
+		{name: "dial limit", setup: func(cp *http.ClientPool) { cp.SetMaxConcurrentDials(1) }},
+		{name: "fast fail", opts: []http.Option{http.WithFirstRequestFastFail(time.Second)}},
+		{name: "LRU reuse", opts: []http.Option{http.WithLRUConnReuse()}},
+		{name: "IPv6 preference", opts: []http.Option{http.WithIPv6PreferredFallback(time.Second)}},
+	}
+	for _, tt := range tests {
+		cp := http.NewClientPool(tt.opts...)
+		if tt.setup != nil {
+			tt.setup(cp)
+		}
+		if cp.GetClient(time.Second).Transport == shared {
+			t.Errorf("%s: pool used the global transport", tt.name)
+		}
+	}
+}
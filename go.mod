@@ -1,3 +1,11 @@
 module github.com/Updater/http
 
 go 1.18
+
+require (
+	github.com/klauspost/compress v1.16.7
+	golang.org/x/net v0.35.0
+	golang.org/x/oauth2 v0.26.0
+)
+
+require golang.org/x/text v0.22.0 // indirect
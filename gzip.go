@@ -0,0 +1,101 @@
+package http
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// gzipReaders holds the gzip readers that are not used by any body.
+var gzipReaders sync.Pool
+
+// WithPooledGzip makes the clients of the pool request gzip-compressed
+// responses and decompress them with gzip readers reused across
+// responses, instead of allocating a new reader for every response as
+// the core http package does. As with the core http package, requests
+// that already carry an Accept-Encoding header are sent as is and their
+// responses are not decompressed.
+//
+// A reader is returned to the pool once the body using it is closed.
+func WithPooledGzip() Option {
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			return &gzipTransport{next: next}
+		})
+	}
+}
+
+// gzipTransport decompresses the responses of the next transport.
+type gzipTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *gzipTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept-Encoding") != "" || req.Header.Get("Range") != "" || req.Method == http.MethodHead {
+		return t.next.RoundTrip(req)
+	}
+
+	greq := req.Clone(req.Context())
+	greq.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := t.next.RoundTrip(greq)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+		resp.Body = &gzipBody{body: resp.Body}
+	}
+	return resp, nil
+}
+
+// gzipBody decompresses a response body with a pooled reader, acquired
+// on the first read. The reader is guarded by the mutex, since the body
+// can be closed while being read.
+type gzipBody struct {
+	body io.ReadCloser
+
+	mtx    sync.Mutex
+	reader *gzip.Reader
+	err    error
+}
+
+func (b *gzipBody) Read(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.reader == nil && b.err == nil {
+		if zr, ok := gzipReaders.Get().(*gzip.Reader); ok {
+			b.reader, b.err = zr, zr.Reset(b.body)
+		} else {
+			b.reader, b.err = gzip.NewReader(b.body)
+		}
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.reader.Read(p)
+}
+
+// Close closes the body and returns its reader to the pool, once a read
+// in progress, which fails with the closed body, returns.
+func (b *gzipBody) Close() error {
+	err := b.body.Close()
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.reader != nil {
+		gzipReaders.Put(b.reader)
+		b.reader = nil
+	}
+	b.err = http.ErrBodyReadAfterClose
+	return err
+}
@@ -0,0 +1,205 @@
+package http_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// gzipTransport answers every request with its path, gzip-compressed if
+// the request accepts it.
+var gzipTransport = roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+	resp := statusResponse(req, nethttp.StatusOK)
+	payload := strings.Repeat(req.URL.Path, 100)
+	if req.Header.Get("Accept-Encoding") != "gzip" {
+		resp.Body = io.NopCloser(strings.NewReader(payload))
+		return resp, nil
+	}
+
+	compressed := gzipped(payload)
+	resp.Header.Set("Content-Encoding", "gzip")
+	resp.Body = io.NopCloser(bytes.NewReader(compressed))
+	resp.ContentLength = int64(len(compressed))
+	return resp, nil
+})
+
+// gzipped returns the payload compressed with gzip.
+func gzipped(payload string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(payload))
+	zw.Close()
+	return buf.Bytes()
+}
+
+// compressedTransport answers every request with the same payload,
+// compressed with gzip.
+func compressedTransport() nethttp.RoundTripper {
+	compressed := gzipped(strings.Repeat("benchmark", 1000))
+
+	return roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		resp := statusResponse(req, nethttp.StatusOK)
+		resp.Header.Set("Content-Encoding", "gzip")
+		resp.Body = io.NopCloser(bytes.NewReader(compressed))
+		resp.ContentLength = int64(len(compressed))
+		return resp, nil
+	})
+}
+
+func TestPooledGzip(t *testing.T) {
+	cp := http.NewClientPool(http.WithPooledGzip())
+	cp.SetTransport(gzipTransport)
+	client := cp.GetClient(time.Second)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			path := fmt.Sprintf("/%d", i)
+			for j := 0; j < 20; j++ {
+				resp, err := client.Get("http://example.com" + path)
+				if err != nil {
+					errs <- err
+					return
+				}
+				body, err := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err != nil {
+					errs <- err
+					return
+				}
+				if string(body) != strings.Repeat(path, 100) || !resp.Uncompressed {
+					errs <- fmt.Errorf("got body %.20q for %s", body, path)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestPooledGzipCallerEncoding(t *testing.T) {
+	cp := http.NewClientPool(http.WithPooledGzip())
+	cp.SetTransport(gzipTransport)
+
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/raw", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := cp.GetClient(time.Second).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Encoding") != "gzip" || resp.Uncompressed {
+		t.Fatal("expected the response to be left compressed for the caller")
+	}
+}
+
+func benchmarkGzip(b *testing.B, client *nethttp.Client, decompress bool) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/bench", nil)
+		if decompress {
+			req.Header.Set("Accept-Encoding", "gzip")
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		body := io.Reader(resp.Body)
+		if decompress {
+			zr, err := gzip.NewReader(resp.Body)
+			if err != nil {
+				b.Fatal(err)
+			}
+			body = zr
+		}
+		io.Copy(io.Discard, body)
+		resp.Body.Close()
+	}
+}
+
+func BenchmarkGzipNewReader(b *testing.B) {
+	cp := http.NewClientPool()
+	cp.SetTransport(compressedTransport())
+	benchmarkGzip(b, cp.GetClient(time.Second), true)
+}
+
+func BenchmarkPooledGzip(b *testing.B) {
+	cp := http.NewClientPool(http.WithPooledGzip())
+	cp.SetTransport(compressedTransport())
+	benchmarkGzip(b, cp.GetClient(time.Second), false)
+}
+
+// stalledTransport answers with the compressed payload, the first
+// response stalling halfway through its body until it is closed.
+func stalledTransport(encoding string, compressed []byte) nethttp.RoundTripper {
+	var mtx sync.Mutex
+	first := true
+
+	return roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		resp := statusResponse(req, nethttp.StatusOK)
+		resp.Header.Set("Content-Encoding", encoding)
+		resp.Body = io.NopCloser(bytes.NewReader(compressed))
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		if first {
+			first = false
+			pr, pw := io.Pipe()
+			go pw.Write(compressed[:len(compressed)/2])
+			resp.Body = pr
+		}
+		return resp, nil
+	})
+}
+
+// testCloseWhileReading closes the first response while it is being
+// read, and checks that the next response is decompressed correctly.
+func testCloseWhileReading(t *testing.T, client *nethttp.Client, payload string) {
+	resp, err := client.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		io.Copy(io.Discard, resp.Body)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	resp.Body.Close()
+	<-read
+
+	got, err := getString(client, "http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != payload {
+		t.Fatalf("got %d bytes, want the %d bytes of the payload", len(got), len(payload))
+	}
+}
+
+func TestPooledGzipCloseWhileReading(t *testing.T) {
+	payload := strings.Repeat("gzip payload ", 10000)
+	cp := http.NewClientPool(http.WithPooledGzip())
+	cp.SetTransport(stalledTransport("gzip", gzipped(payload)))
+
+	testCloseWhileReading(t, cp.GetClient(time.Second), payload)
+}
@@ -0,0 +1,89 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// HeaderTimeoutError is returned when the response headers of a request
+// are not received within the time remaining until the deadline of its
+// context, see WithDeadlinePropagation.
+type HeaderTimeoutError struct {
+	// After is the time the headers were waited for.
+	After time.Duration
+}
+
+func (e *HeaderTimeoutError) Error() string {
+	return fmt.Sprintf("http: timeout awaiting response headers after %v", e.After)
+}
+
+// Timeout reports that the error is a timeout, as for net.Error.
+func (e *HeaderTimeoutError) Timeout() bool {
+	return true
+}
+
+// Unwrap returns context.DeadlineExceeded, since the headers were not
+// received before the deadline of the context.
+func (e *HeaderTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// WithDeadlinePropagation makes the clients of the pool bound the time
+// waited for the response headers of the requests whose context has a
+// deadline by the time remaining until that deadline, when it is shorter
+// than the ResponseHeaderTimeout of the transport. Requests whose headers
+// are not received in time fail with a *HeaderTimeoutError, so that
+// header stalls are told apart from the other failures caused by the
+// deadline. Once the headers are received, reading the body is only
+// bounded by the context and the client timeout, as usual.
+func WithDeadlinePropagation() Option {
+	return func(c *ClientPool) {
+		c.deadlinePropagation = true
+		c.resetClients()
+	}
+}
+
+// headerDeadlineTransport bounds the time waited for the response
+// headers of the next transport by the deadline of the requests.
+type headerDeadlineTransport struct {
+	next http.RoundTripper
+
+	// static is the ResponseHeaderTimeout of the transport, enforced by
+	// the transport itself. Zero means no timeout.
+	static time.Duration
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *headerDeadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+	remaining := time.Until(deadline)
+	if t.static > 0 && t.static <= remaining {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	var expired int32
+	timer := time.AfterFunc(remaining, func() {
+		atomic.StoreInt32(&expired, 1)
+		cancel()
+	})
+
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	timer.Stop()
+	if err != nil {
+		cancel()
+		if atomic.LoadInt32(&expired) == 1 || req.Context().Err() == context.DeadlineExceeded {
+			return nil, &HeaderTimeoutError{After: remaining}
+		}
+		return nil, err
+	}
+
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
@@ -0,0 +1,67 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestDeadlinePropagation(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/stall" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		}
+
+		// The body is sent some time after the headers.
+		w.WriteHeader(nethttp.StatusOK)
+		w.(nethttp.Flusher).Flush()
+		time.Sleep(150 * time.Millisecond)
+		io.WriteString(w, "body")
+	}))
+	defer server.Close()
+	defer close(release)
+
+	cp := http.NewClientPool(http.WithDeadlinePropagation())
+	cp.SetTransport(&nethttp.Transport{ResponseHeaderTimeout: 5 * time.Second})
+	client := cp.GetClient(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, server.URL+"/stall", nil)
+
+	start := time.Now()
+	_, err := client.Do(req)
+	var headerErr *http.HeaderTimeoutError
+	if !errors.As(err, &headerErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want a *HeaderTimeoutError", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("request failed after %v, want about 100ms", elapsed)
+	}
+
+	// Once the headers are received, the body is only bounded by the
+	// context.
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, _ = nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, server.URL+"/slow-body", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != "body" {
+		t.Fatalf("read %q, %v, want the body", body, err)
+	}
+}
@@ -0,0 +1,171 @@
+package http
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// latencyBucketMin is the upper bound of the first latency bucket,
+	// and latencyBucketGrowth the ratio between the bounds of two
+	// consecutive buckets, keeping the error of the reported
+	// percentiles under 10%.
+	latencyBucketMin    = 100 * time.Microsecond
+	latencyBucketGrowth = 1.2
+
+	// latencyBuckets is the number of latency buckets, the last one
+	// holding the latencies above about 3 minutes.
+	latencyBuckets = 80
+)
+
+// HostMetrics holds the metrics of the requests sent to a host, as
+// reported by HostMetricsSnapshot.
+type HostMetrics struct {
+	// Requests is the number of requests sent to the host, and Errors
+	// the number of those that failed or received a 5xx status code.
+	Requests uint64
+	Errors   uint64
+
+	// P50 and P95 are the median and the 95th percentile of the time
+	// taken by the requests to receive the response headers.
+	P50 time.Duration
+	P95 time.Duration
+}
+
+// WithHostMetrics makes the pool aggregate the metrics of the requests
+// sent by its clients, per host, see HostMetricsSnapshot. Every attempt
+// of a retried request counts as a request.
+func WithHostMetrics() Option {
+	return func(c *ClientPool) {
+		c.hostMetrics = &hostMetrics{
+			hosts: make(map[string]*latencyHistogram),
+		}
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+}
+
+// HostMetricsSnapshot returns the metrics of the requests sent by the
+// clients of the pool, by host of the request URLs, port included if
+// specified. It returns nil if the pool was not created with
+// WithHostMetrics.
+//
+// The percentiles are estimated from a histogram of the latencies and
+// are approximate, within 10% of the exact values.
+func (c *ClientPool) HostMetricsSnapshot() map[string]HostMetrics {
+	c.mtx.RLock()
+	m := c.hostMetrics
+	c.mtx.RUnlock()
+
+	if m == nil {
+		return nil
+	}
+	return m.snapshot()
+}
+
+// latencyHistogram counts the requests to a host by latency bucket.
+type latencyHistogram struct {
+	requests uint64
+	errors   uint64
+	buckets  [latencyBuckets]uint64
+}
+
+// latencyBucket returns the bucket of the latency.
+func latencyBucket(d time.Duration) int {
+	if d <= latencyBucketMin {
+		return 0
+	}
+	i := int(math.Ceil(math.Log(float64(d)/float64(latencyBucketMin)) / math.Log(latencyBucketGrowth)))
+	if i >= latencyBuckets {
+		i = latencyBuckets - 1
+	}
+	return i
+}
+
+// percentile returns an estimate of the percentile of the latencies,
+// between 0 and 1: the geometric middle of the bucket holding it.
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+	var total uint64
+	for _, n := range h.buckets {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(p * float64(total)))
+	var seen uint64
+	for i, n := range h.buckets {
+		seen += n
+		if seen >= rank {
+			upper := float64(latencyBucketMin) * math.Pow(latencyBucketGrowth, float64(i))
+			return time.Duration(upper / math.Sqrt(latencyBucketGrowth))
+		}
+	}
+	return 0
+}
+
+// hostMetrics aggregates the metrics of the requests, per host.
+type hostMetrics struct {
+	mtx   sync.Mutex
+	hosts map[string]*latencyHistogram
+}
+
+// observe records a request to the host. Latencies are only recorded for
+// the requests that received a response.
+func (m *hostMetrics) observe(host string, latency time.Duration, failed, responded bool) {
+	m.mtx.Lock()
+	{
+		h := m.hosts[host]
+		if h == nil {
+			h = &latencyHistogram{}
+			m.hosts[host] = h
+		}
+		h.requests++
+		if failed {
+			h.errors++
+		}
+		if responded {
+			h.buckets[latencyBucket(latency)]++
+		}
+	}
+	m.mtx.Unlock()
+}
+
+// snapshot returns the metrics of every host.
+func (m *hostMetrics) snapshot() map[string]HostMetrics {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	snapshot := make(map[string]HostMetrics, len(m.hosts))
+	for host, h := range m.hosts {
+		snapshot[host] = HostMetrics{
+			Requests: h.requests,
+			Errors:   h.errors,
+			P50:      h.percentile(0.5),
+			P95:      h.percentile(0.95),
+		}
+	}
+	return snapshot
+}
+
+// hostMetricsTransport records the metrics of the requests sent through
+// the next transport.
+type hostMetricsTransport struct {
+	next    http.RoundTripper
+	metrics *hostMetrics
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *hostMetricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+	t.metrics.observe(req.URL.Host, time.Since(start), failed, err == nil)
+	return resp, err
+}
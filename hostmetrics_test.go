@@ -0,0 +1,61 @@
+package http_test
+
+import (
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestHostMetrics(t *testing.T) {
+	fast := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		time.Sleep(5 * time.Millisecond)
+	}))
+	defer fast.Close()
+	slow := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		time.Sleep(40 * time.Millisecond)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(nethttp.StatusBadGateway)
+		}
+	}))
+	defer slow.Close()
+
+	pool := http.NewClientPool(http.WithHostMetrics())
+	client := pool.GetClient(time.Second)
+	for i := 0; i < 20; i++ {
+		getDiscard(t, client, fast.URL)
+	}
+	for i := 0; i < 10; i++ {
+		path := "/"
+		if i%2 == 0 {
+			path = "/fail"
+		}
+		getDiscard(t, client, slow.URL+path)
+	}
+
+	metrics := pool.HostMetricsSnapshot()
+	for _, host := range []struct {
+		url            string
+		requests       uint64
+		errors         uint64
+		minP50, maxP95 time.Duration
+	}{
+		{fast.URL, 20, 0, 4 * time.Millisecond, 30 * time.Millisecond},
+		{slow.URL, 10, 5, 35 * time.Millisecond, 80 * time.Millisecond},
+	} {
+		m := metrics[strings.TrimPrefix(host.url, "http://")]
+		if m.Requests != host.requests || m.Errors != host.errors {
+			t.Errorf("%s: %d requests and %d errors, want %d and %d", host.url, m.Requests, m.Errors, host.requests, host.errors)
+		}
+		if m.P50 < host.minP50 || m.P95 > host.maxP95 || m.P50 > m.P95 {
+			t.Errorf("%s: p50 = %v and p95 = %v, want within [%v, %v]", host.url, m.P50, m.P95, host.minP50, host.maxP95)
+		}
+	}
+
+	if metrics := http.NewClientPool().HostMetricsSnapshot(); metrics != nil {
+		t.Errorf("metrics = %v, want nil when disabled", metrics)
+	}
+}
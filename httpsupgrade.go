@@ -0,0 +1,132 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrHTTPSUpgradeFailed is returned when a plain HTTP request upgraded to
+// HTTPS by WithStrictHTTPSUpgrade cannot be sent over HTTPS.
+var ErrHTTPSUpgradeFailed = errors.New("http: upgrade to https failed")
+
+// WithHTTPSUpgrade makes the clients of the pool send their plain HTTP
+// requests over HTTPS, rewriting the scheme of their URLs and keeping
+// their host, path and query, unless their host matches one of the
+// exceptions. An exception is either a host name, or a domain prefixed
+// with "*." matching its subdomains. The default port of plain HTTP is
+// replaced by the default port of HTTPS, other ports are kept.
+//
+// When no connection to the server can be established over HTTPS, for
+// instance because it is refused, the request is sent over plain HTTP as
+// before, provided its body can be sent again. Use WithStrictHTTPSUpgrade
+// to fail these requests instead. Since an attacker on the network can
+// cause them, failures of the TLS handshake, such as an invalid
+// certificate, never fall back to plain HTTP and fail the request.
+func WithHTTPSUpgrade(exceptions ...string) Option {
+	return withHTTPSUpgrade(false, exceptions)
+}
+
+// WithStrictHTTPSUpgrade upgrades the plain HTTP requests to HTTPS like
+// WithHTTPSUpgrade, but fails with ErrHTTPSUpgradeFailed the requests
+// that cannot be sent over HTTPS rather than sending them over plain
+// HTTP.
+func WithStrictHTTPSUpgrade(exceptions ...string) Option {
+	return withHTTPSUpgrade(true, exceptions)
+}
+
+func withHTTPSUpgrade(strict bool, exceptions []string) Option {
+	hosts := make([]string, len(exceptions))
+	for i, host := range exceptions {
+		hosts[i] = strings.ToLower(host)
+	}
+
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			return &httpsUpgradeTransport{
+				next:       next,
+				strict:     strict,
+				exceptions: hosts,
+			}
+		})
+	}
+}
+
+// httpsUpgradeTransport sends the plain HTTP requests over HTTPS through
+// the next transport.
+type httpsUpgradeTransport struct {
+	next       http.RoundTripper
+	strict     bool
+	exceptions []string
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *httpsUpgradeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" || t.isException(req.URL.Hostname()) {
+		return t.next.RoundTrip(req)
+	}
+
+	ureq := req.Clone(req.Context())
+	ureq.URL.Scheme = "https"
+	if req.URL.Port() == "80" {
+		ureq.URL.Host = req.URL.Hostname()
+		if strings.Contains(ureq.URL.Host, ":") {
+			ureq.URL.Host = "[" + ureq.URL.Host + "]"
+		}
+	}
+
+	resp, err := t.next.RoundTrip(ureq)
+	if err == nil {
+		return resp, nil
+	}
+	if t.strict && (isDialError(err) || isTLSFailure(err)) {
+		return nil, fmt.Errorf("%w: %s", ErrHTTPSUpgradeFailed, err)
+	}
+	if t.strict || !isDialError(err) {
+		return nil, err
+	}
+
+	// Nothing was sent over HTTPS, the request is sent as is.
+	if !canRewind(req) {
+		return nil, err
+	}
+	if req, err = rewindRequest(req); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
+
+// isException reports whether the host is not to be upgraded.
+func (t *httpsUpgradeTransport) isException(host string) bool {
+	host = strings.ToLower(host)
+	for _, exception := range t.exceptions {
+		if exception == host {
+			return true
+		}
+		if strings.HasPrefix(exception, "*.") && strings.HasSuffix(host, exception[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTLSFailure reports whether the error occurred because the TLS
+// handshake with the server failed, before the request was sent.
+func isTLSFailure(err error) bool {
+	var recordErr tls.RecordHeaderError
+	return isTLSHandshakeTimeout(err) || errors.As(err, &recordErr) || isCertificateError(err)
+}
+
+// isCertificateError reports whether the error occurred because the
+// certificate of the server could not be verified.
+func isCertificateError(err error) bool {
+	var (
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+	return errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
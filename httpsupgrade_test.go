@@ -0,0 +1,153 @@
+package http_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// newSchemeServer returns a server answering with the scheme and the
+// path of the requests.
+func newSchemeServer(secure bool) *httptest.Server {
+	handler := nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		io.WriteString(w, scheme+" "+r.URL.RequestURI())
+	})
+	if secure {
+		return httptest.NewTLSServer(handler)
+	}
+	return httptest.NewServer(handler)
+}
+
+// newUpgradePool returns a pool created with the option, which sends the
+// plain HTTP requests to the plain server and the HTTPS requests to the
+// secure server, whose certificate it trusts, refusing the connections
+// if the secure server is nil.
+func newUpgradePool(t *testing.T, opt http.Option, plain, secure *httptest.Server) *http.ClientPool {
+	restore := http.SetDialContext(func(d *net.Dialer, ctx context.Context, network, addr string) (net.Conn, error) {
+		_, port, _ := net.SplitHostPort(addr)
+		target := plain
+		if port == "443" {
+			target = secure
+		}
+		if target == nil {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: errors.New("connection refused")}
+		}
+		return d.DialContext(ctx, network, target.Listener.Addr().String())
+	})
+	t.Cleanup(restore)
+
+	pool := http.NewClientPool(opt)
+	pool.SetProxy(func(*nethttp.Request) (*url.URL, error) { return nil, nil })
+	if secure != nil && secure.Certificate() != nil {
+		roots := x509.NewCertPool()
+		roots.AddCert(secure.Certificate())
+		pool.SetDefaultTLSConfig(&tls.Config{RootCAs: roots})
+	}
+	return pool
+}
+
+// getString returns the body of the response to a GET request to the
+// URL.
+func getString(client *nethttp.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestHTTPSUpgrade(t *testing.T) {
+	plain := newSchemeServer(false)
+	defer plain.Close()
+	secure := newSchemeServer(true)
+	defer secure.Close()
+
+	pool := newUpgradePool(t, http.WithHTTPSUpgrade("legacy.example.com", "*.local.example.com"), plain, secure)
+	client := pool.GetClient(time.Second)
+	for _, test := range []struct {
+		url  string
+		want string
+	}{
+		{"http://example.com/items?page=2", "https /items?page=2"},
+		{"http://example.com:80/", "https /"},
+		{"https://example.com/", "https /"},
+		{"http://legacy.example.com/", "http /"},
+		{"http://Legacy.Example.com/", "http /"},
+		{"http://box.local.example.com/", "http /"},
+	} {
+		got, err := getString(client, test.url)
+		if err != nil {
+			t.Fatalf("%s: %v", test.url, err)
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.url, got, test.want)
+		}
+	}
+}
+
+func TestHTTPSUpgradeFallback(t *testing.T) {
+	plain := newSchemeServer(false)
+	defer plain.Close()
+
+	// Without a server accepting HTTPS, the requests are sent over plain
+	// HTTP unless the upgrade is strict.
+	pool := newUpgradePool(t, http.WithHTTPSUpgrade(), plain, nil)
+	if got, err := getString(pool.GetClient(time.Second), "http://example.com/"); err != nil || got != "http /" {
+		t.Errorf("got %q and %v, want %q", got, err, "http /")
+	}
+
+	pool = newUpgradePool(t, http.WithStrictHTTPSUpgrade(), plain, nil)
+	if _, err := getString(pool.GetClient(time.Second), "http://example.com/"); !errors.Is(err, http.ErrHTTPSUpgradeFailed) {
+		t.Errorf("error = %v, want %v", err, http.ErrHTTPSUpgradeFailed)
+	}
+
+	// The exceptions are still sent over plain HTTP.
+	pool = newUpgradePool(t, http.WithStrictHTTPSUpgrade("example.com"), plain, nil)
+	if got, err := getString(pool.GetClient(time.Second), "http://example.com/"); err != nil || got != "http /" {
+		t.Errorf("got %q and %v, want %q", got, err, "http /")
+	}
+}
+
+func TestHTTPSUpgradeTLSFailure(t *testing.T) {
+	plain := newSchemeServer(false)
+	defer plain.Close()
+	secure := newSchemeServer(true)
+	defer secure.Close()
+
+	for _, test := range []struct {
+		name   string
+		secure *httptest.Server
+	}{
+		// The certificate of the server is not trusted.
+		{"untrusted certificate", secure},
+		// The server on the HTTPS port does not speak TLS.
+		{"no TLS", plain},
+	} {
+		for _, opt := range []http.Option{http.WithHTTPSUpgrade(), http.WithStrictHTTPSUpgrade()} {
+			pool := newUpgradePool(t, opt, plain, test.secure)
+			pool.SetDefaultTLSConfig(&tls.Config{RootCAs: x509.NewCertPool()})
+
+			// Failed handshakes never fall back to plain HTTP.
+			if got, err := getString(pool.GetClient(time.Second), "http://example.com/"); err == nil {
+				t.Errorf("%s: got %q, want the handshake to fail", test.name, got)
+			}
+		}
+	}
+}
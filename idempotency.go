@@ -0,0 +1,56 @@
+package http
+
+import (
+	"context"
+	"net/http"
+)
+
+// idempotencyKey is the context key marking the requests that carry an
+// idempotency key generated by the pool.
+type idempotencyKey struct{}
+
+// WithIdempotencyKey makes the clients of the pool set the specified
+// header on the requests whose method is not idempotent, such as POST
+// and PATCH, to a key returned by gen. The key is generated once per
+// request sent through a client, before any middleware, so that retries
+// send the same key and the server can detect duplicates. Requests that
+// already carry the header keep their key. Requests carrying a key are
+// considered idempotent by DefaultRetryClassifier.
+func WithIdempotencyKey(header string, gen func() string) Option {
+	header = http.CanonicalHeaderKey(header)
+
+	return func(c *ClientPool) {
+		c.idempotency = func(next http.RoundTripper) http.RoundTripper {
+			return &idempotencyTransport{
+				next:   next,
+				header: header,
+				gen:    gen,
+			}
+		}
+		c.resetClients()
+	}
+}
+
+// idempotencyTransport sets an idempotency key on the requests sent
+// through the next transport.
+type idempotencyTransport struct {
+	next   http.RoundTripper
+	header string
+	gen    func() string
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *idempotencyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isIdempotent(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx := context.WithValue(req.Context(), idempotencyKey{}, true)
+	if _, ok := req.Header[t.header]; ok {
+		return t.next.RoundTrip(req.WithContext(ctx))
+	}
+
+	kreq := req.Clone(ctx)
+	kreq.Header.Set(t.header, t.gen())
+	return t.next.RoundTrip(kreq)
+}
@@ -0,0 +1,79 @@
+package http_test
+
+import (
+	"fmt"
+	nethttp "net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestWithIdempotencyKey(t *testing.T) {
+	var generated int
+	gen := func() string {
+		generated++
+		return fmt.Sprintf("key-%d", generated)
+	}
+
+	var mtx sync.Mutex
+	var keys []string
+	cp := http.NewClientPool(
+		http.WithRetry(3, time.Millisecond),
+		http.WithIdempotencyKey("Request-Key", gen),
+	)
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		keys = append(keys, req.Header.Get("Request-Key"))
+		if len(keys)%3 != 0 {
+			return statusResponse(req, nethttp.StatusServiceUnavailable), nil
+		}
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+
+	client := cp.GetClient(time.Second)
+	for i := 0; i < 2; i++ {
+		resp, err := client.Post("http://example.com/", "text/plain", strings.NewReader("payment"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != nethttp.StatusOK {
+			t.Fatalf("status = %d, want 200 after the retries", resp.StatusCode)
+		}
+	}
+
+	want := []string{"key-1", "key-1", "key-1", "key-2", "key-2", "key-2"}
+	if fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Fatalf("sent keys %q, want %q", keys, want)
+	}
+}
+
+func TestWithIdempotencyKeySkipsIdempotent(t *testing.T) {
+	cp := http.NewClientPool(http.WithIdempotencyKey("Request-Key", func() string { return "generated" }))
+
+	var got []string
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		got = append(got, req.Header.Get("Request-Key"))
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+
+	get, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/", nil)
+	post, _ := nethttp.NewRequest(nethttp.MethodPost, "http://example.com/", nil)
+	post.Header.Set("Request-Key", "caller")
+	for _, req := range []*nethttp.Request{get, post} {
+		resp, err := cp.GetClient(time.Second).Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint([]string{"", "caller"}) {
+		t.Fatalf("sent keys %q, want none for GET and the caller key for POST", got)
+	}
+}
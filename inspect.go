@@ -0,0 +1,79 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"time"
+)
+
+// TLSInfo describes the TLS certificates and settings of a server.
+type TLSInfo struct {
+	// PeerCertificates are the certificates sent by the server, the
+	// first one being its leaf certificate.
+	PeerCertificates []*x509.Certificate
+
+	// NotAfter is the earliest expiry of the peer certificates.
+	NotAfter time.Time
+
+	// DNSNames are the DNS names of the leaf certificate.
+	DNSNames []string
+
+	// NegotiatedProtocol is the application protocol negotiated with
+	// ALPN, such as "h2" or "http/1.1", or empty if none was.
+	NegotiatedProtocol string
+}
+
+// InspectTLS connects to the server at addr, of the form "host:port",
+// and completes a TLS handshake without sending any HTTP request. The
+// handshake uses the TLS Configuration of the pool, so the certificate
+// chain is verified against its root CAs, with the host of addr as
+// server name unless the configuration sets one. If the configuration
+// does not specify any application protocol, "h2" and "http/1.1" are
+// offered.
+func (c *ClientPool) InspectTLS(ctx context.Context, addr string) (TLSInfo, error) {
+	c.mtx.RLock()
+	config := c.defaultTLSConfig().Clone()
+	c.mtx.RUnlock()
+
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return TLSInfo{}, err
+		}
+		config.ServerName = host
+	}
+	if len(config.NextProtos) == 0 {
+		config.NextProtos = []string{"h2", "http/1.1"}
+	}
+
+	conn, err := (&net.Dialer{Timeout: 30 * time.Second}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return TLSInfo{}, err
+	}
+	defer conn.Close()
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return TLSInfo{}, err
+	}
+
+	state := tlsConn.ConnectionState()
+	info := TLSInfo{
+		PeerCertificates:   state.PeerCertificates,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+	}
+	for i, cert := range state.PeerCertificates {
+		if i == 0 {
+			info.DNSNames = cert.DNSNames
+		}
+		if info.NotAfter.IsZero() || cert.NotAfter.Before(info.NotAfter) {
+			info.NotAfter = cert.NotAfter
+		}
+	}
+	return info, nil
+}
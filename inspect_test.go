@@ -0,0 +1,61 @@
+package http_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Updater/http"
+)
+
+func TestInspectTLS(t *testing.T) {
+	var requests int
+	server := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {
+		requests++
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	cp := http.NewClientPool()
+	cp.SetDefaultTLSConfig(&tls.Config{RootCAs: roots})
+
+	info, err := cp.InspectTLS(context.Background(), server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := server.Certificate()
+	if len(info.PeerCertificates) == 0 || !info.PeerCertificates[0].Equal(cert) {
+		t.Fatal("expected the certificate of the server")
+	}
+	if !info.NotAfter.Equal(cert.NotAfter) {
+		t.Errorf("NotAfter = %v, want %v", info.NotAfter, cert.NotAfter)
+	}
+	if fmt.Sprint(info.DNSNames) != fmt.Sprint(cert.DNSNames) {
+		t.Errorf("DNSNames = %v, want %v", info.DNSNames, cert.DNSNames)
+	}
+	if info.NegotiatedProtocol != "h2" {
+		t.Errorf("NegotiatedProtocol = %q, want h2", info.NegotiatedProtocol)
+	}
+	if requests != 0 {
+		t.Errorf("got %d requests, want none", requests)
+	}
+}
+
+func TestInspectTLSUntrusted(t *testing.T) {
+	server := httptest.NewTLSServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	defer server.Close()
+
+	cp := http.NewClientPool()
+	if _, err := cp.InspectTLS(context.Background(), server.Listener.Addr().String()); err == nil {
+		t.Fatal("expected the certificate of the server not to be trusted")
+	}
+}
@@ -0,0 +1,110 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// ErrRetryRequest can be returned by a status interceptor to send the
+// request again, for instance after refreshing the credentials it uses.
+var ErrRetryRequest = errors.New("http: retry request")
+
+// statusInterceptors holds the interceptors registered by status code.
+type statusInterceptors struct {
+	mtx sync.RWMutex
+	fns map[int][]func(*http.Request, *http.Response) error
+}
+
+func (s *statusInterceptors) get(code int) []func(*http.Request, *http.Response) error {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	return s.fns[code]
+}
+
+// OnStatus registers an interceptor called with the responses received
+// with the specified status code by the clients of the pool, before they
+// are returned. If the interceptor returns an error, the response body
+// is closed and the request fails with that error. If it returns nil,
+// the response is returned as is.
+//
+// If the interceptor returns ErrRetryRequest, the response is discarded
+// and the request is sent again, once, provided its body can be sent
+// again. The interceptor receives a copy of the request that was sent
+// and can update its headers before the retry, for instance to set a
+// refreshed Authorization header. If the retry also asks to be retried,
+// its response is returned as is. Interceptors of the same status code
+// are called in the order they were registered, until one of them
+// returns an error.
+func (c *ClientPool) OnStatus(code int, fn func(*http.Request, *http.Response) error) {
+	c.mtx.Lock()
+	{
+		if c.interceptors == nil {
+			c.interceptors = &statusInterceptors{
+				fns: make(map[int][]func(*http.Request, *http.Response) error),
+			}
+
+			// Ensuring that new clients requested from the pool will
+			// call the interceptors.
+			c.resetClients()
+		}
+	}
+	c.mtx.Unlock()
+
+	c.interceptors.mtx.Lock()
+	{
+		c.interceptors.fns[code] = append(c.interceptors.fns[code], fn)
+	}
+	c.interceptors.mtx.Unlock()
+}
+
+// interceptorTransport calls the status interceptors with the responses
+// of the next transport.
+type interceptorTransport struct {
+	next         http.RoundTripper
+	interceptors *statusInterceptors
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *interceptorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	retried := false
+	for {
+		fns := t.interceptors.get(resp.StatusCode)
+		if len(fns) == 0 {
+			return resp, nil
+		}
+
+		sent := req.Clone(req.Context())
+		var ierr error
+		for _, fn := range fns {
+			if ierr = fn(sent, resp); ierr != nil {
+				break
+			}
+		}
+
+		switch {
+		case ierr == nil:
+			return resp, nil
+		case ierr == ErrRetryRequest && (retried || !canRewind(req)):
+			return resp, nil
+		case ierr != ErrRetryRequest:
+			resp.Body.Close()
+			return nil, ierr
+		}
+
+		discardBody(resp)
+		if req, err = rewindRequest(sent); err != nil {
+			return nil, err
+		}
+		if resp, err = t.next.RoundTrip(req); err != nil {
+			return nil, err
+		}
+		retried = true
+	}
+}
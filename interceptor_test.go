@@ -0,0 +1,111 @@
+package http_test
+
+import (
+	"errors"
+	nethttp "net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestOnStatusRefreshRetry(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool()
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		if req.Header.Get("Authorization") != "Bearer fresh" {
+			return statusResponse(req, nethttp.StatusUnauthorized), nil
+		}
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+
+	var refreshes int
+	cp.OnStatus(nethttp.StatusUnauthorized, func(req *nethttp.Request, resp *nethttp.Response) error {
+		refreshes++
+		req.Header.Set("Authorization", "Bearer fresh")
+		return http.ErrRetryRequest
+	})
+
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/", nil)
+	req.Header.Set("Authorization", "Bearer stale")
+	resp, err := cp.GetClient(time.Second).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != nethttp.StatusOK || calls != 2 || refreshes != 1 {
+		t.Fatalf("got status %d after %d calls and %d refreshes, want 200 after 2 and 1",
+			resp.StatusCode, calls, refreshes)
+	}
+	if req.Header.Get("Authorization") != "Bearer stale" {
+		t.Fatal("expected the caller request to be left untouched")
+	}
+}
+
+func TestOnStatusRetriesOnce(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool()
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		return statusResponse(req, nethttp.StatusUnauthorized), nil
+	}))
+	cp.OnStatus(nethttp.StatusUnauthorized, func(*nethttp.Request, *nethttp.Response) error {
+		return http.ErrRetryRequest
+	})
+
+	resp, err := cp.GetClient(time.Second).Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != nethttp.StatusUnauthorized || calls != 2 {
+		t.Fatalf("got status %d after %d calls, want 401 after 2", resp.StatusCode, calls)
+	}
+}
+
+func TestOnStatusObserve(t *testing.T) {
+	cp := http.NewClientPool()
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		if req.URL.Path == "/limited" {
+			return statusResponse(req, nethttp.StatusTooManyRequests), nil
+		}
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+
+	var limited []string
+	cp.OnStatus(nethttp.StatusTooManyRequests, func(req *nethttp.Request, resp *nethttp.Response) error {
+		limited = append(limited, req.URL.Path)
+		return nil
+	})
+
+	client := cp.GetClient(time.Second)
+	for _, path := range []string{"/ok", "/limited", "/ok", "/limited"} {
+		resp, err := client.Get("http://example.com" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if len(limited) != 2 {
+		t.Fatalf("interceptor called for %q, want the 2 limited requests", limited)
+	}
+}
+
+func TestOnStatusError(t *testing.T) {
+	errForbidden := errors.New("forbidden")
+
+	cp := http.NewClientPool()
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		return statusResponse(req, nethttp.StatusForbidden), nil
+	}))
+	cp.OnStatus(nethttp.StatusForbidden, func(*nethttp.Request, *nethttp.Response) error {
+		return errForbidden
+	})
+
+	if _, err := cp.GetClient(time.Second).Get("http://example.com/"); !errors.Is(err, errForbidden) {
+		t.Fatalf("error = %v, want %v", err, errForbidden)
+	}
+}
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// WithIPv6PreferredFallback makes the default transport connect to the
+// hosts over IPv6 first. If the connection is not established within
+// fallbackAfter, a connection over IPv4 is attempted in parallel and the
+// first one established is used, the other attempt being abandoned. If
+// the IPv6 attempt fails before the delay, for instance because the host
+// has no IPv6 address, IPv4 is attempted right away. Transports set with
+// SetTransport and connections through a jump host set with WithJumpHost
+// are not affected.
+func WithIPv6PreferredFallback(fallbackAfter time.Duration) Option {
+	return func(c *ClientPool) {
+		c.ipv6Fallback = fallbackAfter
+		c.resetClients()
+	}
+}
+
+// dialResult is the outcome of a dial attempt.
+type dialResult struct {
+	conn    net.Conn
+	err     error
+	primary bool
+}
+
+// preferIPv6 returns the dial function dialing over IPv6 first, or the
+// function as is if IPv6 is not preferred. Must be called while holding
+// the lock.
+func (c *ClientPool) preferIPv6(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	fallbackAfter := c.ipv6Fallback
+	if fallbackAfter <= 0 {
+		return dial
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if network != "tcp" {
+			return dial(ctx, network, addr)
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		results := make(chan dialResult, 2)
+		attempt := func(network string, primary bool) {
+			conn, err := dial(ctx, network, addr)
+			results <- dialResult{conn: conn, err: err, primary: primary}
+		}
+		go attempt("tcp6", true)
+
+		timer := time.NewTimer(fallbackAfter)
+		defer timer.Stop()
+
+		var firstErr error
+		fallback := false
+		for pending := 1; pending > 0; {
+			select {
+			case <-timer.C:
+			case res := <-results:
+				pending--
+				if res.err == nil {
+					if pending > 0 {
+						// Closing the abandoned attempt once it completes.
+						cancel()
+						go func() {
+							if res := <-results; res.conn != nil {
+								res.conn.Close()
+							}
+						}()
+					}
+					return res.conn, nil
+				}
+				if res.primary || firstErr == nil {
+					firstErr = res.err
+				}
+			}
+
+			if !fallback {
+				fallback = true
+				pending++
+				go attempt("tcp4", false)
+			}
+		}
+		return nil, firstErr
+	}
+}
@@ -0,0 +1,84 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestIPv6PreferredFallback(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	defer server.Close()
+
+	var mtx sync.Mutex
+	starts := make(map[string]time.Time)
+	abandoned := make(chan struct{})
+	defer http.SetDialContext(func(d *net.Dialer, ctx context.Context, network, addr string) (net.Conn, error) {
+		mtx.Lock()
+		starts[network] = time.Now()
+		mtx.Unlock()
+
+		if network == "tcp6" {
+			// IPv6 is blackholed.
+			<-ctx.Done()
+			close(abandoned)
+			return nil, ctx.Err()
+		}
+		return d.DialContext(ctx, network, server.Listener.Addr().String())
+	})()
+
+	cp := http.NewClientPool(http.WithIPv6PreferredFallback(100 * time.Millisecond))
+	cp.SetProxy(func(*nethttp.Request) (*url.URL, error) { return nil, nil })
+
+	resp, err := cp.GetClient(time.Second).Get("http://dual-stack.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	mtx.Lock()
+	delay := starts["tcp4"].Sub(starts["tcp6"])
+	mtx.Unlock()
+	if delay < 100*time.Millisecond || delay > time.Second {
+		t.Fatalf("IPv4 attempted %v after IPv6, want about 100ms", delay)
+	}
+
+	select {
+	case <-abandoned:
+	case <-time.After(time.Second):
+		t.Fatal("IPv6 attempt not abandoned once IPv4 connected")
+	}
+}
+
+func TestIPv6PreferredFallbackImmediate(t *testing.T) {
+	var mtx sync.Mutex
+	var networks []string
+	defer http.SetDialContext(func(d *net.Dialer, ctx context.Context, network, addr string) (net.Conn, error) {
+		mtx.Lock()
+		networks = append(networks, network)
+		mtx.Unlock()
+		return nil, &net.OpError{Op: "dial", Net: network, Err: errors.New("no address")}
+	})()
+
+	cp := http.NewClientPool(http.WithIPv6PreferredFallback(time.Hour))
+	cp.SetProxy(func(*nethttp.Request) (*url.URL, error) { return nil, nil })
+
+	start := time.Now()
+	if _, err := cp.GetClient(time.Second).Get("http://ipv4-only.example.com/"); err == nil {
+		t.Fatal("expected the dials to fail")
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("expected IPv4 to be attempted as soon as IPv6 failed")
+	}
+	if len(networks) != 2 || networks[0] != "tcp6" || networks[1] != "tcp4" {
+		t.Fatalf("dialed %v, want [tcp6 tcp4]", networks)
+	}
+}
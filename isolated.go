@@ -0,0 +1,74 @@
+package http
+
+import (
+	"net/http"
+	"time"
+)
+
+// isolatedKey identifies a client returned by GetIsolatedClient.
+type isolatedKey struct {
+	name    string
+	timeout time.Duration
+}
+
+// GetIsolatedClient returns a HTTP Client for making HTTP calls based on
+// the specified timeout, with a transport dedicated to the call sites
+// sharing the specified name. The connections of the transport are not
+// shared with the other clients of the pool, so that a noisy call site
+// cannot exhaust the connections of the others. The clients of the same
+// name share their transport across timeouts. Timeouts are handled as
+// with GetClient.
+//
+// The dedicated transport is a copy of the transport of the pool, with
+// the same settings and middleware. Transports set with SetTransport
+// that are not of type *http.Transport cannot be copied and are shared.
+func (c *ClientPool) GetIsolatedClient(name string, timeout time.Duration) *http.Client {
+	key := isolatedKey{name: name, timeout: c.clientTimeout(timeout)}
+
+	c.mtx.RLock()
+	client := c.isolated[key]
+	c.mtx.RUnlock()
+	if client != nil {
+		return client
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if client := c.isolated[key]; client != nil {
+		return client
+	}
+
+	transport := c.isolatedTransports[name]
+	if transport == nil {
+		transport = c.transport
+		if transport == nil && !c.customizesDefaultTransport() {
+			transport = globalDefaultTransport()
+		}
+		if t, ok := transport.(*http.Transport); ok {
+			transport = t.Clone()
+		}
+		if transport == nil && c.lruReuse {
+			transport = c.newLRUTransport()
+		}
+		if transport == nil {
+			transport = c.newDefaultTransport()
+		}
+
+		if c.isolatedTransports == nil {
+			c.isolatedTransports = make(map[string]http.RoundTripper)
+		}
+		c.isolatedTransports[name] = transport
+	}
+
+	client = &http.Client{
+		Transport:     c.wrap(transport),
+		CheckRedirect: c.checkRedirect(),
+		Timeout:       key.timeout,
+	}
+	if c.isolated == nil {
+		c.isolated = make(map[isolatedKey]*http.Client)
+	}
+	c.isolated[key] = client
+	return client
+}
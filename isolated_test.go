@@ -0,0 +1,70 @@
+package http_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestGetIsolatedClient(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	defer server.Close()
+
+	cp := http.NewClientPool()
+	cp.SetTransport(&nethttp.Transport{})
+	ctx := context.Background()
+
+	shared := connAddr(t, cp.GetClient(time.Second), ctx, server.URL)
+	noisy := connAddr(t, cp.GetIsolatedClient("noisy", time.Second), ctx, server.URL)
+	quiet := connAddr(t, cp.GetIsolatedClient("quiet", time.Second), ctx, server.URL)
+
+	if noisy == shared || quiet == shared || noisy == quiet {
+		t.Fatalf("got connections %s, %s and %s, want distinct ones", shared, noisy, quiet)
+	}
+
+	if cp.GetIsolatedClient("noisy", time.Second) != cp.GetIsolatedClient("noisy", time.Second) {
+		t.Fatal("expected the client to be cached")
+	}
+	if addr := connAddr(t, cp.GetIsolatedClient("noisy", 2*time.Second), ctx, server.URL); addr != noisy {
+		t.Fatalf("clients of the same name used connections %s and %s", noisy, addr)
+	}
+	if addr := connAddr(t, cp.GetClient(time.Second), ctx, server.URL); addr != shared {
+		t.Fatalf("shared client used connections %s and %s", shared, addr)
+	}
+}
+
+func TestGetIsolatedClientTimeout(t *testing.T) {
+	cp := http.NewClientPool()
+	cp.SetDefaultTimeout(time.Minute)
+	if timeout := cp.GetIsolatedClient("noisy", 0).Timeout; timeout != time.Minute {
+		t.Errorf("timeout = %v, want the default timeout", timeout)
+	}
+
+	cp = http.NewClientPool(http.WithContextTimeoutsOnly())
+	if timeout := cp.GetIsolatedClient("noisy", time.Second).Timeout; timeout != 0 {
+		t.Errorf("timeout = %v, want none", timeout)
+	}
+}
+
+func TestGetIsolatedClientGlobalTransport(t *testing.T) {
+	http.SetGlobalDefaultTransport(&nethttp.Transport{})
+	defer http.SetGlobalDefaultTransport(nil)
+
+	server := httptest.NewTLSServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	defer server.Close()
+	cp := newPinnedPool(t, server)
+
+	// The transport of the pool trusts the server and checks the pins.
+	unknown := sha256.Sum256([]byte("another key"))
+	cp.SetPinnedPublicKeys("example.com", [][]byte{unknown[:]})
+	_, err := cp.GetIsolatedClient("noisy", time.Second).Get("https://example.com/")
+	if !errors.Is(err, http.ErrPublicKeyPinMismatch) {
+		t.Fatalf("error = %v, want %v", err, http.ErrPublicKeyPinMismatch)
+	}
+}
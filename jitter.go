@@ -0,0 +1,62 @@
+package http
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// WithTimeoutJitter makes the clients of the pool shorten the deadline of
+// every request by a random fraction of its remaining time, up to the
+// specified fraction between 0 and 1, so that the requests started
+// together with the same timeout do not all expire at the same time and
+// retry in lockstep. The deadline is derived from the timeout of the
+// client, or from an earlier deadline of the request context, and is
+// only ever shortened: with a fraction of 0.1, a request sent by the
+// client for a 10s timeout expires after 9s to 10s. Requests without a
+// deadline are sent as is.
+func WithTimeoutJitter(fraction float64) Option {
+	if fraction < 0 {
+		fraction = 0
+	} else if fraction > 1 {
+		fraction = 1
+	}
+
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			return &jitterTransport{
+				next:     next,
+				fraction: fraction,
+			}
+		})
+	}
+}
+
+// jitterTransport shortens the deadline of the requests sent through the
+// next transport by a random fraction of their remaining time.
+type jitterTransport struct {
+	next     http.RoundTripper
+	fraction float64
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *jitterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	remaining := time.Until(deadline)
+	jitter := time.Duration(rand.Float64() * t.fraction * float64(remaining))
+	ctx, cancel := context.WithDeadline(req.Context(), deadline.Add(-jitter))
+
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
@@ -0,0 +1,57 @@
+package http_test
+
+import (
+	nethttp "net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestTimeoutJitter(t *testing.T) {
+	var (
+		mtx       sync.Mutex
+		remaining []time.Duration
+	)
+	pool := http.NewClientPool(http.WithTimeoutJitter(0.2))
+	pool.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		deadline, ok := req.Context().Deadline()
+		if !ok {
+			t.Error("request without deadline")
+		}
+		mtx.Lock()
+		remaining = append(remaining, time.Until(deadline))
+		mtx.Unlock()
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+	client := pool.GetClient(10 * time.Second)
+
+	const requests = 200
+	for i := 0; i < requests; i++ {
+		resp, err := client.Get("http://example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	// The deadlines are spread over [8s, 10s], with about a fifth of
+	// them in every fifth of the band.
+	var bands [5]int
+	for _, d := range remaining {
+		if d < 8*time.Second-100*time.Millisecond || d > 10*time.Second {
+			t.Fatalf("deadline in %v, want within [8s, 10s]", d)
+		}
+		band := int((10*time.Second - d) / (400 * time.Millisecond))
+		if band > 4 {
+			band = 4
+		}
+		bands[band]++
+	}
+	for i, n := range bands {
+		if n < requests/5/2 {
+			t.Errorf("%d deadlines in band %d of %v, want about %d", n, i, bands, requests/5)
+		}
+	}
+}
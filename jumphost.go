@@ -0,0 +1,56 @@
+package http
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// WithJumpHost makes the default transport establish all its connections
+// through the jump host reached with dial, such as the Dial method of an
+// SSH client connected to a bastion, in place of dialing the targets
+// directly. The dial timeout of the transport still applies, through the
+// context passed to dial, as does the shorter timeout of
+// WithFirstRequestFastFail for the hosts not dialed yet. The jump host
+// chooses how to reach the targets, so WithIPv6PreferredFallback does not
+// apply. A SOCKS5 proxy set with SetSOCKS5Proxy is reached through the
+// jump host. Transports set with SetTransport are not affected.
+func WithJumpHost(dial func(ctx context.Context, network, addr string) (net.Conn, error)) Option {
+	return func(c *ClientPool) {
+		c.jumpHost = dial
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+}
+
+// jumpHostDial returns the dial function establishing the connections
+// through the jump host with the specified dial timeout, shortened for
+// the hosts not dialed yet with WithFirstRequestFastFail, or the function
+// as is if no jump host is set. Must be called while holding the lock.
+func (c *ClientPool) jumpHostDial(timeout time.Duration, dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	jump := c.jumpHost
+	if jump == nil {
+		return dial
+	}
+	ff := c.fastFail
+	if timeout <= 0 && ff == nil {
+		return jump
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		d := timeout
+		if ff != nil && !ff.known(addr) {
+			d = ff.timeout
+			defer ff.add(addr)
+		}
+		if d > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d)
+			defer cancel()
+		}
+
+		return jump(ctx, network, addr)
+	}
+}
@@ -0,0 +1,80 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestWithJumpHost(t *testing.T) {
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, r.Host)
+	}))
+	defer srv.Close()
+
+	// The fake jump host tunnels every connection to the local server,
+	// whatever the target, which is not reachable directly.
+	var mtx sync.Mutex
+	var targets []string
+	jump := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		mtx.Lock()
+		targets = append(targets, addr)
+		mtx.Unlock()
+		return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+	}
+
+	cp := http.NewClientPool(http.WithJumpHost(jump))
+	for _, url := range []string{"http://internal-a.invalid/", "http://internal-b.invalid:8080/", "http://internal-a.invalid/again"} {
+		resp, err := cp.GetClient(0).Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if want := resp.Request.URL.Host; string(body) != want {
+			t.Errorf("got host %q, want %q", body, want)
+		}
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	sort.Strings(targets)
+	if len(targets) != 2 || targets[0] != "internal-a.invalid:80" || targets[1] != "internal-b.invalid:8080" {
+		t.Fatalf("got dials through the jump host to %q", targets)
+	}
+}
+
+func TestWithJumpHostFastFail(t *testing.T) {
+	var mtx sync.Mutex
+	var timeouts []time.Duration
+	jump := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		deadline, _ := ctx.Deadline()
+		mtx.Lock()
+		timeouts = append(timeouts, time.Until(deadline))
+		mtx.Unlock()
+		return nil, errors.New("unreachable")
+	}
+
+	cp := http.NewClientPool(http.WithJumpHost(jump), http.WithFirstRequestFastFail(time.Second))
+	for i := 0; i < 2; i++ {
+		if _, err := cp.GetClient(0).Get("http://internal.invalid/"); err == nil {
+			t.Fatal("expected the dial to fail")
+		}
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if len(timeouts) != 2 || timeouts[0] > time.Second || timeouts[1] <= time.Second {
+		t.Fatalf("got dial timeouts %v, want the fast-fail timeout for the first dial only", timeouts)
+	}
+}
@@ -0,0 +1,200 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultKeepWarmInterval is the interval between keep-warm rounds
+	// when the transport has no idle connection timeout.
+	defaultKeepWarmInterval = 30 * time.Second
+
+	// keepWarmRecent is the duration after which a host that was not
+	// used anymore is no longer kept warm.
+	keepWarmRecent = 5 * time.Minute
+)
+
+// warmHost is a host recently used by the clients of the pool.
+type warmHost struct {
+	// transport is the transport that was used to reach the host,
+	// whose connections are kept warm.
+	transport http.RoundTripper
+	lastUsed  time.Time
+}
+
+// keepWarm keeps idle connections open to the hosts recently used.
+type keepWarm struct {
+	mtx   sync.Mutex
+	conns int
+	hosts map[string]*warmHost
+	stop  chan struct{}
+}
+
+// SetMinIdleConnsPerHost makes the pool keep at least n idle connections
+// open to every host used within the last five minutes. A background
+// goroutine periodically sends n concurrent HEAD requests to the root of
+// every such host, which reuses the idle connections and opens new ones
+// if some were closed. The requests are sent every half the idle
+// connection timeout of the transport, or every 30 seconds if it has
+// none, so that idle connections do not expire. The number of idle
+// connections kept by a transport is also capped by its
+// MaxIdleConnsPerHost setting, which defaults to 2. The keep-warm
+// requests are not accounted by HostMetricsSnapshot, RecentErrors,
+// ConnectionReport nor SetOnNewConnection.
+//
+// Zero stops keeping connections warm. The goroutine is stopped when the
+// pool is closed.
+func (c *ClientPool) SetMinIdleConnsPerHost(n int) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.keepWarm == nil {
+		c.keepWarm = &keepWarm{
+			hosts: make(map[string]*warmHost),
+		}
+
+		// Ensuring that new clients requested from the pool will
+		// track the hosts they use.
+		c.resetClients()
+	}
+
+	kw := c.keepWarm
+	kw.mtx.Lock()
+	{
+		kw.conns = n
+		if n > 0 && kw.stop == nil {
+			kw.stop = make(chan struct{})
+			go c.runKeepWarm(kw, kw.stop)
+		} else if n <= 0 && kw.stop != nil {
+			close(kw.stop)
+			kw.stop = nil
+		}
+	}
+	kw.mtx.Unlock()
+}
+
+// stopKeepWarm stops the keep-warm goroutine, if running. Must be called
+// while holding the lock.
+func (c *ClientPool) stopKeepWarm() {
+	if kw := c.keepWarm; kw != nil {
+		kw.mtx.Lock()
+		{
+			if kw.stop != nil {
+				close(kw.stop)
+				kw.stop = nil
+			}
+		}
+		kw.mtx.Unlock()
+	}
+}
+
+// keepWarmTimer returns the clock of the pool and a timer firing after
+// the interval between keep-warm rounds for its current transport.
+func (c *ClientPool) keepWarmTimer() (clock, timer) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	interval := defaultKeepWarmInterval
+	if t, ok := c.transport.(*http.Transport); ok && t.IdleConnTimeout > 0 {
+		interval = t.IdleConnTimeout / 2
+	}
+	clk := c.timeSource()
+	return clk, clk.NewTimer(interval)
+}
+
+func (c *ClientPool) runKeepWarm(kw *keepWarm, stop chan struct{}) {
+	for {
+		clk, timer := c.keepWarmTimer()
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		kw.warm(clk.Now())
+	}
+}
+
+// touch records that the host was reached through the transport at the
+// specified time.
+func (kw *keepWarm) touch(host string, transport http.RoundTripper, now time.Time) {
+	kw.mtx.Lock()
+	{
+		kw.hosts[host] = &warmHost{
+			transport: transport,
+			lastUsed:  now,
+		}
+	}
+	kw.mtx.Unlock()
+}
+
+// warm sends a round of keep-warm requests to the hosts used recently as
+// of the specified time.
+func (kw *keepWarm) warm(now time.Time) {
+	hosts := make(map[string]http.RoundTripper)
+	var conns int
+
+	kw.mtx.Lock()
+	{
+		conns = kw.conns
+		for host, wh := range kw.hosts {
+			if now.Sub(wh.lastUsed) > keepWarmRecent {
+				delete(kw.hosts, host)
+				continue
+			}
+			hosts[host] = wh.transport
+		}
+	}
+	kw.mtx.Unlock()
+
+	var wg sync.WaitGroup
+	for host, transport := range hosts {
+		client := &http.Client{
+			Transport: transport,
+			Timeout:   warmupTimeout,
+		}
+
+		for i := 0; i < conns; i++ {
+			wg.Add(1)
+			go func(host string) {
+				defer wg.Done()
+				warmConnection(client, host)
+			}(host)
+		}
+	}
+	wg.Wait()
+}
+
+// warmConnection sends a HEAD request to the root of the host.
+func warmConnection(client *http.Client, host string) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodHead, host+"/", nil)
+	if err != nil {
+		return
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	discardBody(resp)
+}
+
+// keepWarmTransport records the hosts reached through the next
+// transport, whose connections are kept warm through the warm transport
+// below the layers accounting the requests.
+type keepWarmTransport struct {
+	next     http.RoundTripper
+	warm     http.RoundTripper
+	keepWarm *keepWarm
+	clock    clock
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *keepWarmTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.keepWarm.touch(req.URL.Scheme+"://"+req.URL.Host, t.warm, t.clock.Now())
+	return t.next.RoundTrip(req)
+}
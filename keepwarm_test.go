@@ -0,0 +1,117 @@
+package http_test
+
+import (
+	"context"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// countingDialer counts the connections it establishes.
+type countingDialer struct {
+	dials int32
+}
+
+func (d *countingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	atomic.AddInt32(&d.dials, 1)
+	return (&net.Dialer{}).DialContext(ctx, network, addr)
+}
+
+func (d *countingDialer) count() int32 {
+	return atomic.LoadInt32(&d.dials)
+}
+
+func TestMinIdleConnsPerHost(t *testing.T) {
+	var heads int32
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.Method == nethttp.MethodHead {
+			atomic.AddInt32(&heads, 1)
+
+			// Keep the requests of a round concurrent.
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	dialer := &countingDialer{}
+	cp := http.NewClientPool()
+	cp.SetTransport(&nethttp.Transport{
+		DialContext:     dialer.DialContext,
+		IdleConnTimeout: 200 * time.Millisecond,
+	})
+	cp.SetMinIdleConnsPerHost(2)
+	defer cp.Close()
+
+	resp, err := cp.GetClient(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// The connections are kept alive well beyond the idle timeout,
+	// so after the second one is opened no new connection is needed.
+	time.Sleep(time.Second)
+	if dials := dialer.count(); dials != 2 {
+		t.Fatalf("dialed %d connections, want 2", dials)
+	}
+	if atomic.LoadInt32(&heads) == 0 {
+		t.Fatal("expected keep-warm requests")
+	}
+
+	// Keep-warm requests stop once the pool is closed.
+	cp.Close()
+	time.Sleep(150 * time.Millisecond)
+	stopped := atomic.LoadInt32(&heads)
+	time.Sleep(300 * time.Millisecond)
+	if atomic.LoadInt32(&heads) != stopped {
+		t.Fatal("expected keep-warm requests to stop after Close")
+	}
+}
+
+func TestMinIdleConnsPerHostNotAccounted(t *testing.T) {
+	var heads int32
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.Method == nethttp.MethodHead {
+			atomic.AddInt32(&heads, 1)
+		}
+	}))
+	defer server.Close()
+
+	var conns int32
+	clock := http.NewFakeClock(time.Now())
+	cp := http.NewClientPool(http.WithClock(clock), http.WithHostMetrics(), http.WithConnectionReport())
+	cp.SetTransport(&nethttp.Transport{})
+	cp.SetOnNewConnection(func(string, string, bool) { atomic.AddInt32(&conns, 1) })
+	cp.SetMinIdleConnsPerHost(2)
+	defer cp.Close()
+
+	resp, err := cp.GetClient(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// Wait for a round of keep-warm requests, which opens a second
+	// connection.
+	for clock.Timers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(30 * time.Second)
+	for atomic.LoadInt32(&heads) < 2 || clock.Timers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	if m := cp.HostMetricsSnapshot()[host]; m.Requests != 1 {
+		t.Errorf("host metrics counted %d requests, want 1", m.Requests)
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("reported %d new connections, want 1", n)
+	}
+}
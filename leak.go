@@ -0,0 +1,86 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// WithLeakDetector makes the pool track the response bodies that were
+// returned by its clients but not closed yet. Whenever a response is
+// returned while more than threshold bodies are outstanding, onLeak is
+// called with their number, from the goroutine sending the request.
+//
+// Bodies that are garbage collected without being closed are closed so
+// that their connection is released, but remain counted as outstanding
+// since they were leaked by the caller.
+func WithLeakDetector(threshold int, onLeak func(count int)) Option {
+	d := &leakDetector{
+		threshold: int64(threshold),
+		onLeak:    onLeak,
+	}
+
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			return &leakTransport{
+				next:     next,
+				detector: d,
+			}
+		})
+	}
+}
+
+// leakDetector counts the outstanding response bodies.
+type leakDetector struct {
+	threshold   int64
+	onLeak      func(count int)
+	outstanding int64
+}
+
+// leakTransport tracks the bodies of the responses of the next
+// transport.
+type leakTransport struct {
+	next     http.RoundTripper
+	detector *leakDetector
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *leakTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	d := t.detector
+	body := &leakBody{ReadCloser: resp.Body, detector: d}
+	runtime.SetFinalizer(body, (*leakBody).finalize)
+	resp.Body = body
+
+	if n := atomic.AddInt64(&d.outstanding, 1); n > d.threshold {
+		d.onLeak(int(n))
+	}
+	return resp, nil
+}
+
+// leakBody is a response body tracked by a leak detector.
+type leakBody struct {
+	io.ReadCloser
+	detector *leakDetector
+	once     sync.Once
+}
+
+// Close closes the body and stops counting it as outstanding.
+func (b *leakBody) Close() error {
+	b.once.Do(func() {
+		runtime.SetFinalizer(b, nil)
+		atomic.AddInt64(&b.detector.outstanding, -1)
+	})
+	return b.ReadCloser.Close()
+}
+
+// finalize closes a body garbage collected without being closed.
+func (b *leakBody) finalize() {
+	b.ReadCloser.Close()
+}
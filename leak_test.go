@@ -0,0 +1,97 @@
+package http_test
+
+import (
+	"io"
+	nethttp "net/http"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestWithLeakDetector(t *testing.T) {
+	var leaks []int
+	cp := http.NewClientPool(http.WithLeakDetector(2, func(count int) {
+		leaks = append(leaks, count)
+	}))
+	cp.SetTransport(okTransport)
+	client := cp.GetClient(time.Second)
+
+	// Closed bodies are not outstanding.
+	for i := 0; i < 5; i++ {
+		resp, err := client.Get("http://example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if len(leaks) != 0 {
+		t.Fatalf("detector fired with %v for closed bodies", leaks)
+	}
+
+	var leaked []*nethttp.Response
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get("http://example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaked = append(leaked, resp)
+	}
+	if len(leaks) != 2 || leaks[0] != 3 || leaks[1] != 4 {
+		t.Fatalf("detector fired with %v, want [3 4]", leaks)
+	}
+
+	for _, resp := range leaked {
+		resp.Body.Close()
+		resp.Body.Close()
+	}
+	resp, err := client.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(leaks) != 2 {
+		t.Fatalf("detector fired with %v after the bodies were closed", leaks)
+	}
+}
+
+// closeTracker is a response body recording whether it was closed.
+type closeTracker struct {
+	io.Reader
+	closed *int32
+}
+
+func (b *closeTracker) Close() error {
+	atomic.StoreInt32(b.closed, 1)
+	return nil
+}
+
+func TestWithLeakDetectorFinalizer(t *testing.T) {
+	var closed int32
+	cp := http.NewClientPool(http.WithLeakDetector(10, func(int) {}))
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		resp := statusResponse(req, nethttp.StatusOK)
+		resp.Body = &closeTracker{Reader: strings.NewReader("leaked"), closed: &closed}
+		return resp, nil
+	}))
+
+	func() {
+		resp, err := cp.GetClient(time.Second).Get("http://example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&closed) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("leaked body was not closed once garbage collected")
+		}
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,262 @@
+package http
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned when a request cannot wait for a concurrency
+// slot because the queue of the waiting requests is full.
+var ErrQueueFull = errors.New("http: request queue full")
+
+// Priority is the priority of a request waiting for a concurrency slot.
+type Priority int
+
+const (
+	// PriorityLow is for background and bulk requests.
+	PriorityLow Priority = iota - 1
+
+	// PriorityNormal is the priority of the requests sent through the
+	// clients returned by GetClient.
+	PriorityNormal
+
+	// PriorityHigh is for latency-critical requests.
+	PriorityHigh
+)
+
+// priorityKey is the context key for the priority of a request.
+type priorityKey struct{}
+
+// requestPriority returns the priority of a request.
+func requestPriority(req *http.Request) Priority {
+	if p, ok := req.Context().Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+	return PriorityNormal
+}
+
+// waiter is a request waiting for a concurrency slot.
+type waiter struct {
+	priority Priority
+	seq      uint64
+	ready    chan struct{}
+
+	// index is the position of the waiter in the queue, or -1 once the
+	// waiter has been granted a slot.
+	index int
+}
+
+// waiterQueue is a heap of waiters ordered by priority, then by arrival.
+type waiterQueue []*waiter
+
+func (q waiterQueue) Len() int { return len(q) }
+
+func (q waiterQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waiterQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waiterQueue) Push(x interface{}) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waiterQueue) Pop() interface{} {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+	return w
+}
+
+// limiter limits the number of concurrent requests. When all the slots
+// are taken, waiting requests are granted the released slots by
+// priority, and in arrival order for the same priority.
+type limiter struct {
+	mtx     sync.Mutex
+	slots   int
+	seq     uint64
+	waiters waiterQueue
+}
+
+// acquire waits for a slot until the context is done. It fails with
+// ErrQueueFull if the context bounds the number of waiters, see
+// WithBoundedQueue, and as many requests are already waiting.
+func (l *limiter) acquire(ctx context.Context, priority Priority) error {
+	l.mtx.Lock()
+	if l.slots > 0 && len(l.waiters) == 0 {
+		l.slots--
+		l.mtx.Unlock()
+		return nil
+	}
+	if capacity, ok := ctx.Value(queueCapacityKey{}).(int); ok && len(l.waiters) >= capacity {
+		l.mtx.Unlock()
+		return ErrQueueFull
+	}
+
+	l.seq++
+	w := &waiter{
+		priority: priority,
+		seq:      l.seq,
+		ready:    make(chan struct{}),
+	}
+	heap.Push(&l.waiters, w)
+	l.mtx.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	l.mtx.Lock()
+	if w.index >= 0 {
+		heap.Remove(&l.waiters, w.index)
+		l.mtx.Unlock()
+		return ctx.Err()
+	}
+	l.mtx.Unlock()
+
+	// The slot was granted concurrently with the cancellation.
+	l.release()
+	return ctx.Err()
+}
+
+// release releases a slot, granting it to the first waiter if any.
+func (l *limiter) release() {
+	l.mtx.Lock()
+	{
+		if len(l.waiters) > 0 {
+			w := heap.Pop(&l.waiters).(*waiter)
+			close(w.ready)
+		} else {
+			l.slots++
+		}
+	}
+	l.mtx.Unlock()
+}
+
+// WithMaxConcurrency limits the number of requests in flight through the
+// clients of the pool to n. A request is in flight until its response
+// body is closed. Requests waiting for a slot are admitted by priority,
+// see GetClientWithPriority. A non-positive n does not limit the
+// requests.
+func WithMaxConcurrency(n int) Option {
+	if n <= 0 {
+		return func(*ClientPool) {}
+	}
+	l := &limiter{slots: n}
+
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			return &limiterTransport{
+				next:    next,
+				limiter: l,
+			}
+		})
+	}
+}
+
+// limiterTransport limits the concurrency of the requests sent through
+// the next transport.
+type limiterTransport struct {
+	next    http.RoundTripper
+	limiter *limiter
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *limiterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.acquire(req.Context(), requestPriority(req)); err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.limiter.release()
+		return nil, err
+	}
+
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: t.limiter.release}
+	return resp, nil
+}
+
+// queueCapacityKey is the context key for the maximum number of requests
+// waiting for a concurrency slot.
+type queueCapacityKey struct{}
+
+// WithBoundedQueue bounds the number of requests waiting for a
+// concurrency slot when the pool limits its concurrency with
+// WithMaxConcurrency. Once capacity requests are waiting, the requests
+// sent through the clients of the pool fail immediately with
+// ErrQueueFull instead of waiting, so that a slow backend cannot pile up
+// an unbounded number of pending requests. It has no effect on a pool
+// without WithMaxConcurrency.
+func WithBoundedQueue(capacity int) Option {
+	return func(c *ClientPool) {
+		c.queueCapacity = capacity
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+}
+
+// queueCapacityTransport sets the maximum number of requests waiting for
+// a concurrency slot on the requests sent through the next transport.
+type queueCapacityTransport struct {
+	next     http.RoundTripper
+	capacity int
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *queueCapacityTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := context.WithValue(req.Context(), queueCapacityKey{}, t.capacity)
+	return t.next.RoundTrip(req.WithContext(ctx))
+}
+
+// GetClientWithPriority returns a HTTP Client for making HTTP calls based
+// on the specified timeout, whose requests wait for a concurrency slot
+// with the specified priority when the pool limits its concurrency with
+// WithMaxConcurrency. The returned client shares the transport of the
+// client returned by GetClient for the same timeout.
+func (c *ClientPool) GetClientWithPriority(timeout time.Duration, priority Priority) *http.Client {
+	client := c.GetClient(timeout)
+	if priority == PriorityNormal {
+		return client
+	}
+
+	return &http.Client{
+		Transport:     &priorityTransport{next: client.Transport, priority: priority},
+		CheckRedirect: client.CheckRedirect,
+		Jar:           client.Jar,
+		Timeout:       client.Timeout,
+	}
+}
+
+// priorityTransport sets the priority of the requests sent through the
+// next transport.
+type priorityTransport struct {
+	next     http.RoundTripper
+	priority Priority
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *priorityTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := context.WithValue(req.Context(), priorityKey{}, t.priority)
+	return t.next.RoundTrip(req.WithContext(ctx))
+}
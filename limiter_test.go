@@ -0,0 +1,146 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	nethttp "net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestPriorityAdmission(t *testing.T) {
+	var (
+		mtx   sync.Mutex
+		order []string
+	)
+	block := make(chan struct{})
+	cp := http.NewClientPool(http.WithMaxConcurrency(1))
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		if req.URL.Path == "/blocker" {
+			<-block
+		}
+		mtx.Lock()
+		order = append(order, req.URL.Path)
+		mtx.Unlock()
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+
+	get := func(client *nethttp.Client, path string, wg *sync.WaitGroup) {
+		defer wg.Done()
+		resp, err := client.Get("http://example.com" + path)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+	}
+
+	// Take the only slot.
+	var blocker sync.WaitGroup
+	blocker.Add(1)
+	go get(cp.GetClient(time.Second), "/blocker", &blocker)
+	time.Sleep(20 * time.Millisecond)
+
+	// Queue low priority requests first, then high priority ones.
+	var wg sync.WaitGroup
+	low := cp.GetClientWithPriority(time.Second, http.PriorityLow)
+	high := cp.GetClientWithPriority(time.Second, http.PriorityHigh)
+	for _, req := range []struct {
+		client *nethttp.Client
+		path   string
+	}{
+		{low, "/low"}, {low, "/low"}, {cp.GetClient(time.Second), "/normal"},
+		{high, "/high"}, {high, "/high"},
+	} {
+		wg.Add(1)
+		go get(req.client, req.path, &wg)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(block)
+	blocker.Wait()
+	wg.Wait()
+
+	want := []string{"/blocker", "/high", "/high", "/normal", "/low", "/low"}
+	for i := range want {
+		if i >= len(order) || order[i] != want[i] {
+			t.Fatalf("order = %q, want %q", order, want)
+		}
+	}
+}
+
+func TestMaxConcurrencyCancel(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	cp := http.NewClientPool(http.WithMaxConcurrency(1))
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		<-block
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+	client := cp.GetClient(5 * time.Second)
+
+	go client.Get("http://example.com/")
+	time.Sleep(20 * time.Millisecond)
+
+	// A waiting request gives up when its context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, "http://example.com/", nil)
+	if _, err := client.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestBoundedQueue(t *testing.T) {
+	block := make(chan struct{})
+	cp := http.NewClientPool(http.WithMaxConcurrency(1), http.WithBoundedQueue(2))
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		<-block
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+	client := cp.GetClient(5 * time.Second)
+
+	// Take the only slot, then fill the queue.
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get("http://example.com/")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if _, err := client.Get("http://example.com/"); !errors.Is(err, http.ErrQueueFull) {
+		t.Errorf("error = %v, want %v", err, http.ErrQueueFull)
+	}
+
+	// The queued requests are admitted once the slot is released.
+	close(block)
+	wg.Wait()
+}
+
+func TestMaxConcurrencyUnlimited(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		cp := http.NewClientPool(http.WithMaxConcurrency(n))
+		cp.SetTransport(okTransport)
+		client := cp.GetClient(time.Second)
+
+		for i := 0; i < 3; i++ {
+			resp, err := client.Get("http://example.com/")
+			if err != nil {
+				t.Fatalf("WithMaxConcurrency(%d): %v", n, err)
+			}
+			defer resp.Body.Close()
+		}
+	}
+}
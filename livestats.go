@@ -0,0 +1,108 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// LiveStats holds the counters of the requests sent by the clients of a
+// pool, as reported by LiveStats.
+type LiveStats struct {
+	// Requests is the number of requests sent, retries excluded, and
+	// Errors the number of those that failed without a response.
+	Requests int64
+	Errors   int64
+
+	// InFlight is the number of requests waiting for their response,
+	// or whose response body is neither closed nor read entirely.
+	InFlight int64
+}
+
+// WithLiveStats makes the pool count the requests sent by its clients,
+// see LiveStats.
+func WithLiveStats() Option {
+	return func(c *ClientPool) {
+		c.live = &liveStats{}
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+}
+
+// LiveStats returns the current counters of the requests sent by the
+// clients of the pool, for instance to publish them with the expvar
+// package. The counters are kept across changes to the settings of the
+// pool, and a clone starts with its own counters. All the counters are
+// zero if the pool was not created with WithLiveStats.
+func (c *ClientPool) LiveStats() LiveStats {
+	c.mtx.RLock()
+	s := c.live
+	c.mtx.RUnlock()
+
+	if s == nil {
+		return LiveStats{}
+	}
+	return LiveStats{
+		Requests: atomic.LoadInt64(&s.requests),
+		Errors:   atomic.LoadInt64(&s.errors),
+		InFlight: atomic.LoadInt64(&s.inFlight),
+	}
+}
+
+// liveStats holds the counters of the requests, accessed atomically.
+type liveStats struct {
+	requests int64
+	errors   int64
+	inFlight int64
+}
+
+// liveStatsTransport counts the requests sent with the next transport.
+type liveStatsTransport struct {
+	next  http.RoundTripper
+	stats *liveStats
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *liveStatsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&t.stats.requests, 1)
+	atomic.AddInt64(&t.stats.inFlight, 1)
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		atomic.AddInt64(&t.stats.errors, 1)
+		atomic.AddInt64(&t.stats.inFlight, -1)
+		return nil, err
+	}
+
+	resp.Body = &liveStatsBody{ReadCloser: resp.Body, stats: t.stats}
+	return resp, nil
+}
+
+// liveStatsBody ends the request in flight once the body is read
+// entirely, fails or is closed.
+type liveStatsBody struct {
+	io.ReadCloser
+	stats *liveStats
+	once  sync.Once
+}
+
+func (b *liveStatsBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *liveStatsBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+	return err
+}
+
+func (b *liveStatsBody) done() {
+	b.once.Do(func() { atomic.AddInt64(&b.stats.inFlight, -1) })
+}
@@ -0,0 +1,83 @@
+package http_test
+
+import (
+	"errors"
+	"io"
+	nethttp "net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestLiveStats(t *testing.T) {
+	release := make(chan struct{})
+	cp := http.NewClientPool(http.WithLiveStats())
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		<-release
+		if req.URL.Path == "/fail" {
+			return nil, errors.New("failed")
+		}
+		resp := statusResponse(req, nethttp.StatusOK)
+		resp.Body = io.NopCloser(strings.NewReader("body"))
+		return resp, nil
+	}))
+
+	const n = 5
+	var wg sync.WaitGroup
+	bodies := make(chan io.ReadCloser, n)
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if resp, err := cp.GetClient(0).Get("http://example.com/ok"); err == nil {
+				bodies <- resp.Body
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			cp.GetClient(0).Get("http://example.com/fail")
+		}()
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); cp.LiveStats().InFlight != 2*n; {
+		if time.Now().After(deadline) {
+			t.Fatalf("got stats %+v, want %d requests in flight", cp.LiveStats(), 2*n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	wg.Wait()
+	close(bodies)
+
+	// The failed requests ended, the successful ones last until their
+	// body is read or closed.
+	if got, want := cp.LiveStats(), (http.LiveStats{Requests: 2 * n, Errors: n, InFlight: n}); got != want {
+		t.Fatalf("got stats %+v, want %+v", got, want)
+	}
+
+	i := 0
+	for body := range bodies {
+		if i%2 == 0 {
+			io.Copy(io.Discard, body)
+		}
+		body.Close()
+		i++
+	}
+	if got, want := cp.LiveStats(), (http.LiveStats{Requests: 2 * n, Errors: n}); got != want {
+		t.Fatalf("got stats %+v, want %+v", got, want)
+	}
+}
+
+func TestLiveStatsDisabled(t *testing.T) {
+	cp := http.NewClientPool()
+	cp.SetTransport(okTransport)
+	getDiscard(t, cp.GetClient(0), "http://example.com/")
+
+	if got := cp.LiveStats(); got != (http.LiveStats{}) {
+		t.Errorf("got stats %+v without WithLiveStats", got)
+	}
+}
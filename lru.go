@@ -0,0 +1,346 @@
+package http
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// lruMaxIdleConnsPerHost is the number of idle connections kept per
+	// host by the LRU transport.
+	lruMaxIdleConnsPerHost = 16
+
+	// lruIdleConnTimeout is the duration after which an idle connection
+	// of the LRU transport is closed.
+	lruIdleConnTimeout = 90 * time.Second
+
+	// lruTLSHandshakeTimeout bounds the TLS handshakes of the LRU
+	// transport, as the default transport does.
+	lruTLSHandshakeTimeout = 10 * time.Second
+)
+
+// WithLRUConnReuse replaces the default transport of the pool by a
+// transport reusing the idle connection to a host that was used the
+// least recently, instead of the most recently used one as the core http
+// package does. This keeps every connection exercised, spreading the
+// load across them and detecting the connections silently dropped by
+// the network sooner.
+//
+// The transport only speaks HTTP/1.1, directly to the hosts. The requests
+// sent through a proxy, selected with SetProxy or from the environment,
+// are sent with the usual default transport instead. It keeps up to 16
+// idle connections per host, for 90 seconds at most. Transports set with
+// SetTransport are not affected.
+func WithLRUConnReuse() Option {
+	return func(c *ClientPool) {
+		c.lruReuse = true
+		c.resetClients()
+	}
+}
+
+// newLRUTransport creates a transport reusing the least recently used
+// connections. Must be called while holding the lock.
+func (c *ClientPool) newLRUTransport() *lruTransport {
+	return &lruTransport{
+		dial:      c.defaultDial(30 * time.Second),
+		tlsConfig: c.defaultTLSConfig(),
+		proxy:     c.proxyFunc(),
+		proxied:   c.newDefaultTransport(),
+		clock:     c.timeSource(),
+		idle:      make(map[string]*list.List),
+	}
+}
+
+// lruConn is a connection of the LRU transport.
+type lruConn struct {
+	key    string
+	conn   net.Conn
+	br     *bufio.Reader
+	idleAt time.Time
+	reused bool
+}
+
+// lruTransport is a HTTP/1.1 transport reusing the least recently used
+// idle connections.
+type lruTransport struct {
+	dial      func(ctx context.Context, network, addr string) (net.Conn, error)
+	tlsConfig *tls.Config
+	clock     clock
+
+	// proxy selects the proxy of the requests, which are sent with the
+	// proxied transport if any is selected.
+	proxy   func(*http.Request) (*url.URL, error)
+	proxied *http.Transport
+
+	mtx sync.Mutex
+
+	// idle holds the idle connections of every host, the least recently
+	// used first.
+	idle map[string]*list.List
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *lruTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		closeRequestBody(req)
+		return nil, errors.New("http: unsupported protocol scheme " + req.URL.Scheme)
+	}
+	if proxyURL, err := t.proxy(req); err != nil {
+		closeRequestBody(req)
+		return nil, err
+	} else if proxyURL != nil {
+		return t.proxied.RoundTrip(req)
+	}
+
+	for {
+		pc, err := t.getConn(req)
+		if err != nil {
+			closeRequestBody(req)
+			return nil, err
+		}
+
+		resp, err := t.send(pc, req)
+		if err == nil {
+			return resp, nil
+		}
+
+		// A reused connection may have been closed by the server while
+		// idle, in which case the request is sent again on another one.
+		if !pc.reused || !isIdempotent(req) || !canRewind(req) || req.Context().Err() != nil {
+			closeRequestBody(req)
+			return nil, err
+		}
+		if req, err = rewindRequest(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// send sends the request over the connection and reads the response
+// headers.
+func (t *lruTransport) send(pc *lruConn, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	stop := closeOnDone(ctx, pc.conn)
+
+	if err := req.Write(pc.conn); err != nil {
+		stop()
+		pc.conn.Close()
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(pc.br, req)
+	if err != nil {
+		stop()
+		pc.conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	keepAlive := !resp.Close && !req.Close
+	if resp.Body == http.NoBody || resp.ContentLength == 0 {
+		stop()
+		t.release(pc, keepAlive)
+		return resp, nil
+	}
+
+	resp.Body = &lruBody{
+		ReadCloser: resp.Body,
+		release: func(reusable bool) {
+			stop()
+			t.release(pc, keepAlive && reusable)
+		},
+	}
+	return resp, nil
+}
+
+// getConn returns the least recently used idle connection to the host of
+// the request, or a new one.
+func (t *lruTransport) getConn(req *http.Request) (*lruConn, error) {
+	addr := canonicalAddr(req.URL)
+	key := req.URL.Scheme + "://" + addr
+	if pc := t.popIdle(key); pc != nil {
+		t.traceGotConn(req, pc)
+		return pc, nil
+	}
+
+	conn, err := t.dial(req.Context(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL.Scheme == "https" {
+		config := t.tlsConfig.Clone()
+		if config == nil {
+			config = &tls.Config{}
+		}
+		if config.ServerName == "" {
+			config.ServerName = req.URL.Hostname()
+		}
+		config.NextProtos = []string{"http/1.1"}
+
+		ctx, cancel := context.WithTimeout(req.Context(), lruTLSHandshakeTimeout)
+		tlsConn := tls.Client(conn, config)
+		err := tlsConn.HandshakeContext(ctx)
+		cancel()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	pc := &lruConn{key: key, conn: conn, br: bufio.NewReader(conn)}
+	t.traceGotConn(req, pc)
+	return pc, nil
+}
+
+// popIdle removes the least recently used idle connection of the host
+// from the pool, closing the expired ones.
+func (t *lruTransport) popIdle(key string) *lruConn {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	conns := t.idle[key]
+	for conns != nil && conns.Len() > 0 {
+		pc := conns.Remove(conns.Front()).(*lruConn)
+		if t.clock.Now().Sub(pc.idleAt) > lruIdleConnTimeout {
+			pc.conn.Close()
+			continue
+		}
+		pc.reused = true
+		return pc
+	}
+	return nil
+}
+
+// release returns the connection to the pool if it can be reused, or
+// closes it.
+func (t *lruTransport) release(pc *lruConn, reusable bool) {
+	if !reusable {
+		pc.conn.Close()
+		return
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	conns := t.idle[pc.key]
+	if conns == nil {
+		conns = list.New()
+		t.idle[pc.key] = conns
+	}
+	if conns.Len() >= lruMaxIdleConnsPerHost {
+		pc.conn.Close()
+		return
+	}
+	pc.idleAt = t.clock.Now()
+	conns.PushBack(pc)
+}
+
+// CloseIdleConnections closes the idle connections of the transport.
+func (t *lruTransport) CloseIdleConnections() {
+	t.proxied.CloseIdleConnections()
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for key, conns := range t.idle {
+		for e := conns.Front(); e != nil; e = e.Next() {
+			e.Value.(*lruConn).conn.Close()
+		}
+		delete(t.idle, key)
+	}
+}
+
+// lruBody releases the connection of a response once its body has been
+// read or closed.
+type lruBody struct {
+	io.ReadCloser
+	release func(reusable bool)
+	once    sync.Once
+}
+
+func (b *lruBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(func() { b.release(true) })
+	} else if err != nil {
+		b.once.Do(func() { b.release(false) })
+	}
+	return n, err
+}
+
+// Close closes the body. A body that was not read entirely closes its
+// connection, rather than reading the rest of a body that may be long
+// or never end, such as an event stream.
+func (b *lruBody) Close() error {
+	released := false
+	b.once.Do(func() {
+		b.release(false)
+		released = true
+	})
+	err := b.ReadCloser.Close()
+	if released {
+		// The body fails to read the rest from the closed connection.
+		return nil
+	}
+	return err
+}
+
+// closeOnDone closes the connection when the context is done, until the
+// returned function is called.
+func closeOnDone(ctx context.Context, conn net.Conn) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// traceGotConn reports the connection obtained for the request to its
+// trace, if any.
+func (t *lruTransport) traceGotConn(req *http.Request, pc *lruConn) {
+	if trace := httptrace.ContextClientTrace(req.Context()); trace != nil && trace.GotConn != nil {
+		info := httptrace.GotConnInfo{Conn: pc.conn, Reused: pc.reused}
+		if pc.reused {
+			info.WasIdle = true
+			info.IdleTime = t.clock.Now().Sub(pc.idleAt)
+		}
+		trace.GotConn(info)
+	}
+}
+
+// canonicalAddr returns the host of a URL with its port, adding the
+// default port of the scheme if missing.
+func canonicalAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
@@ -0,0 +1,180 @@
+package http_test
+
+import (
+	"context"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// countingConn is a connection recording its writes in a shared log.
+type countingConn struct {
+	net.Conn
+	id  int
+	log *writeLog
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	c.log.record(c.id)
+	return c.Conn.Write(p)
+}
+
+// writeLog records the connections written to, in order.
+type writeLog struct {
+	mtx sync.Mutex
+	ids []int
+}
+
+func (l *writeLog) record(id int) {
+	l.mtx.Lock()
+	l.ids = append(l.ids, id)
+	l.mtx.Unlock()
+}
+
+func (l *writeLog) reset() []int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	ids := l.ids
+	l.ids = nil
+	return ids
+}
+
+func TestLRUConnReuse(t *testing.T) {
+	const conns = 3
+
+	var arrived int32
+	release := make(chan struct{})
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/burst" {
+			if atomic.AddInt32(&arrived, 1) == conns {
+				close(release)
+			}
+			<-release
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	log := &writeLog{}
+	var dials int32
+	defer http.SetDialContext(func(d *net.Dialer, ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := d.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &countingConn{Conn: conn, id: int(atomic.AddInt32(&dials, 1)), log: log}, nil
+	})()
+
+	cp := http.NewClientPool(http.WithLRUConnReuse())
+	cp.SetProxy(func(*nethttp.Request) (*url.URL, error) { return nil, nil })
+	client := cp.GetClient(5 * time.Second)
+
+	fetch := func(path string) {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "ok" {
+			t.Errorf("read body %q, want ok", body)
+		}
+	}
+
+	// Opening the connections concurrently.
+	var wg sync.WaitGroup
+	for i := 0; i < conns; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fetch("/burst")
+		}()
+	}
+	wg.Wait()
+	log.reset()
+
+	for i := 0; i < 2*conns; i++ {
+		fetch("/steady")
+	}
+
+	ids := log.reset()
+	if len(ids) != 2*conns || dials != conns {
+		t.Fatalf("sent %d requests over %d connections, want %d over %d", len(ids), dials, 2*conns, conns)
+	}
+	seen := make(map[int]bool)
+	for i, id := range ids {
+		if i < conns {
+			if seen[id] {
+				t.Fatalf("connections reused in order %v, want the least recently used first", ids)
+			}
+			seen[id] = true
+		} else if id != ids[i-conns] {
+			t.Fatalf("connections reused in order %v, want the least recently used first", ids)
+		}
+	}
+}
+
+func TestLRUConnReuseEarlyClose(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		// An endless stream, until the client goes away.
+		io.WriteString(w, "first event\n")
+		w.(nethttp.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	cp := http.NewClientPool(http.WithLRUConnReuse())
+	cp.SetProxy(func(*nethttp.Request) (*url.URL, error) { return nil, nil })
+
+	resp, err := cp.GetClient(5 * time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(resp.Body, buf); err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- resp.Body.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("closing an unfinished body blocked")
+	}
+}
+
+func TestLRUConnReuseProxy(t *testing.T) {
+	proxy := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, "proxied "+r.URL.String())
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	cp := http.NewClientPool(http.WithLRUConnReuse())
+	cp.SetProxy(nethttp.ProxyURL(proxyURL))
+
+	resp, err := cp.GetClient(5 * time.Second).Get("http://example.invalid/path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if want := "proxied http://example.invalid/path"; string(body) != want {
+		t.Fatalf("got body %q, want %q", body, want)
+	}
+}
@@ -0,0 +1,136 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// mirrorTimeout bounds the time a mirrored request can take.
+	mirrorTimeout = 30 * time.Second
+
+	// mirrorMaxBodyBytes is the size of the largest request body
+	// mirrored.
+	mirrorMaxBodyBytes = 1 << 20
+)
+
+// WithTrafficMirror makes the clients of the pool mirror a sample of
+// their requests to a shadow endpoint, for instance to compare a new
+// backend with the current one. A fraction of at most sampleRate of the
+// idempotent requests whose body is at most 1 MiB is copied and sent in
+// the background to target, a base URL whose scheme and host replace
+// the ones of the request. Once both the primary and the shadow requests
+// are done, compare is called from a background goroutine with both
+// responses, either of which is nil if its request failed.
+//
+// The body of a sampled request is read in memory before the request is
+// sent, so that the shadow request has its own copy. The shadow request
+// does not delay the primary one otherwise nor affect its result. The primary response is handed to compare as returned to the
+// caller, whose body must not be read by compare. The shadow response
+// body is drained and closed once compare returns. If target is not a
+// valid URL, no request is mirrored.
+func WithTrafficMirror(sampleRate float64, target string, compare func(primary, shadow *http.Response)) Option {
+	s := &sampler{rate: sampleRate}
+	u, err := url.Parse(target)
+
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			if err != nil {
+				return next
+			}
+			return &mirrorTransport{
+				next:    next,
+				target:  u,
+				sampler: s,
+				compare: compare,
+			}
+		})
+	}
+}
+
+// mirrorTransport mirrors a sample of the requests sent through the
+// next transport.
+type mirrorTransport struct {
+	next    http.RoundTripper
+	target  *url.URL
+	sampler *sampler
+	compare func(primary, shadow *http.Response)
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *mirrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isIdempotent(req) || req.ContentLength > mirrorMaxBodyBytes || !t.sampler.sample() {
+		return t.next.RoundTrip(req)
+	}
+
+	body, ok, err := bufferMirroredBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return t.next.RoundTrip(body)
+	}
+	req = body
+
+	// The shadow request outlives the primary one.
+	ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+	shadow, err := rewindRequest(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return t.next.RoundTrip(req)
+	}
+	shadow.URL.Scheme = t.target.Scheme
+	shadow.URL.Host = t.target.Host
+	shadow.Host = ""
+
+	primary := make(chan *http.Response, 1)
+	go func() {
+		defer cancel()
+
+		resp, err := t.next.RoundTrip(shadow)
+		if err != nil {
+			resp = nil
+		}
+		t.compare(<-primary, resp)
+		if resp != nil {
+			discardBody(resp)
+		}
+	}()
+
+	resp, err := t.next.RoundTrip(req)
+	primary <- resp
+	return resp, err
+}
+
+// bufferMirroredBody returns a copy of the request whose body is read in
+// memory, so that it can be sent again without consuming the original
+// body. If the body is larger than mirrorMaxBodyBytes, it returns false
+// with a copy of the request sending the original body.
+func bufferMirroredBody(req *http.Request) (*http.Request, bool, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, true, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, mirrorMaxBodyBytes+1))
+	if err != nil {
+		req.Body.Close()
+		return nil, false, err
+	}
+
+	breq := req.Clone(req.Context())
+	if len(body) > mirrorMaxBodyBytes {
+		breq.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(body), req.Body), Closer: req.Body}
+		return breq, false, nil
+	}
+	req.Body.Close()
+
+	breq.Body = io.NopCloser(bytes.NewReader(body))
+	breq.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return breq, true, nil
+}
@@ -0,0 +1,149 @@
+package http_test
+
+import (
+	"bytes"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestTrafficMirror(t *testing.T) {
+	var mtx sync.Mutex
+	var shadowPaths []string
+	cp := http.NewClientPool(http.WithTrafficMirror(0.5, "http://shadow.example.com", func(primary, shadow *nethttp.Response) {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		if primary == nil || primary.StatusCode != nethttp.StatusOK || shadow != nil {
+			t.Errorf("compared %v with %v, want the primary response and a failed shadow", primary, shadow)
+		}
+		shadowPaths = append(shadowPaths, primary.Request.URL.Path)
+	}))
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		if req.URL.Host == "shadow.example.com" {
+			// The shadow is slow and fails.
+			time.Sleep(100 * time.Millisecond)
+			return nil, errFailing
+		}
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+	client := cp.GetClient(time.Second)
+
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		resp, err := client.Get("http://primary.example.com/get")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	resp, err := client.Post("http://primary.example.com/post", "text/plain", strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("primary requests took %v, want them not to wait for the shadow", elapsed)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mtx.Lock()
+		n := len(shadowPaths)
+		mtx.Unlock()
+
+		if n == 10 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("mirrored %d of 20 requests, want 10", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Non-idempotent requests are not mirrored.
+	time.Sleep(150 * time.Millisecond)
+	mtx.Lock()
+	defer mtx.Unlock()
+	for _, path := range shadowPaths {
+		if path != "/get" {
+			t.Fatalf("mirrored a request to %s", path)
+		}
+	}
+}
+
+func TestTrafficMirrorFileBody(t *testing.T) {
+	echo := func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}
+	primary := httptest.NewServer(nethttp.HandlerFunc(echo))
+	defer primary.Close()
+	shadow := httptest.NewServer(nethttp.HandlerFunc(echo))
+	defer shadow.Close()
+
+	shadowBodies := make(chan []byte, 1)
+	pool := http.NewClientPool(
+		http.WithPreserveBodyOnRedirect(),
+		http.WithTrafficMirror(1, shadow.URL, func(_, shadow *nethttp.Response) {
+			var body []byte
+			if shadow != nil {
+				body, _ = io.ReadAll(shadow.Body)
+			}
+			shadowBodies <- body
+		}),
+	)
+
+	for _, size := range []int{1000, 2000000} {
+		payload := bytes.Repeat([]byte("x"), size)
+		path := filepath.Join(t.TempDir(), "body")
+		if err := os.WriteFile(path, payload, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The GetBody function set by WithPreserveBodyOnRedirect seeks
+		// the file shared with the primary request.
+		req, _ := nethttp.NewRequest(nethttp.MethodPut, primary.URL, file)
+		resp, err := pool.Do(req)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", size, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !bytes.Equal(body, payload) {
+			t.Errorf("%d bytes: primary received %d bytes", size, len(body))
+		}
+
+		// Bodies larger than 1 MiB are not mirrored.
+		if size > 1<<20 {
+			continue
+		}
+		select {
+		case body := <-shadowBodies:
+			if !bytes.Equal(body, payload) {
+				t.Errorf("%d bytes: shadow received %d bytes", size, len(body))
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%d bytes: request not mirrored", size)
+		}
+	}
+
+	select {
+	case <-shadowBodies:
+		t.Error("mirrored a body larger than 1 MiB")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
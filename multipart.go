@@ -0,0 +1,195 @@
+package http
+
+import (
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileField is a file uploaded in a multipart form.
+type FileField struct {
+	FieldName string
+	FileName  string
+	Reader    io.Reader
+}
+
+// PostMultipart posts a multipart form with the specified fields and
+// files to the URL, using a client of the pool with the specified
+// timeout. The files are streamed from their readers without being
+// buffered in memory, so the request is sent with chunked encoding
+// unless every reader is an io.Seeker. In that case the length of the
+// body is computed upfront and the request can be sent again, for
+// instance by a retry, by rewinding the readers.
+func (c *ClientPool) PostMultipart(ctx context.Context, url string, fields map[string]string, files []FileField, timeout time.Duration) (*http.Response, error) {
+	body, err := newMultipartBody(fields, files)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+body.boundary)
+
+	if body.seekable {
+		if req.ContentLength, err = body.length(); err != nil {
+			return nil, err
+		}
+		req.GetBody = body.rewind
+	} else {
+		req.ContentLength = -1
+	}
+
+	// The body is opened last, since its writer runs until the body is
+	// read or closed.
+	req.Body = body.open()
+	return c.sendTimeout(timeout, req, c.do)
+}
+
+// multipartBody streams a multipart form.
+type multipartBody struct {
+	boundary string
+	names    []string
+	fields   map[string]string
+	files    []FileField
+
+	// seekable is set if every file reader is an io.Seeker, in which
+	// case starts holds their initial offsets and sizes the number of
+	// bytes they hold from there.
+	seekable bool
+	starts   []int64
+	sizes    []int64
+
+	// reader is the reader of the last stream opened, and written is
+	// closed once its writer returns.
+	mtx     sync.Mutex
+	reader  *io.PipeReader
+	written chan struct{}
+}
+
+func newMultipartBody(fields map[string]string, files []FileField) (*multipartBody, error) {
+	b := &multipartBody{
+		boundary: multipart.NewWriter(io.Discard).Boundary(),
+		fields:   fields,
+		files:    files,
+		seekable: true,
+	}
+
+	// Fields are written in a stable order.
+	for name := range fields {
+		b.names = append(b.names, name)
+	}
+	sort.Strings(b.names)
+
+	for _, file := range files {
+		seeker, ok := file.Reader.(io.Seeker)
+		if !ok {
+			b.seekable = false
+			break
+		}
+
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		b.starts = append(b.starts, start)
+		b.sizes = append(b.sizes, end-start)
+	}
+	return b, nil
+}
+
+// open returns a reader streaming the form.
+func (b *multipartBody) open() io.ReadCloser {
+	pr, pw := io.Pipe()
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		pw.CloseWithError(b.write(pw, true))
+	}()
+
+	b.mtx.Lock()
+	b.reader, b.written = pr, written
+	b.mtx.Unlock()
+	return pr
+}
+
+// rewind seeks the files back to their initial offsets and returns a
+// new reader streaming the form. The previous stream is closed first,
+// and its writer waited for, so that it no longer reads the files.
+func (b *multipartBody) rewind() (io.ReadCloser, error) {
+	b.mtx.Lock()
+	reader, written := b.reader, b.written
+	b.mtx.Unlock()
+	if reader != nil {
+		reader.Close()
+		<-written
+	}
+
+	for i, file := range b.files {
+		if _, err := file.Reader.(io.Seeker).Seek(b.starts[i], io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	return b.open(), nil
+}
+
+// length returns the length of the form, computed by writing its
+// framing without the file contents.
+func (b *multipartBody) length() (int64, error) {
+	var counter countingWriter
+	if err := b.write(&counter, false); err != nil {
+		return 0, err
+	}
+
+	n := int64(counter)
+	for _, size := range b.sizes {
+		n += size
+	}
+	return n, nil
+}
+
+// write writes the form, with the contents of the files if requested.
+func (b *multipartBody) write(w io.Writer, contents bool) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(b.boundary); err != nil {
+		return err
+	}
+
+	for _, name := range b.names {
+		if err := mw.WriteField(name, b.fields[name]); err != nil {
+			return err
+		}
+	}
+	for _, file := range b.files {
+		part, err := mw.CreateFormFile(file.FieldName, file.FileName)
+		if err != nil {
+			return err
+		}
+		if contents {
+			if _, err := io.Copy(part, file.Reader); err != nil {
+				return err
+			}
+		}
+	}
+	return mw.Close()
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
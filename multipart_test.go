@@ -0,0 +1,165 @@
+package http_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// multipartEcho echoes the parts of the received form, one per line, and
+// the transfer encoding used to send it.
+func multipartEcho(w nethttp.ResponseWriter, r *nethttp.Request) {
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		nethttp.Error(w, err.Error(), nethttp.StatusBadRequest)
+		return
+	}
+
+	var lines []string
+	for name, values := range r.MultipartForm.Value {
+		lines = append(lines, fmt.Sprintf("field %s=%s", name, values[0]))
+	}
+	for name, headers := range r.MultipartForm.File {
+		f, _ := headers[0].Open()
+		content, _ := io.ReadAll(f)
+		f.Close()
+		lines = append(lines, fmt.Sprintf("file %s:%s=%s", name, headers[0].Filename, content))
+	}
+	sort.Strings(lines)
+
+	fmt.Fprintf(w, "encoding=%v length=%d\n", r.TransferEncoding, r.ContentLength)
+	io.WriteString(w, strings.Join(lines, "\n"))
+}
+
+func TestPostMultipartStreaming(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(multipartEcho))
+	defer server.Close()
+
+	// A pipe is not seekable, so its size is unknown.
+	pr, pw := io.Pipe()
+	go func() {
+		io.WriteString(pw, "streamed content")
+		pw.Close()
+	}()
+
+	resp, err := http.NewClientPool().PostMultipart(context.Background(), server.URL,
+		map[string]string{"name": "value", "other": "x"},
+		[]http.FileField{{FieldName: "upload", FileName: "a.txt", Reader: pr}},
+		5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	want := "encoding=[chunked] length=-1\n" +
+		"field name=value\nfield other=x\nfile upload:a.txt=streamed content"
+	if string(body) != want {
+		t.Fatalf("echo = %q, want %q", body, want)
+	}
+}
+
+func TestPostMultipartSeekable(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		// Fail the first attempt to force a retry.
+		if atomic.AddInt32(&calls, 1) == 1 {
+			io.Copy(io.Discard, r.Body)
+			w.WriteHeader(nethttp.StatusServiceUnavailable)
+			return
+		}
+		multipartEcho(w, r)
+	}))
+	defer server.Close()
+
+	cp := http.NewClientPool(http.WithRetry(2, time.Millisecond,
+		http.WithRetryClassifier(func(req *nethttp.Request, resp *nethttp.Response, err error) bool {
+			return err == nil && resp.StatusCode == nethttp.StatusServiceUnavailable
+		}),
+	))
+
+	resp, err := cp.PostMultipart(context.Background(), server.URL, nil,
+		[]http.FileField{
+			{FieldName: "first", FileName: "a.txt", Reader: strings.NewReader("alpha")},
+			{FieldName: "second", FileName: "b.txt", Reader: strings.NewReader("beta")},
+		},
+		5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if calls != 2 {
+		t.Fatalf("server called %d times, want 2", calls)
+	}
+	lines := strings.SplitN(string(body), "\n", 2)
+	if !strings.HasPrefix(lines[0], "encoding=[] length=") || strings.HasSuffix(lines[0], "=-1") {
+		t.Fatalf("got %q, want a known length", lines[0])
+	}
+	if want := "file first:a.txt=alpha\nfile second:b.txt=beta"; lines[1] != want {
+		t.Fatalf("echo = %q, want %q", lines[1], want)
+	}
+}
+
+func TestPostMultipartInvalidRequest(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		_, err := http.NewClientPool().PostMultipart(context.Background(), "http://[::1", map[string]string{"name": "value"},
+			[]http.FileField{{FieldName: "upload", FileName: "a.txt", Reader: strings.NewReader("content")}},
+			5*time.Second)
+		if err == nil {
+			t.Fatal("expected an invalid URL error")
+		}
+	}
+
+	// No writer is left blocked on a body that is never read.
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left running, want %d", runtime.NumGoroutine(), before)
+		}
+	}
+}
+
+func TestPostMultipartRewindWhileReading(t *testing.T) {
+	content := strings.Repeat("0123456789", 10000)
+	var rewound string
+	cp := http.NewClientPool()
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		// The first stream is abandoned while its writer is copying
+		// the file.
+		io.ReadFull(req.Body, make([]byte, 1000))
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := req.Body.Read(make([]byte, 1)); err == nil {
+			t.Error("previous stream still readable after rewinding")
+		}
+
+		all, _ := io.ReadAll(body)
+		rewound = string(all)
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+
+	resp, err := cp.PostMultipart(context.Background(), "http://example.com/", nil,
+		[]http.FileField{{FieldName: "upload", FileName: "a.txt", Reader: strings.NewReader(content)}},
+		5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if !strings.Contains(rewound, "\r\n\r\n"+content+"\r\n") {
+		t.Fatal("rewound stream does not hold the whole file")
+	}
+}
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+)
+
+// SetOnNewConnection sets a function called every time the clients of
+// the pool open a new connection, with the network and remote address of
+// the connection and whether it is a TLS connection, in which case the
+// function is called after the TLS handshake. The function is called in
+// its own goroutine so that it does not delay the requests, and must be
+// safe for concurrent use. A nil function removes the callback.
+//
+// The connections are reported when handed to their first request, and
+// only by transports supporting the httptrace package, as the default
+// transport does.
+func (c *ClientPool) SetOnNewConnection(fn func(network, remoteAddr string, tls bool)) {
+	c.mtx.Lock()
+	{
+		c.onNewConn = fn
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+	c.mtx.Unlock()
+}
+
+// newConnTransport reports the new connections used by the requests sent
+// through the next transport.
+type newConnTransport struct {
+	next      http.RoundTripper
+	onNewConn func(network, remoteAddr string, tls bool)
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *newConnTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused || info.Conn == nil {
+				return
+			}
+
+			addr := info.Conn.RemoteAddr()
+			_, isTLS := info.Conn.(*tls.Conn)
+			go t.onNewConn(addr.Network(), addr.String(), isTLS)
+		},
+	}
+	return t.next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
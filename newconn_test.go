@@ -0,0 +1,75 @@
+package http_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// newConn is a connection reported to the OnNewConnection callback.
+type newConn struct {
+	network, remoteAddr string
+	tls                 bool
+}
+
+// connNotifications returns a callback sending the new connections to
+// the returned channel.
+func connNotifications() (func(network, remoteAddr string, tls bool), chan newConn) {
+	conns := make(chan newConn, 16)
+	return func(network, remoteAddr string, tls bool) {
+		conns <- newConn{network: network, remoteAddr: remoteAddr, tls: tls}
+	}, conns
+}
+
+func TestOnNewConnection(t *testing.T) {
+	handler := nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, "ok")
+	})
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+	secure := httptest.NewTLSServer(handler)
+	defer secure.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(secure.Certificate())
+
+	fn, conns := connNotifications()
+	cp := http.NewClientPool()
+	cp.SetDefaultTLSConfig(&tls.Config{RootCAs: roots})
+	cp.SetOnNewConnection(fn)
+	client := cp.GetClient(time.Second)
+
+	// The second request to each server reuses the connection.
+	for _, tt := range []struct {
+		server *httptest.Server
+		tls    bool
+	}{
+		{plain, false},
+		{secure, true},
+	} {
+		getDiscard(t, client, tt.server.URL)
+		getDiscard(t, client, tt.server.URL)
+
+		want := newConn{network: "tcp", remoteAddr: tt.server.Listener.Addr().String(), tls: tt.tls}
+		select {
+		case got := <-conns:
+			if got != want {
+				t.Errorf("got connection %+v, want %+v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no connection reported for %s", tt.server.URL)
+		}
+	}
+
+	select {
+	case got := <-conns:
+		t.Errorf("unexpected connection %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,24 @@
+package http
+
+import (
+	"net/http"
+
+	"golang.org/x/oauth2"
+)
+
+// WithOAuth2 makes the clients of the pool authorize their requests with
+// the tokens of the specified source. Tokens are cached and refreshed
+// when they expire, and are sent using the transport of the pool, not
+// http.DefaultTransport.
+func WithOAuth2(ts oauth2.TokenSource) Option {
+	ts = oauth2.ReuseTokenSource(nil, ts)
+
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			return &oauth2.Transport{
+				Source: ts,
+				Base:   next,
+			}
+		})
+	}
+}
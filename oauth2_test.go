@@ -0,0 +1,69 @@
+package http_test
+
+import (
+	"fmt"
+	nethttp "net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/Updater/http"
+)
+
+// sequenceTokenSource returns a new token every time it is called. The
+// token expiry is given by the function.
+type sequenceTokenSource struct {
+	expiry func(n int) time.Time
+
+	mtx   sync.Mutex
+	calls int
+}
+
+func (s *sequenceTokenSource) Token() (*oauth2.Token, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.calls++
+	return &oauth2.Token{
+		AccessToken: fmt.Sprintf("token-%d", s.calls),
+		TokenType:   "Bearer",
+		Expiry:      s.expiry(s.calls),
+	}, nil
+}
+
+func TestOAuth2(t *testing.T) {
+	source := &sequenceTokenSource{expiry: func(n int) time.Time {
+		if n == 1 {
+			// The first token expires right away.
+			return time.Now()
+		}
+		return time.Now().Add(time.Hour)
+	}}
+
+	var headers []string
+	cp := http.NewClientPool(http.WithOAuth2(source))
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		headers = append(headers, req.Header.Get("Authorization"))
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+
+	client := cp.GetClient(time.Second)
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get("http://example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	want := []string{"Bearer token-1", "Bearer token-2", "Bearer token-2"}
+	if !reflect.DeepEqual(headers, want) {
+		t.Fatalf("Authorization headers = %q, want %q", headers, want)
+	}
+	if source.calls != 2 {
+		t.Fatalf("token source called %d times, want 2", source.calls)
+	}
+}
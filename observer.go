@@ -0,0 +1,253 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Observer receives events about the requests made by the clients of a
+// pool. Observers must be safe for concurrent use.
+type Observer interface {
+	// RequestCompleted is called when a response has been received or
+	// the request failed. The status class is the first digit of the
+	// status code, or 0 if the request failed with an error.
+	RequestCompleted(statusClass int, err error, d time.Duration)
+}
+
+// RouteObserver is an Observer that also receives the route of the
+// completed requests, as derived by the route classifier of the pool.
+// RouteCompleted is only called if a route classifier is set.
+type RouteObserver interface {
+	Observer
+
+	// RouteCompleted is called along with RequestCompleted with the
+	// route of the request.
+	RouteCompleted(route string, statusClass int, err error, d time.Duration)
+}
+
+// DNSObserver is an Observer that also receives the duration of the DNS
+// resolutions made to establish the connections of the requests.
+type DNSObserver interface {
+	Observer
+
+	// DNSResolveDuration is called once the host has been resolved.
+	// Coalesced reports whether the resolver shared the result of a
+	// concurrent lookup of the same host.
+	DNSResolveDuration(host string, d time.Duration, coalesced bool)
+}
+
+// TTFBObserver is an Observer that also receives the time to first byte
+// of the requests, the time the server took to start answering once the
+// request was written, excluding the time spent establishing the
+// connection and transferring the response body.
+type TTFBObserver interface {
+	Observer
+
+	// TimeToFirstByte is called when the first byte of the response
+	// from the host is read, with the time elapsed since the request
+	// was written. It is called for every attempt of a retried
+	// request.
+	TimeToFirstByte(host string, d time.Duration)
+}
+
+// RetryObserver is an Observer that also receives the retries made by
+// the retry transport installed with WithRetry, to tell whether they are
+// worth the load they add.
+type RetryObserver interface {
+	Observer
+
+	// RetryAttempted is called before a request to the host is sent
+	// again, attempt being the number of the attempt about to be made,
+	// starting at 2.
+	RetryAttempted(host string, attempt int)
+
+	// RetrySucceeded is called when a request to the host that was
+	// retried eventually got a response that is not to be retried,
+	// after the specified total number of attempts.
+	RetrySucceeded(host string, attempts int)
+}
+
+// retryObserversKey is the context key for the retry observers of a
+// request.
+type retryObserversKey struct{}
+
+// retryObservers returns the retry observers of a request.
+func retryObservers(req *http.Request) []RetryObserver {
+	observers, _ := req.Context().Value(retryObserversKey{}).([]RetryObserver)
+	return observers
+}
+
+// WithObserver registers an observer notified about every request made
+// by the clients of the pool. Observers are notified in the order they
+// were registered.
+func WithObserver(observer Observer) Option {
+	return func(c *ClientPool) {
+		c.observers = append(c.observers, observer)
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+}
+
+// observerTransport notifies observers about the requests sent through
+// the next transport.
+type observerTransport struct {
+	next      http.RoundTripper
+	observers []Observer
+	route     func(*http.Request) string
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *observerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = t.traceDNS(req)
+	req = t.traceTTFB(req)
+	req = t.observeRetries(req)
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start)
+
+	class := 0
+	if err == nil {
+		class = resp.StatusCode / 100
+	}
+	var route string
+	if t.route != nil {
+		route = t.route(req)
+	}
+
+	for _, observer := range t.observers {
+		observer.RequestCompleted(class, err, elapsed)
+		if ro, ok := observer.(RouteObserver); ok && t.route != nil {
+			ro.RouteCompleted(route, class, err, elapsed)
+		}
+	}
+
+	return resp, err
+}
+
+// traceDNS returns the request with a trace notifying the DNS observers,
+// if any, about its DNS resolutions.
+func (t *observerTransport) traceDNS(req *http.Request) *http.Request {
+	var observers []DNSObserver
+	for _, observer := range t.observers {
+		if do, ok := observer.(DNSObserver); ok {
+			observers = append(observers, do)
+		}
+	}
+	if len(observers) == 0 {
+		return req
+	}
+
+	// A request establishes at most one connection, resolving a single
+	// host.
+	var mtx sync.Mutex
+	var host string
+	var start time.Time
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			mtx.Lock()
+			host, start = info.Host, time.Now()
+			mtx.Unlock()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			mtx.Lock()
+			h, d := host, time.Since(start)
+			mtx.Unlock()
+
+			for _, observer := range observers {
+				observer.DNSResolveDuration(h, d, info.Coalesced)
+			}
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// traceTTFB returns the request with a trace notifying the TTFB
+// observers, if any, about its time to first byte.
+func (t *observerTransport) traceTTFB(req *http.Request) *http.Request {
+	var observers []TTFBObserver
+	for _, observer := range t.observers {
+		if to, ok := observer.(TTFBObserver); ok {
+			observers = append(observers, to)
+		}
+	}
+	if len(observers) == 0 {
+		return req
+	}
+
+	host := req.URL.Host
+	var mtx sync.Mutex
+	var wrote time.Time
+	trace := &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			mtx.Lock()
+			wrote = time.Now()
+			mtx.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			mtx.Lock()
+			d := time.Since(wrote)
+			mtx.Unlock()
+
+			for _, observer := range observers {
+				observer.TimeToFirstByte(host, d)
+			}
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// observeRetries returns the request carrying the retry observers, if
+// any, for the retry transport.
+func (t *observerTransport) observeRetries(req *http.Request) *http.Request {
+	var observers []RetryObserver
+	for _, observer := range t.observers {
+		if ro, ok := observer.(RetryObserver); ok {
+			observers = append(observers, ro)
+		}
+	}
+	if len(observers) == 0 {
+		return req
+	}
+
+	return req.WithContext(context.WithValue(req.Context(), retryObserversKey{}, observers))
+}
+
+// StatusCollector is an Observer counting the completed requests by
+// status class.
+type StatusCollector struct {
+	mtx    sync.Mutex
+	counts map[int]uint64
+}
+
+// NewStatusCollector returns a new, empty StatusCollector.
+func NewStatusCollector() *StatusCollector {
+	return &StatusCollector{
+		counts: make(map[int]uint64),
+	}
+}
+
+// RequestCompleted implements the Observer interface.
+func (s *StatusCollector) RequestCompleted(statusClass int, err error, d time.Duration) {
+	s.mtx.Lock()
+	s.counts[statusClass]++
+	s.mtx.Unlock()
+}
+
+// Counts returns the number of completed requests by status class. The
+// returned map is a copy and can be freely modified.
+func (s *StatusCollector) Counts() map[int]uint64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	counts := make(map[int]uint64, len(s.counts))
+	for class, n := range s.counts {
+		counts[class] = n
+	}
+	return counts
+}
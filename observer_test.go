@@ -0,0 +1,220 @@
+package http_test
+
+import (
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestStatusCollector(t *testing.T) {
+	collector := http.NewStatusCollector()
+	cp := http.NewClientPool(http.WithObserver(collector))
+
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		switch req.URL.Path {
+		case "/ok":
+			return statusResponse(req, nethttp.StatusOK), nil
+		case "/missing":
+			return statusResponse(req, nethttp.StatusNotFound), nil
+		case "/broken":
+			return statusResponse(req, nethttp.StatusBadGateway), nil
+		}
+		return nil, errors.New("connection refused")
+	}))
+	client := cp.GetClient(time.Second)
+
+	paths := []string{"/ok", "/ok", "/ok", "/missing", "/broken", "/broken", "/fail"}
+	for _, path := range paths {
+		resp, err := client.Get("http://example.com" + path)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+
+	want := map[int]uint64{0: 1, 2: 3, 4: 1, 5: 2}
+	if got := collector.Counts(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("counts = %v, want %v", got, want)
+	}
+}
+
+// dnsRecorder is a DNSObserver recording the DNS resolutions.
+type dnsRecorder struct {
+	mtx       sync.Mutex
+	hosts     []string
+	durations []time.Duration
+	coalesced []bool
+}
+
+func (r *dnsRecorder) RequestCompleted(int, error, time.Duration) {}
+
+func (r *dnsRecorder) DNSResolveDuration(host string, d time.Duration, coalesced bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.hosts = append(r.hosts, host)
+	r.durations = append(r.durations, d)
+	r.coalesced = append(r.coalesced, coalesced)
+}
+
+// slowResolverTransport simulates a resolver taking delay to resolve the
+// host of every request, reporting it to the trace of the request.
+func slowResolverTransport(delay time.Duration, coalesced bool) nethttp.RoundTripper {
+	return roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		if trace := httptrace.ContextClientTrace(req.Context()); trace != nil {
+			trace.DNSStart(httptrace.DNSStartInfo{Host: req.URL.Hostname()})
+			time.Sleep(delay)
+			trace.DNSDone(httptrace.DNSDoneInfo{Coalesced: coalesced})
+		}
+		return statusResponse(req, nethttp.StatusOK), nil
+	})
+}
+
+func TestDNSObserver(t *testing.T) {
+	recorder := &dnsRecorder{}
+	cp := http.NewClientPool(http.WithObserver(recorder))
+	cp.SetTransport(slowResolverTransport(50*time.Millisecond, true))
+
+	resp, err := cp.GetClient(time.Second).Get("http://slow.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(recorder.hosts) != 1 || recorder.hosts[0] != "slow.example.com" {
+		t.Fatalf("got resolutions of %v, want slow.example.com", recorder.hosts)
+	}
+	if d := recorder.durations[0]; d < 50*time.Millisecond || d > time.Second {
+		t.Errorf("DNS duration = %v, want about 50ms", d)
+	}
+	if !recorder.coalesced[0] {
+		t.Error("expected the resolution to be reported as coalesced")
+	}
+}
+
+func TestDNSObserverLookup(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	defer server.Close()
+
+	recorder := &dnsRecorder{}
+	cp := http.NewClientPool(http.WithObserver(recorder))
+	cp.SetProxy(func(*nethttp.Request) (*url.URL, error) { return nil, nil })
+
+	resp, err := cp.GetClient(time.Second).Get(strings.Replace(server.URL, "127.0.0.1", "localhost", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(recorder.hosts) != 1 || recorder.hosts[0] != "localhost" {
+		t.Fatalf("got resolutions of %v, want localhost", recorder.hosts)
+	}
+}
+
+// retryRecorder is a RetryObserver recording the retries.
+type retryRecorder struct {
+	mtx       sync.Mutex
+	attempted []string
+	succeeded []string
+}
+
+func (r *retryRecorder) RequestCompleted(int, error, time.Duration) {}
+
+func (r *retryRecorder) RetryAttempted(host string, attempt int) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.attempted = append(r.attempted, fmt.Sprintf("%s#%d", host, attempt))
+}
+
+func (r *retryRecorder) RetrySucceeded(host string, attempts int) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.succeeded = append(r.succeeded, fmt.Sprintf("%s#%d", host, attempts))
+}
+
+func TestRetryObserver(t *testing.T) {
+	tests := []struct {
+		failures  int
+		attempted string
+		succeeded string
+	}{
+		{failures: 0, attempted: "[]", succeeded: "[]"},
+		{failures: 2, attempted: "[example.com#2 example.com#3]", succeeded: "[example.com#3]"},
+		{failures: 5, attempted: "[example.com#2 example.com#3]", succeeded: "[]"},
+	}
+
+	for _, tt := range tests {
+		var calls int32
+		recorder := &retryRecorder{}
+		cp := http.NewClientPool(http.WithRetry(3, time.Millisecond), http.WithObserver(recorder))
+		cp.SetTransport(failingTransport(tt.failures, nethttp.StatusServiceUnavailable, &calls))
+
+		resp, err := cp.GetClient(time.Second).Get("http://example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if got := fmt.Sprint(recorder.attempted); got != tt.attempted {
+			t.Errorf("%d failures: got retries %s, want %s", tt.failures, got, tt.attempted)
+		}
+		if got := fmt.Sprint(recorder.succeeded); got != tt.succeeded {
+			t.Errorf("%d failures: got successes %s, want %s", tt.failures, got, tt.succeeded)
+		}
+	}
+}
+
+// ttfbRecorder is a TTFBObserver recording the times to first byte.
+type ttfbRecorder struct {
+	mtx   sync.Mutex
+	hosts []string
+	ttfb  []time.Duration
+}
+
+func (r *ttfbRecorder) RequestCompleted(int, error, time.Duration) {}
+
+func (r *ttfbRecorder) TimeToFirstByte(host string, d time.Duration) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.hosts = append(r.hosts, host)
+	r.ttfb = append(r.ttfb, d)
+}
+
+func TestTTFBObserver(t *testing.T) {
+	// The server thinks before sending the headers, then takes longer to
+	// send the body.
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(nethttp.StatusOK)
+		w.(nethttp.Flusher).Flush()
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	recorder := &ttfbRecorder{}
+	cp := http.NewClientPool(http.WithObserver(recorder))
+	getDiscard(t, cp.GetClient(time.Second), server.URL)
+
+	recorder.mtx.Lock()
+	defer recorder.mtx.Unlock()
+
+	if want := strings.TrimPrefix(server.URL, "http://"); len(recorder.hosts) != 1 || recorder.hosts[0] != want {
+		t.Fatalf("got times to first byte of %v, want %s", recorder.hosts, want)
+	}
+	if d := recorder.ttfb[0]; d < 50*time.Millisecond || d >= 150*time.Millisecond {
+		t.Errorf("time to first byte = %v, want about 50ms", d)
+	}
+}
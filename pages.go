@@ -0,0 +1,111 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrTooManyPages is returned by GetAllPages when the resource has more
+// pages than it follows.
+var ErrTooManyPages = errors.New("http: too many pages")
+
+// maxPages is the number of pages followed by GetAllPages.
+var maxPages = 1000
+
+// GetAllPages fetches all the pages of a paginated resource, starting
+// with the page at the specified URL and following the links with the
+// "next" relation of the Link header of every page, as specified by RFC
+// 8288, until a page has none. Every page is fetched using a client of
+// the pool with the specified timeout and passed to each, whose error
+// stops the pagination and is returned as is. The body of every page is
+// drained and closed once each returns, as with DoAndDrain.
+//
+// It fails with the error of the context once it is done, and with
+// ErrTooManyPages once 1000 pages have been fetched, protecting from
+// servers linking pages in a loop.
+func (c *ClientPool) GetAllPages(ctx context.Context, url string, timeout time.Duration, each func(*http.Response) error) error {
+	for pages := 0; url != ""; pages++ {
+		if pages >= maxPages {
+			return ErrTooManyPages
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		url = ""
+		err = c.DoAndDrain(ctx, req, timeout, func(resp *http.Response) error {
+			if err := each(resp); err != nil {
+				return err
+			}
+			if next := nextLink(resp.Header); next != "" {
+				u, err := resp.Request.URL.Parse(next)
+				if err != nil {
+					return err
+				}
+				url = u.String()
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextLink returns the target of the link with the "next" relation in
+// the Link header, or an empty string.
+func nextLink(header http.Header) string {
+	for _, value := range header.Values("Link") {
+		for _, link := range splitLinks(value) {
+			link = strings.TrimSpace(link)
+			end := strings.IndexByte(link, '>')
+			if !strings.HasPrefix(link, "<") || end < 0 {
+				continue
+			}
+
+			for _, param := range strings.Split(link[end+1:], ";") {
+				name, value, ok := strings.Cut(param, "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(name), "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(value), `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return link[1:end]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// splitLinks splits the value of a Link header into its links, ignoring
+// the commas of the link targets and of the quoted parameters.
+func splitLinks(value string) []string {
+	var links []string
+	inTarget, inQuotes := false, false
+	start := 0
+	for i, r := range value {
+		switch {
+		case r == '<' && !inQuotes:
+			inTarget = true
+		case r == '>' && !inQuotes:
+			inTarget = false
+		case r == '"' && !inTarget:
+			inQuotes = !inQuotes
+		case r == ',' && !inTarget && !inQuotes:
+			links = append(links, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(links, value[start:])
+}
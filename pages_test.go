@@ -0,0 +1,93 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// newPagesServer returns a server paginating a resource of the specified
+// number of pages, or of infinitely many pages if negative.
+func newPagesServer(pages int) *httptest.Server {
+	return httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		if pages < 0 || page < pages {
+			// The next link is relative, and listed after another link
+			// whose target contains a comma.
+			w.Header().Add("Link", `<https://example.com/docs,v2>; rel="help"`)
+			w.Header().Add("Link", fmt.Sprintf(`</items?page=1>; rel="first", </items?page=%d>; title="Next; page"; rel="next last"`, page+1))
+		}
+		fmt.Fprintf(w, "page %d", page)
+	}))
+}
+
+func TestGetAllPages(t *testing.T) {
+	server := newPagesServer(4)
+	defer server.Close()
+
+	var pages []string
+	pool := http.NewClientPool()
+	err := pool.GetAllPages(context.Background(), server.URL+"/items", time.Second, func(resp *nethttp.Response) error {
+		body, err := io.ReadAll(resp.Body)
+		pages = append(pages, string(body))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(pages); got != "[page 1 page 2 page 3 page 4]" {
+		t.Errorf("got pages %s, want [page 1 page 2 page 3 page 4]", got)
+	}
+}
+
+func TestGetAllPagesStops(t *testing.T) {
+	server := newPagesServer(-1)
+	defer server.Close()
+	defer http.SetMaxPages(10)()
+
+	pool := http.NewClientPool()
+	pages := 0
+	err := pool.GetAllPages(context.Background(), server.URL+"/items", time.Second, func(*nethttp.Response) error {
+		pages++
+		return nil
+	})
+	if !errors.Is(err, http.ErrTooManyPages) || pages != 10 {
+		t.Errorf("got %d pages and error %v, want 10 pages and %v", pages, err, http.ErrTooManyPages)
+	}
+
+	// The errors of the callback and of the context stop the pagination.
+	errStop := errors.New("stop")
+	pages = 0
+	err = pool.GetAllPages(context.Background(), server.URL+"/items", time.Second, func(*nethttp.Response) error {
+		if pages++; pages == 3 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) || pages != 3 {
+		t.Errorf("got %d pages and error %v, want 3 pages and %v", pages, err, errStop)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pages = 0
+	err = pool.GetAllPages(ctx, server.URL+"/items", time.Second, func(*nethttp.Response) error {
+		if pages++; pages == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || pages != 2 {
+		t.Errorf("got %d pages and error %v, want 2 pages and %v", pages, err, context.Canceled)
+	}
+}
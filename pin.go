@@ -0,0 +1,104 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrPublicKeyPinMismatch is matched by the errors of the TLS handshakes
+// with the hosts whose certificates match none of the pins set with
+// SetPinnedPublicKeys.
+var ErrPublicKeyPinMismatch = errors.New("http: no certificate public key matches the pins")
+
+// PublicKeyPin returns the pin of the public key of a certificate, the
+// SHA-256 hash of its DER-encoded SubjectPublicKeyInfo, as used by
+// SetPinnedPublicKeys.
+func PublicKeyPin(cert *x509.Certificate) []byte {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return sum[:]
+}
+
+// SetPinnedPublicKeys pins the public keys accepted from a host by the
+// default transport: the TLS handshakes with the host fail with an error
+// matching ErrPublicKeyPinMismatch unless the public key of one of the
+// certificates of the verified chains, or of the certificate of the
+// host if the verification is skipped, matches one of the pins, see
+// PublicKeyPin. Backup pins, for instance of the next key of the host or
+// of its CA, should be included so that rotating the key does not cause
+// an outage. The pins are checked in addition to the
+// usual verification of the certificates, on every handshake, resumed
+// ones included. An empty list of pins removes the pins of the host.
+//
+// Hosts are matched by the server name of the TLS connections, without
+// the port, so hosts addressed by IP address cannot be pinned.
+// Transports set with SetTransport are not affected.
+func (c *ClientPool) SetPinnedPublicKeys(host string, sha256Pins [][]byte) {
+	host = strings.ToLower(host)
+
+	c.mtx.Lock()
+	{
+		// The map is copied so that the configurations of the existing
+		// transports keep checking the pins they were created with.
+		pins := make(map[string][][]byte, len(c.pins)+1)
+		for h, p := range c.pins {
+			pins[h] = p
+		}
+		if len(sha256Pins) == 0 {
+			delete(pins, host)
+		} else {
+			pins[host] = append([][]byte(nil), sha256Pins...)
+		}
+		c.pins = pins
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+	c.mtx.Unlock()
+
+	c.warmup()
+}
+
+// verifyPins returns the function verifying the pins of the connections,
+// chained to the one of the configuration, if any.
+func verifyPins(pins map[string][][]byte, next func(tls.ConnectionState) error) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if host := strings.ToLower(cs.ServerName); len(pins[host]) > 0 && !matchPins(cs, pins[host]) {
+			return fmt.Errorf("%w of %s", ErrPublicKeyPinMismatch, host)
+		}
+		if next != nil {
+			return next(cs)
+		}
+		return nil
+	}
+}
+
+// matchPins reports whether the public key of one of the certificates
+// of the connection matches one of the pins. Only the certificates of
+// the verified chains are trusted, since the server can present any
+// certificate in addition to its own, or only the certificate of the
+// server when the verification is skipped.
+func matchPins(cs tls.ConnectionState, pins [][]byte) bool {
+	var certs []*x509.Certificate
+	for _, chain := range cs.VerifiedChains {
+		certs = append(certs, chain...)
+	}
+	if len(cs.VerifiedChains) == 0 && len(cs.PeerCertificates) > 0 {
+		certs = cs.PeerCertificates[:1]
+	}
+
+	for _, cert := range certs {
+		pin := PublicKeyPin(cert)
+		for _, p := range pins {
+			if bytes.Equal(pin, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
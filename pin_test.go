@@ -0,0 +1,165 @@
+package http_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// newPinnedPool returns a pool trusting the TLS server, whose
+// connections to any host are made to the server.
+func newPinnedPool(t *testing.T, server *httptest.Server) *http.ClientPool {
+	restore := http.SetDialContext(func(d *net.Dialer, ctx context.Context, network, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, network, server.Listener.Addr().String())
+	})
+	t.Cleanup(restore)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	cp := http.NewClientPool()
+	cp.SetDefaultTLSConfig(&tls.Config{RootCAs: roots})
+	return cp
+}
+
+func TestPinnedPublicKeys(t *testing.T) {
+	server := httptest.NewTLSServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	defer server.Close()
+	cp := newPinnedPool(t, server)
+
+	unknown := sha256.Sum256([]byte("another key"))
+	pin := http.PublicKeyPin(server.Certificate())
+
+	// The backup pin matches.
+	cp.SetPinnedPublicKeys("example.com", [][]byte{unknown[:], pin})
+	resp, err := cp.GetClient(time.Second).Get("https://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	cp.SetPinnedPublicKeys("Example.com", [][]byte{unknown[:]})
+	if _, err := cp.GetClient(time.Second).Get("https://example.com/"); !errors.Is(err, http.ErrPublicKeyPinMismatch) {
+		t.Fatalf("error = %v, want %v", err, http.ErrPublicKeyPinMismatch)
+	}
+
+	// Other hosts are not pinned.
+	resp, err = cp.GetClient(time.Second).Get("https://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	cp.SetPinnedPublicKeys("example.com", nil)
+	resp, err = cp.GetClient(time.Second).Get("https://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestPinnedPublicKeysChained(t *testing.T) {
+	server := httptest.NewTLSServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	defer server.Close()
+	cp := newPinnedPool(t, server)
+
+	errRefused := errors.New("refused")
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	cp.SetDefaultTLSConfig(&tls.Config{
+		RootCAs:          roots,
+		VerifyConnection: func(tls.ConnectionState) error { return errRefused },
+	})
+	cp.SetPinnedPublicKeys("example.com", [][]byte{http.PublicKeyPin(server.Certificate())})
+
+	// The verification of the configuration still applies.
+	if _, err := cp.GetClient(time.Second).Get("https://example.com/"); !errors.Is(err, errRefused) {
+		t.Fatalf("error = %v, want %v", err, errRefused)
+	}
+}
+
+// issueCertificate returns a certificate for example.com signed by the
+// CA, or self-signed if the CA is nil.
+func issueCertificate(t *testing.T, ca *tls.Certificate, isCA bool) *tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "example.com"},
+		DNSNames:              []string{"example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	parent, signer := template, interface{}(key)
+	if ca != nil {
+		parent, signer = ca.Leaf, ca.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestPinnedPublicKeysPresentedOnly(t *testing.T) {
+	// The server presents a certificate issued by a trusted CA, along
+	// with the pinned certificate, which is not part of its chain.
+	ca := issueCertificate(t, nil, true)
+	pinned := issueCertificate(t, nil, false)
+	presented := issueCertificate(t, ca, false)
+	presented.Certificate = append(presented.Certificate, pinned.Certificate[0])
+
+	server := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{*presented}}
+	server.StartTLS()
+	defer server.Close()
+
+	cp := newPinnedPool(t, server)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+
+	for _, config := range []*tls.Config{{RootCAs: roots}, {InsecureSkipVerify: true}} {
+		cp.SetDefaultTLSConfig(config)
+		cp.SetPinnedPublicKeys("example.com", [][]byte{http.PublicKeyPin(pinned.Leaf)})
+		if _, err := cp.GetClient(time.Second).Get("https://example.com/"); !errors.Is(err, http.ErrPublicKeyPinMismatch) {
+			t.Fatalf("InsecureSkipVerify %v: error = %v, want %v", config.InsecureSkipVerify, err, http.ErrPublicKeyPinMismatch)
+		}
+
+		// The pin of the CA matches only if the chain was verified.
+		cp.SetPinnedPublicKeys("example.com", [][]byte{http.PublicKeyPin(ca.Leaf)})
+		resp, err := cp.GetClient(time.Second).Get("https://example.com/")
+		if config.InsecureSkipVerify {
+			if !errors.Is(err, http.ErrPublicKeyPinMismatch) {
+				t.Fatalf("InsecureSkipVerify: error = %v, want %v", err, http.ErrPublicKeyPinMismatch)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,60 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// lookupIPAddr resolves the hosts pinned with PinHost. It is a variable
+// so that tests can control the resolution.
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// pinnedHostsKey is the context key for the addresses of the hosts
+// pinned with PinHost.
+type pinnedHostsKey struct{}
+
+// PinHost resolves the host once and returns a copy of the context in
+// which the host is pinned to the first address it resolved to: the
+// connections the default transport establishes to the host for the
+// requests sent with the context are made to that address, so that the
+// requests of a single operation reach the same server even if the
+// resolution of the host changes. The host is given without port, and
+// several hosts can be pinned in the same context.
+//
+// The requests can still reuse the idle connections established to the
+// host from other contexts, which may have been made to other
+// addresses. Transports set with SetTransport are not affected.
+func PinHost(ctx context.Context, host string) (context.Context, error) {
+	addrs, err := lookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("http: no address found for %s", host)
+	}
+
+	previous, _ := ctx.Value(pinnedHostsKey{}).(map[string]string)
+	pinned := make(map[string]string, len(previous)+1)
+	for h, ip := range previous {
+		pinned[h] = ip
+	}
+	pinned[strings.ToLower(host)] = addrs[0].IP.String()
+	return context.WithValue(ctx, pinnedHostsKey{}, pinned), nil
+}
+
+// pinnedDial returns the dial function connecting to the addresses of
+// the hosts pinned in the context of the dials.
+func pinnedDial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if pinned, ok := ctx.Value(pinnedHostsKey{}).(map[string]string); ok {
+			if host, port, err := net.SplitHostPort(addr); err == nil {
+				if ip, ok := pinned[strings.ToLower(host)]; ok {
+					addr = net.JoinHostPort(ip, port)
+				}
+			}
+		}
+		return dial(ctx, network, addr)
+	}
+}
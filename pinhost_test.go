@@ -0,0 +1,85 @@
+package http_test
+
+import (
+	"context"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestPinHost(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// The host resolves to a different address every time.
+	var lookups int
+	restoreLookup := http.SetLookupIPAddr(func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		lookups++
+		if lookups == 1 {
+			return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}, {IP: net.ParseIP("127.0.0.2")}}, nil
+		}
+		return []net.IPAddr{{IP: net.ParseIP("127.0.0.2")}}, nil
+	})
+	defer restoreLookup()
+
+	var mtx sync.Mutex
+	var dialed []string
+	restoreDial := http.SetDialContext(func(d *net.Dialer, ctx context.Context, network, addr string) (net.Conn, error) {
+		mtx.Lock()
+		dialed = append(dialed, addr)
+		mtx.Unlock()
+		return d.DialContext(ctx, network, addr)
+	})
+	defer restoreDial()
+
+	ctx, err := http.PinHost(context.Background(), "Pinned.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := http.PinHost(context.Background(), "other.example"); err != nil {
+		t.Fatal(err)
+	}
+
+	cp := http.NewClientPool()
+	client := cp.GetClient(time.Second)
+	for i := 0; i < 3; i++ {
+		req, _ := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, "http://pinned.example:"+port+"/", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		// Every request establishes a new connection.
+		client.CloseIdleConnections()
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	want := net.JoinHostPort("127.0.0.1", port)
+	if len(dialed) != 3 {
+		t.Fatalf("got dials %v, want 3", dialed)
+	}
+	for _, addr := range dialed {
+		if addr != want {
+			t.Errorf("dialed %s, want %s", addr, want)
+		}
+	}
+}
+
+func TestPinHostNotFound(t *testing.T) {
+	restore := http.SetLookupIPAddr(func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return nil, nil
+	})
+	defer restore()
+
+	if _, err := http.PinHost(context.Background(), "missing.example"); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -1,10 +1,13 @@
 package http
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
 	"net/http"
+	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,13 +19,244 @@ type ClientPool struct {
 	mtx       sync.RWMutex
 	transport http.RoundTripper
 	tlsConfig *tls.Config
-	clients   map[time.Duration]*http.Client
+
+	// tlsProfiles holds the TLS profiles of the default transport by
+	// name, and tlsProfile is the name of the profile in use, if any.
+	tlsProfiles map[string]TLSProfile
+	tlsProfile  string
+
+	// sessionCache stores the TLS sessions of the default transport,
+	// overriding the one of the TLS Configuration, if set.
+	sessionCache tls.ClientSessionCache
+
+	// pins holds the SHA-256 pins of the public keys accepted from the
+	// hosts by the default transport. It is never modified once
+	// stored.
+	pins map[string][][]byte
+
+	// clients holds a map[time.Duration]*http.Client that is never
+	// modified once stored, so that it can be read without locking.
+	// Changes are made to a copy stored while holding the lock.
+	clients atomic.Value
+
+	// isolated holds the clients returned by GetIsolatedClient, and
+	// isolatedTransports their transports by name.
+	isolated           map[isolatedKey]*http.Client
+	isolatedTransports map[string]http.RoundTripper
+
+	// timeoutClients holds the clients returned by GetClientTimeouts,
+	// and timeoutTransports their transports, by timeout profile
+	// without the overall timeout.
+	timeoutClients    map[Timeouts]*http.Client
+	timeoutTransports map[Timeouts]http.RoundTripper
+
+	// upgrade holds the clients returned by GetUpgradeClient, by dial
+	// timeout.
+	upgrade map[time.Duration]*http.Client
+
+	// defaultTransport is the transport created by the pool for its
+	// clients when no transport is set, shared by all of them.
+	defaultTransport http.RoundTripper
+
+	// bases holds the transports the cached clients were built upon,
+	// before the middleware of the pool.
+	bases map[*http.Client]http.RoundTripper
+
+	// defaultTimeout is the timeout of the clients requested with a
+	// non-positive timeout, accessed atomically. Zero means no timeout.
+	defaultTimeout int64
+
+	// proxy selects the proxy of the default transport, and proxyAuth
+	// holds the credentials sent to it.
+	proxy     func(*http.Request) (*url.URL, error)
+	proxyAuth *url.Userinfo
+
+	// dialSlots limits the dials of the default transport in progress
+	// at the same time, if set.
+	dialSlots chan struct{}
+
+	// socks5 is the SOCKS5 proxy the connections of the default
+	// transport are tunneled through, if set.
+	socks5 *socks5Proxy
+
+	// jumpHost establishes the connections of the default transport
+	// through a jump host, if set.
+	jumpHost func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// methodTimeouts holds the timeouts of the clients used by Do,
+	// by request method.
+	methodTimeouts map[string]time.Duration
+
+	// fastFail shortens the dial timeout of the default transport
+	// until a first connection to a host succeeds, if enabled.
+	fastFail *fastFail
+
+	// ipv6Fallback is the delay after which the default transport
+	// dials over IPv4 when dialing over IPv6 has not succeeded yet.
+	// Zero means the addresses are dialed in the usual order.
+	ipv6Fallback time.Duration
+
+	// deadlinePropagation bounds the time waited for the response
+	// headers by the deadline of the requests, if enabled.
+	deadlinePropagation bool
+
+	// clock provides the time to the pool, the clock of the time
+	// package if nil.
+	clock clock
+
+	// contextTimeoutsOnly makes the clients of the pool have no timeout,
+	// the helpers of the pool enforcing it through the contexts instead.
+	contextTimeoutsOnly bool
+
+	// recycleThreshold is the number of consecutive errors after which
+	// a client is recycled. Zero means clients are never recycled.
+	recycleThreshold int
+
+	// lruReuse replaces the default transport by a transport reusing
+	// the least recently used connections, if enabled.
+	lruReuse bool
+
+	// certSelector selects the client certificates of the default
+	// transport, and tenants holds the transports of the tenants
+	// presenting them, if set.
+	certSelector func(*tls.CertificateRequestInfo) (*tls.Certificate, error)
+	tenants      *tenantTransports
+
+	// affinity pins the requests carrying the same affinity key to the
+	// same connection, if enabled.
+	affinity *connAffinity
+
+	// sentCapture is called with a snapshot of every request sent,
+	// after the middleware, if set.
+	sentCapture func(*http.Request)
+
+	// queueCapacity bounds the number of requests waiting for a
+	// concurrency slot. Zero means no limit.
+	queueCapacity int
+
+	// maxRequestHeaderBytes limits the size of the header block of
+	// the requests. Zero means no limit.
+	maxRequestHeaderBytes int64
+
+	// basicAuth holds the Basic authentication credentials of the
+	// requests, if set.
+	basicAuth *url.Userinfo
+
+	// preserveBody makes the helpers of the pool resend the bodies of
+	// the requests redirected with a 307 or 308 status code, if
+	// enabled.
+	preserveBody bool
+
+	// closeOnHeader is the canonical name of the response header
+	// whose connections are closed, if set, and closingConns the
+	// connections to close once their response is done.
+	closeOnHeader string
+	closingConns  *closingConns
+
+	// connReport accounts the connections used by the clients, per
+	// host, if enabled.
+	connReport *connReport
+
+	// hostMetrics aggregates the metrics of the requests sent by the
+	// clients, per host, if enabled.
+	hostMetrics *hostMetrics
+
+	// live holds the counters reported by LiveStats, if enabled.
+	live *liveStats
+
+	// errorSamples holds the last transport errors of the requests
+	// sent by the clients, if enabled.
+	errorSamples *errorSamples
+
+	// onNewConn is called with every new connection of the clients,
+	// if set.
+	onNewConn func(network, remoteAddr string, tls bool)
+
+	// maxRedirectsPerHost limits the number of redirects to the same
+	// host in a chain. Zero means no limit.
+	maxRedirectsPerHost int
+
+	// errorDecoder turns the non-2xx responses received by the helpers
+	// of the pool into errors, if set.
+	errorDecoder func(*http.Response) error
+
+	// balancer distributes the requests of DoBalanced across the
+	// endpoints set in the pool.
+	balancer *balancer
+
+	// softLimit caps the connections kept open per host without
+	// queueing the requests beyond the cap, if enabled.
+	softLimit *softConnLimit
+
+	// middleware wraps the transport of every client created by the
+	// pool. The first entry is the outermost wrapper.
+	middleware []func(http.RoundTripper) http.RoundTripper
+
+	// stack is the chain of middleware set with SetMiddlewareStack,
+	// wrapping the transport below the middleware, if set.
+	stack *TransportChain
+
+	// idempotency sets an idempotency key on the requests, before
+	// any middleware, if enabled.
+	idempotency func(http.RoundTripper) http.RoundTripper
+
+	// interceptors are called with the responses of the given status
+	// codes, if any.
+	interceptors *statusInterceptors
+
+	// requestInterceptor is called with every request before the
+	// middleware, if set.
+	requestInterceptor func(*http.Request) error
+
+	// responseInterceptor is called with every response after the
+	// middleware, if set.
+	responseInterceptor func(*http.Response) error
+
+	// panicRecovery recovers the panics of the transports, calling
+	// onPanic if not nil, if enabled.
+	panicRecovery bool
+	onPanic       func(recovered interface{}, req *http.Request)
+
+	// observers are notified about every request made by the clients
+	// of the pool.
+	observers []Observer
+
+	// routeClassifier derives the route label reported to the
+	// observers from a request.
+	routeClassifier func(*http.Request) string
+
+	// streamReadTimeout bounds the time every read of a response body
+	// can take. Zero means no limit.
+	streamReadTimeout time.Duration
+
+	// breaker is the circuit breaker installed in the pool, if any.
+	breaker *CircuitBreaker
+
+	// keepWarm keeps idle connections open to the hosts recently
+	// used, if enabled.
+	keepWarm *keepWarm
+
+	// draining prevents the creation of new clients while existing
+	// ones are still handed out.
+	draining bool
+
+	// warmupURLs are requested after every transport change so that
+	// the new transport has open connections to them.
+	warmupURLs    []string
+	onWarmupError func(url string, err error)
 }
 
+// Option configures a ClientPool when it is created.
+type Option func(*ClientPool)
+
 // SetTransport sets the transport to be shared by all the clients in the
 // pool. If nil, a default transport will be used. The default transport
 // will use the same settings as the default one in the core http package
 // plus the default TLS Configuration maintained in the pool.
+//
+// HTTP/2 server pushes are never accepted: the transports of the core
+// http package advertise that push is disabled and reject the streams
+// that servers attempt to push anyway.
 func (c *ClientPool) SetTransport(transport http.RoundTripper) {
 	c.mtx.Lock()
 	{
@@ -30,38 +264,92 @@ func (c *ClientPool) SetTransport(transport http.RoundTripper) {
 
 		// Ensuring that new clients requested from the pool will use
 		// the new transport settings.
-		c.clients = make(map[time.Duration]*http.Client)
+		c.resetClients()
 	}
 	c.mtx.Unlock()
+
+	c.warmup()
+}
+
+// WithTemporaryTransport sets the transport shared by all the clients in
+// the pool to rt while fn runs, and restores the previous transport once
+// it returns, even if it panics. The swap is visible to every caller of
+// the pool, not only to fn: clients requested from any goroutine while
+// fn runs use rt, and clients obtained before the swap keep using the
+// previous transport. Calls to WithTemporaryTransport must not overlap,
+// otherwise the transport restored by one call may be the temporary
+// transport of another.
+func (c *ClientPool) WithTemporaryTransport(rt http.RoundTripper, fn func()) {
+	previous := c.swapTransport(rt)
+	defer c.swapTransport(previous)
+
+	fn()
+}
+
+// swapTransport replaces the transport of the pool and returns the
+// previous one.
+func (c *ClientPool) swapTransport(transport http.RoundTripper) http.RoundTripper {
+	var previous http.RoundTripper
+
+	c.mtx.Lock()
+	{
+		previous = c.transport
+		c.transport = transport
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+	c.mtx.Unlock()
+
+	c.warmup()
+	return previous
 }
 
 // SetDefaultTLSConfig sets the TLS Configuration that will be used
 // by the default transport. A default transport will be used if no
-// transport has been specified.
+// transport has been specified. It replaces the TLS profile in use, if
+// any, see UseTLSProfile.
 func (c *ClientPool) SetDefaultTLSConfig(tlsConfig *tls.Config) {
 	c.mtx.Lock()
 	{
 		c.tlsConfig = tlsConfig
+		c.tlsProfile = ""
 
 		// Ensuring that new clients requested from the pool will use
 		// the new transport settings.
-		c.clients = make(map[time.Duration]*http.Client)
+		c.resetClients()
 	}
 	c.mtx.Unlock()
+
+	c.warmup()
+}
+
+// clientTimeout returns the timeout of the clients requested with the
+// specified timeout.
+func (c *ClientPool) clientTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		if d := time.Duration(atomic.LoadInt64(&c.defaultTimeout)); d > 0 {
+			timeout = d
+		}
+	}
+	if c.contextTimeoutsOnly {
+		timeout = 0
+	}
+	return timeout
 }
 
 // GetClient returns a HTTP Client for making HTTP calls based
-// on the specified timeout.
+// on the specified timeout. Non-positive timeouts are replaced by the
+// default timeout of the pool, if any, see SetDefaultTimeout.
 func (c *ClientPool) GetClient(timeout time.Duration) *http.Client {
-	// Locate a client for this timeout.
-	c.mtx.RLock()
-	{
-		if client := c.clients[timeout]; client != nil {
-			c.mtx.RUnlock()
-			return client
-		}
+	timeout = c.clientTimeout(timeout)
+
+	// Locate a client for this timeout. This does not require the
+	// lock since the map is never modified.
+	if client := c.loadClients()[timeout]; client != nil {
+		return client
 	}
-	c.mtx.RUnlock()
 
 	// Create a new client for this timeout if one did not exist.
 	var client *http.Client
@@ -69,33 +357,41 @@ func (c *ClientPool) GetClient(timeout time.Duration) *http.Client {
 	c.mtx.Lock()
 	{
 		// Check again to be safe now that we are in the write lock.
-		if client = c.clients[timeout]; client == nil {
-			transport := c.transport
-			if transport == nil {
-				// Create our own transport using the same settings as
-				// the default one in the core http package plus the
-				// default TLS Configuration maintained in the pool.
-				// This maintains a pool of connections.
-				transport = &http.Transport{
-					Proxy:           http.ProxyFromEnvironment,
-					TLSClientConfig: c.tlsConfig,
-					Dial: (&net.Dialer{
-						Timeout:   30 * time.Second,
-						KeepAlive: 30 * time.Second,
-					}).Dial,
-					TLSHandshakeTimeout: 10 * time.Second,
-				}
-			}
+		clients := c.loadClients()
+		client = clients[timeout]
+		if client == nil && c.draining {
+			client = c.closestClient(timeout)
+		}
+		if client == nil {
+			transport := c.sharedTransport()
 
 			// Create a new Client to use this transport
 			// for this specific timeout.
 			client = &http.Client{
-				Transport: transport,
-				Timeout:   timeout,
+				Transport:     c.wrap(transport),
+				CheckRedirect: c.checkRedirect(),
+				Timeout:       timeout,
+			}
+			if c.recycleThreshold > 0 {
+				recycled := client
+				client.Transport = &recycleTransport{
+					next:      client.Transport,
+					threshold: int32(c.recycleThreshold),
+					recycle:   func() { c.recycleClient(timeout, recycled) },
+				}
+			}
+			if c.bases == nil {
+				c.bases = make(map[*http.Client]http.RoundTripper)
 			}
+			c.bases[client] = transport
 
-			// Save this client to the map.
-			c.clients[timeout] = client
+			// Save this client to a copy of the map.
+			updated := make(map[time.Duration]*http.Client, len(clients)+1)
+			for t, cl := range clients {
+				updated[t] = cl
+			}
+			updated[timeout] = client
+			c.clients.Store(updated)
 		}
 	}
 	c.mtx.Unlock()
@@ -103,12 +399,319 @@ func (c *ClientPool) GetClient(timeout time.Duration) *http.Client {
 	return client
 }
 
-// NewClientPool returns a new, empty ClientPool.
-func NewClientPool() *ClientPool {
-	return &ClientPool{
-		clients: make(map[time.Duration]*http.Client),
+// SetDefaultTimeout sets the timeout of the clients returned by GetClient
+// when it is called with a non-positive timeout, and by the helpers of
+// the pool using the client for the default timeout. Zero, the default
+// for pools returned by NewClientPool, means these clients have no
+// timeout. Clients requested with a positive timeout are not affected.
+func (c *ClientPool) SetDefaultTimeout(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	atomic.StoreInt64(&c.defaultTimeout, int64(d))
+}
+
+// SnapshotClients returns a copy of the clients currently held by the
+// pool, keyed by timeout. The clients are shared with the pool, but
+// modifying the returned map does not affect the pool, and clients
+// created or discarded by the pool afterwards are not reflected in it.
+func (c *ClientPool) SnapshotClients() map[time.Duration]*http.Client {
+	clients := c.loadClients()
+
+	snapshot := make(map[time.Duration]*http.Client, len(clients))
+	for timeout, client := range clients {
+		snapshot[timeout] = client
 	}
+	return snapshot
 }
 
+// newDefaultTransport creates our own transport using the same settings
+// as the default one in the core http package plus the default TLS
+// Configuration maintained in the pool. This maintains a pool of
+// connections. Must be called while holding the lock.
+func (c *ClientPool) newDefaultTransport() *http.Transport {
+	transport := &http.Transport{
+		Proxy:               c.proxyFunc(),
+		TLSClientConfig:     c.defaultTLSConfig(),
+		DialContext:         c.defaultDial(30 * time.Second),
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	if c.proxyAuth != nil {
+		transport.ProxyConnectHeader = http.Header{
+			"Proxy-Authorization": {basicAuth(c.proxyAuth)},
+		}
+	}
+	return transport
+}
+
+// defaultDial returns the dial function of the default transport, with
+// the specified dial timeout. Must be called while holding the lock.
+func (c *ClientPool) defaultDial(timeout time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return pinnedDial(c.limitDials(c.socks5Dial(c.jumpHostDial(timeout, c.preferIPv6(c.dialFunc(&net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+	}))))))
+}
+
+// SharedTransport returns the transport shared by all the clients
+// returned by GetClient, whatever their timeout, as it was set in the
+// pool or created by it, without the middleware of the pool. The default
+// transport is created once and shared until the transport settings of
+// the pool change.
+func (c *ClientPool) SharedTransport() http.RoundTripper {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.sharedTransport()
+}
+
+// sharedTransport returns the transport the clients are built upon,
+// creating the default transport if needed. Must be called while
+// holding the lock.
+func (c *ClientPool) sharedTransport() http.RoundTripper {
+	if c.transport != nil {
+		return c.transport
+	}
+	if transport := globalDefaultTransport(); transport != nil && !c.customizesDefaultTransport() {
+		return transport
+	}
+
+	if c.defaultTransport == nil {
+		if c.lruReuse {
+			c.defaultTransport = c.newLRUTransport()
+		} else {
+			c.defaultTransport = c.newDefaultTransport()
+		}
+	}
+	return c.defaultTransport
+}
+
+// loadClients returns the current map of clients, which must not be
+// modified.
+func (c *ClientPool) loadClients() map[time.Duration]*http.Client {
+	clients, _ := c.clients.Load().(map[time.Duration]*http.Client)
+	return clients
+}
+
+// resetClients discards the cached clients, ensuring that new clients
+// requested from the pool will use the new transport settings. Must be
+// called while holding the lock.
+func (c *ClientPool) resetClients() {
+	c.clients.Store(map[time.Duration]*http.Client{})
+	c.bases = make(map[*http.Client]http.RoundTripper)
+	c.defaultTransport = nil
+	c.isolated = nil
+	c.isolatedTransports = nil
+	c.timeoutClients = nil
+	c.timeoutTransports = nil
+	c.upgrade = nil
+	if c.affinity != nil {
+		c.affinity.reset()
+	}
+	if c.softLimit != nil {
+		c.softLimit.reset()
+	}
+	if c.tenants != nil {
+		c.tenants.reset()
+	}
+	if c.connReport != nil {
+		c.connReport.reset()
+	}
+}
+
+// wrap applies the middleware installed in the pool to the specified
+// transport. Must be called while holding the lock.
+func (c *ClientPool) wrap(transport http.RoundTripper) http.RoundTripper {
+	if t, ok := transport.(*http.Transport); ok {
+		if c.softLimit != nil {
+			transport = c.softLimit.transport(t)
+		}
+		if c.affinity != nil {
+			transport = &affinityTransport{
+				next:     transport,
+				base:     t,
+				affinity: c.affinity,
+			}
+		}
+		if c.tenants != nil {
+			transport = &tenantTransport{
+				next:    transport,
+				base:    t,
+				tenants: c.tenants,
+			}
+		}
+		if c.deadlinePropagation {
+			transport = &headerDeadlineTransport{
+				next:   transport,
+				static: t.ResponseHeaderTimeout,
+			}
+		}
+	} else if c.deadlinePropagation {
+		transport = &headerDeadlineTransport{next: transport}
+	}
+
+	// The keep-warm requests are not accounted as the requests of the
+	// clients.
+	warm := transport
+	if c.sentCapture != nil {
+		transport = &sentCaptureTransport{
+			next:    transport,
+			capture: c.sentCapture,
+		}
+	}
+	if c.maxRequestHeaderBytes > 0 {
+		transport = &requestHeaderLimitTransport{
+			next:  transport,
+			limit: c.maxRequestHeaderBytes,
+		}
+	}
+	if c.closeOnHeader != "" {
+		transport = &closeOnHeaderTransport{
+			next:    transport,
+			header:  c.closeOnHeader,
+			closing: c.closingConns,
+		}
+	}
+	if c.errorSamples != nil {
+		transport = &errorSamplingTransport{
+			next:    transport,
+			samples: c.errorSamples,
+		}
+	}
+	if c.hostMetrics != nil {
+		transport = &hostMetricsTransport{
+			next:    transport,
+			metrics: c.hostMetrics,
+		}
+	}
+	if c.connReport != nil {
+		transport = &connReportTransport{
+			next:   transport,
+			report: c.connReport,
+		}
+	}
+	if c.onNewConn != nil {
+		transport = &newConnTransport{
+			next:      transport,
+			onNewConn: c.onNewConn,
+		}
+	}
+	if c.keepWarm != nil {
+		transport = &keepWarmTransport{
+			next:     transport,
+			warm:     warm,
+			keepWarm: c.keepWarm,
+			clock:    c.timeSource(),
+		}
+	}
+	if c.streamReadTimeout > 0 {
+		transport = &streamTimeoutTransport{
+			next:    transport,
+			timeout: c.streamReadTimeout,
+		}
+	}
+	transport = c.stack.Then(transport)
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		transport = c.middleware[i](transport)
+	}
+	if c.preserveBody {
+		transport = &redirectBodyTransport{next: transport}
+	}
+	if c.queueCapacity > 0 {
+		transport = &queueCapacityTransport{
+			next:     transport,
+			capacity: c.queueCapacity,
+		}
+	}
+	if c.idempotency != nil {
+		transport = c.idempotency(transport)
+	}
+	if c.interceptors != nil {
+		transport = &interceptorTransport{
+			next:         transport,
+			interceptors: c.interceptors,
+		}
+	}
+	if len(c.observers) > 0 {
+		transport = &observerTransport{
+			next:      transport,
+			observers: c.observers,
+			route:     c.routeClassifier,
+		}
+	}
+	if c.responseInterceptor != nil {
+		transport = &responseInterceptorTransport{
+			next:        transport,
+			interceptor: c.responseInterceptor,
+		}
+	}
+	if c.basicAuth != nil {
+		transport = &basicAuthTransport{
+			next: transport,
+			auth: c.basicAuth,
+		}
+	}
+	if c.requestInterceptor != nil {
+		transport = &requestInterceptorTransport{
+			next:        transport,
+			interceptor: c.requestInterceptor,
+		}
+	}
+	if c.live != nil {
+		transport = &liveStatsTransport{
+			next:  transport,
+			stats: c.live,
+		}
+	}
+	if c.panicRecovery {
+		transport = &recoveryTransport{
+			next:    transport,
+			onPanic: c.onPanic,
+		}
+	}
+	return transport
+}
+
+// use installs a middleware in the pool. Must be called while holding
+// the lock or before the pool is shared.
+func (c *ClientPool) use(mw func(http.RoundTripper) http.RoundTripper) {
+	c.middleware = append(c.middleware, mw)
+	c.resetClients()
+}
+
+// NewClientPool returns a new, empty ClientPool configured with the
+// specified options.
+func NewClientPool(opts ...Option) *ClientPool {
+	c := &ClientPool{}
+	c.resetClients()
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Close stops the background goroutines of the pool. The clients of the
+// pool remain usable.
+func (c *ClientPool) Close() error {
+	c.mtx.Lock()
+	{
+		c.stopKeepWarm()
+	}
+	c.mtx.Unlock()
+
+	return nil
+}
+
+// defaultPoolTimeout is the default timeout of DefaultClientPool.
+const defaultPoolTimeout = 30 * time.Second
+
 // DefaultClientPool represents the default pool for managing HTTP Clients.
-var DefaultClientPool = NewClientPool()
+// Its clients requested with a non-positive timeout have a timeout of 30
+// seconds, unless changed with SetDefaultTimeout.
+var DefaultClientPool = newDefaultClientPool()
+
+func newDefaultClientPool() *ClientPool {
+	c := NewClientPool()
+	c.SetDefaultTimeout(defaultPoolTimeout)
+	return c
+}
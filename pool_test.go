@@ -1,7 +1,15 @@
 package http_test
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
 
 	"github.com/Updater/http"
 )
@@ -15,3 +23,232 @@ func ExampleNewClientPool() {
 	// Output:
 	// 10ns
 }
+
+// roundTripperFunc adapts an ordinary function to the
+// nethttp.RoundTripper interface for stubbing transports in tests.
+type roundTripperFunc func(*nethttp.Request) (*nethttp.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *nethttp.Request) (*nethttp.Response, error) {
+	return f(req)
+}
+
+// okTransport answers every request with an empty 200 response.
+var okTransport = roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+	return statusResponse(req, nethttp.StatusOK), nil
+})
+
+// statusResponse returns an empty response with the specified status.
+func statusResponse(req *nethttp.Request, code int) *nethttp.Response {
+	return &nethttp.Response{
+		Status:     fmt.Sprintf("%d %s", code, nethttp.StatusText(code)),
+		StatusCode: code,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(nethttp.Header),
+		Body:       nethttp.NoBody,
+		Request:    req,
+	}
+}
+
+// rwMutexPool replicates the previous implementation of the hit path of
+// GetClient, taking a read lock on every call, as a baseline.
+type rwMutexPool struct {
+	mtx     sync.RWMutex
+	clients map[time.Duration]*nethttp.Client
+}
+
+func (p *rwMutexPool) GetClient(timeout time.Duration) *nethttp.Client {
+	p.mtx.RLock()
+	client := p.clients[timeout]
+	p.mtx.RUnlock()
+	return client
+}
+
+func BenchmarkGetClientRWMutex(b *testing.B) {
+	p := &rwMutexPool{clients: map[time.Duration]*nethttp.Client{
+		time.Second: {Timeout: time.Second},
+	}}
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			p.GetClient(time.Second)
+		}
+	})
+}
+
+func BenchmarkGetClient(b *testing.B) {
+	cp := http.NewClientPool()
+	cp.GetClient(time.Second)
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			cp.GetClient(time.Second)
+		}
+	})
+}
+
+func TestGetClientConcurrent(t *testing.T) {
+	cp := http.NewClientPool()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				timeout := time.Duration(j%10+1) * time.Second
+				if client := cp.GetClient(timeout); client.Timeout != timeout {
+					t.Errorf("got timeout %v, want %v", client.Timeout, timeout)
+					return
+				}
+			}
+		}(i)
+	}
+
+	// Reconfigure the pool while clients are requested.
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cp.SetTransport(&nethttp.Transport{})
+				cp.SetDefaultTLSConfig(nil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Clients are cached once the reconfigurations are done.
+	if cp.GetClient(time.Second) != cp.GetClient(time.Second) {
+		t.Fatal("expected the same client for the same timeout")
+	}
+}
+
+func TestSnapshotClients(t *testing.T) {
+	cp := http.NewClientPool()
+	client := cp.GetClient(time.Second)
+
+	snapshot := cp.SnapshotClients()
+	if len(snapshot) != 1 || snapshot[time.Second] != client {
+		t.Fatalf("snapshot = %v, want the 1s client", snapshot)
+	}
+
+	// Clients created afterwards are not part of the snapshot.
+	cp.GetClient(2 * time.Second)
+	if len(snapshot) != 1 {
+		t.Fatalf("snapshot has %d clients, want 1", len(snapshot))
+	}
+
+	// Modifying the snapshot does not affect the pool.
+	delete(snapshot, time.Second)
+	if cp.GetClient(time.Second) != client {
+		t.Fatal("expected the pool to keep its client")
+	}
+	if len(cp.SnapshotClients()) != 2 {
+		t.Fatal("expected a new snapshot to hold both clients")
+	}
+}
+
+func TestDefaultClientPoolTimeout(t *testing.T) {
+	defer http.DefaultClientPool.SetDefaultTimeout(30 * time.Second)
+
+	for _, timeout := range []time.Duration{0, -time.Second} {
+		if got := http.DefaultClientPool.GetClient(timeout).Timeout; got != 30*time.Second {
+			t.Errorf("GetClient(%v) timeout = %v, want 30s", timeout, got)
+		}
+	}
+	if got := http.DefaultClientPool.GetClient(time.Second).Timeout; got != time.Second {
+		t.Errorf("GetClient(1s) timeout = %v, want 1s", got)
+	}
+
+	http.DefaultClientPool.SetDefaultTimeout(5 * time.Second)
+	if got := http.DefaultClientPool.GetClient(0).Timeout; got != 5*time.Second {
+		t.Errorf("GetClient(0) timeout = %v, want 5s", got)
+	}
+
+	// No timeout must be explicitly allowed.
+	http.DefaultClientPool.SetDefaultTimeout(0)
+	if got := http.DefaultClientPool.GetClient(0).Timeout; got != 0 {
+		t.Errorf("GetClient(0) timeout = %v, want none", got)
+	}
+}
+
+func TestNewClientPoolNoDefaultTimeout(t *testing.T) {
+	if got := http.NewClientPool().GetClient(0).Timeout; got != 0 {
+		t.Fatalf("GetClient(0) timeout = %v, want none", got)
+	}
+}
+
+func TestServerPushRefused(t *testing.T) {
+	pushErr := make(chan error, 1)
+	server := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path != "/" {
+			return
+		}
+		pusher, ok := w.(nethttp.Pusher)
+		if !ok {
+			pushErr <- errors.New("push not supported by the server")
+			return
+		}
+		pushErr <- pusher.Push("/pushed", nil)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	cp := http.NewClientPool()
+	cp.SetTransport(&nethttp.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots},
+		ForceAttemptHTTP2: true,
+	})
+
+	resp, err := cp.GetClient(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("got protocol %s, want HTTP/2", resp.Proto)
+	}
+	if err := <-pushErr; !errors.Is(err, nethttp.ErrNotSupported) {
+		t.Errorf("push error = %v, want %v", err, nethttp.ErrNotSupported)
+	}
+}
+
+func TestSharedTransport(t *testing.T) {
+	for _, opts := range [][]http.Option{nil, {http.WithLRUConnReuse()}} {
+		cp := http.NewClientPool(opts...)
+		shared := cp.SharedTransport()
+
+		for _, timeout := range []time.Duration{0, time.Second, 2 * time.Second} {
+			if transport := cp.EffectiveTransport(timeout); transport != shared {
+				t.Errorf("client for %v uses transport %p, want the shared %p", timeout, transport, shared)
+			}
+		}
+
+		// A settings change creates a new shared transport.
+		cp.SetDefaultTLSConfig(nil)
+		updated := cp.SharedTransport()
+		if updated == shared {
+			t.Error("got the same shared transport after a settings change")
+		}
+		if transport := cp.EffectiveTransport(3 * time.Second); transport != updated {
+			t.Errorf("got transport %p, want the updated shared %p", transport, updated)
+		}
+	}
+}
+
+func TestSharedTransportSet(t *testing.T) {
+	set := &nethttp.Transport{}
+	cp := http.NewClientPool()
+	cp.SetTransport(set)
+
+	if transport := cp.SharedTransport(); transport != set {
+		t.Errorf("got transport %p, want the transport set %p", transport, set)
+	}
+}
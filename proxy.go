@@ -0,0 +1,74 @@
+package http
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+)
+
+// SetProxy sets the function selecting the proxy used by the default
+// transport for every request, with the same semantics as the Proxy
+// field of http.Transport. If nil, the proxy is read from the
+// environment with http.ProxyFromEnvironment.
+func (c *ClientPool) SetProxy(proxy func(*http.Request) (*url.URL, error)) {
+	c.mtx.Lock()
+	{
+		c.proxy = proxy
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+	c.mtx.Unlock()
+}
+
+// SetProxyBasicAuth sets the credentials sent by the default transport
+// to its proxy in the Proxy-Authorization header, both with the CONNECT
+// requests tunneling HTTPS requests and with the plain HTTP requests
+// sent through the proxy. The credentials override the ones of the
+// proxy URL.
+func (c *ClientPool) SetProxyBasicAuth(user, pass string) {
+	c.mtx.Lock()
+	{
+		c.proxyAuth = url.UserPassword(user, pass)
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+	c.mtx.Unlock()
+}
+
+// proxyFunc returns the proxy function of the default transport. Must be
+// called while holding the lock.
+func (c *ClientPool) proxyFunc() func(*http.Request) (*url.URL, error) {
+	proxy := c.proxy
+	if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+
+	auth := c.proxyAuth
+	if auth == nil {
+		return proxy
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		u, err := proxy(req)
+		if u == nil || err != nil {
+			return u, err
+		}
+
+		// The core http package sends the credentials of the proxy
+		// URL in the Proxy-Authorization header.
+		withAuth := *u
+		withAuth.User = auth
+		return &withAuth, nil
+	}
+}
+
+// basicAuth returns the value of an Authorization header with the
+// specified credentials.
+func basicAuth(user *url.Userinfo) string {
+	pass, _ := user.Password()
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user.Username()+":"+pass))
+}
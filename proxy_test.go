@@ -0,0 +1,118 @@
+package http_test
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// connectProxy is a CONNECT proxy requiring the specified
+// Proxy-Authorization header. It records the headers it received.
+type connectProxy struct {
+	auth string
+
+	mtx   sync.Mutex
+	auths []string
+}
+
+func (p *connectProxy) ServeHTTP(w nethttp.ResponseWriter, r *nethttp.Request) {
+	p.mtx.Lock()
+	p.auths = append(p.auths, r.Header.Get("Proxy-Authorization"))
+	p.mtx.Unlock()
+
+	if r.Method != nethttp.MethodConnect {
+		nethttp.Error(w, "CONNECT only", nethttp.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("Proxy-Authorization") != p.auth {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="proxy"`)
+		w.WriteHeader(nethttp.StatusProxyAuthRequired)
+		return
+	}
+
+	target, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		nethttp.Error(w, err.Error(), nethttp.StatusBadGateway)
+		return
+	}
+	conn, _, err := w.(nethttp.Hijacker).Hijack()
+	if err != nil {
+		target.Close()
+		return
+	}
+	io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+
+	go func() {
+		io.Copy(target, conn)
+		target.Close()
+	}()
+	io.Copy(conn, target)
+	conn.Close()
+}
+
+// newProxiedPool returns a pool tunneling HTTPS requests to target
+// through the proxy.
+func newProxiedPool(t *testing.T, proxy, target *httptest.Server) *http.ClientPool {
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cp := http.NewClientPool()
+	cp.SetProxy(nethttp.ProxyURL(proxyURL))
+	cp.SetDefaultTLSConfig(&tls.Config{
+		RootCAs: target.Client().Transport.(*nethttp.Transport).TLSClientConfig.RootCAs,
+	})
+	return cp
+}
+
+func TestProxyBasicAuth(t *testing.T) {
+	target := httptest.NewTLSServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, "tunneled")
+	}))
+	defer target.Close()
+
+	handler := &connectProxy{auth: "Basic dXNlcjpzZWNyZXQ="}
+	proxy := httptest.NewServer(handler)
+	defer proxy.Close()
+
+	cp := newProxiedPool(t, proxy, target)
+	cp.SetProxyBasicAuth("user", "secret")
+
+	resp, err := cp.GetClient(5 * time.Second).Get(target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if body, _ := io.ReadAll(resp.Body); string(body) != "tunneled" {
+		t.Fatalf("body = %q, want %q", body, "tunneled")
+	}
+	if len(handler.auths) != 1 || handler.auths[0] != handler.auth {
+		t.Fatalf("proxy received %q, want the credentials", handler.auths)
+	}
+}
+
+func TestProxyAuthRequired(t *testing.T) {
+	target := httptest.NewTLSServer(nethttp.NotFoundHandler())
+	defer target.Close()
+
+	proxy := httptest.NewServer(&connectProxy{auth: "Basic dXNlcjpzZWNyZXQ="})
+	defer proxy.Close()
+
+	cp := newProxiedPool(t, proxy, target)
+
+	_, err := cp.GetClient(5 * time.Second).Get(target.URL)
+	if err == nil || !strings.Contains(err.Error(), "Proxy Authentication Required") {
+		t.Fatalf("error = %v, want a proxy authentication error", err)
+	}
+}
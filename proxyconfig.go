@@ -0,0 +1,28 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// SetProxyFromConfig makes the default transport select its proxy from
+// the specified settings, which have the same format as the HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY environment variables: requests to the hosts
+// matched by noProxy are sent directly, the other ones are sent through
+// httpsProxy or httpProxy depending on their scheme. Unlike
+// http.ProxyFromEnvironment, which reads the environment only once, the
+// settings can be changed at any time by calling SetProxyFromConfig
+// again. It replaces the proxy function installed with SetProxy.
+func (c *ClientPool) SetProxyFromConfig(httpProxy, httpsProxy, noProxy string) {
+	proxy := (&httpproxy.Config{
+		HTTPProxy:  httpProxy,
+		HTTPSProxy: httpsProxy,
+		NoProxy:    noProxy,
+	}).ProxyFunc()
+
+	c.SetProxy(func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	})
+}
@@ -0,0 +1,54 @@
+package http_test
+
+import (
+	nethttp "net/http"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// proxyFor returns the proxy selected by the default transport of the
+// pool for the URL, or "" if the request is sent directly.
+func proxyFor(t *testing.T, cp *http.ClientPool, url string) string {
+	t.Helper()
+
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, url, nil)
+	u, err := cp.EffectiveTransport(time.Second).(*nethttp.Transport).Proxy(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
+
+func TestSetProxyFromConfig(t *testing.T) {
+	cp := http.NewClientPool()
+	cp.SetProxyFromConfig("http://proxy:8080", "http://tls-proxy:8443", "internal.example.com,.corp")
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"http://example.com/", "http://proxy:8080"},
+		{"https://example.com/", "http://tls-proxy:8443"},
+		{"http://internal.example.com/", ""},
+		{"https://api.corp/", ""},
+	}
+	for _, tt := range tests {
+		if got := proxyFor(t, cp, tt.url); got != tt.want {
+			t.Errorf("proxy for %s = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	// The settings can be reloaded.
+	cp.SetProxyFromConfig("http://proxy:8080", "", "example.com")
+	if got := proxyFor(t, cp, "http://example.com/"); got != "" {
+		t.Errorf("proxy for example.com = %q after reload, want none", got)
+	}
+	if got := proxyFor(t, cp, "http://other.com/"); got != "http://proxy:8080" {
+		t.Errorf("proxy for other.com = %q after reload, want http://proxy:8080", got)
+	}
+}
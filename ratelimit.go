@@ -0,0 +1,138 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the rate of the requests by key. Implementations
+// backed by a shared store, such as Redis, enforce the limits across all
+// the instances of an application. A RateLimiter must be safe for
+// concurrent use.
+type RateLimiter interface {
+	// Wait blocks until a request with the specified key can be sent,
+	// or until the context is done, in which case it returns the error
+	// of the context. Any error fails the request.
+	Wait(ctx context.Context, key string) error
+}
+
+// WithDistributedRateLimit makes the clients of the pool wait for the
+// limiter before sending every request, with the key returned by keyFn
+// for the request, for instance its host or its tenant. The requests
+// are keyed by the host of their URL if keyFn is nil. The requests for
+// which the limiter fails are not sent and fail with its error.
+func WithDistributedRateLimit(limiter RateLimiter, keyFn func(*http.Request) string) Option {
+	if keyFn == nil {
+		keyFn = func(req *http.Request) string { return req.URL.Host }
+	}
+
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			return &rateLimitTransport{
+				next:    next,
+				limiter: limiter,
+				keyFn:   keyFn,
+			}
+		})
+	}
+}
+
+// rateLimitTransport waits for the limiter before sending the requests
+// through the next transport.
+type rateLimitTransport struct {
+	next    http.RoundTripper
+	limiter RateLimiter
+	keyFn   func(*http.Request) string
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context(), t.keyFn(req)); err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
+
+// MemoryRateLimiter is a RateLimiter enforcing its limits within the
+// process, with a token bucket per key. It is meant for tests and for
+// applications running a single instance.
+type MemoryRateLimiter struct {
+	rate  float64
+	burst float64
+
+	mtx     sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// tokenBucket holds the tokens of a key, as of the last update. Tokens
+// go negative when requests are waiting for them.
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewMemoryRateLimiter returns a MemoryRateLimiter allowing, for every
+// key, rate requests per second on average with bursts of up to burst
+// requests.
+func NewMemoryRateLimiter(rate float64, burst int) *MemoryRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &MemoryRateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Wait implements the RateLimiter interface.
+func (l *MemoryRateLimiter) Wait(ctx context.Context, key string) error {
+	delay := l.reserve(key)
+	if delay <= 0 {
+		return nil
+	}
+
+	if err := sleep(ctx, systemClock, delay); err != nil {
+		l.cancel(key)
+		return err
+	}
+	return nil
+}
+
+// reserve takes a token from the bucket of the key and returns the time
+// to wait for it to be available.
+func (l *MemoryRateLimiter) reserve(key string) time.Duration {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := time.Now()
+	b := l.buckets[key]
+	if b == nil {
+		b = &tokenBucket{tokens: l.burst, updated: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.updated).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.updated = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / l.rate * float64(time.Second))
+}
+
+// cancel returns the token reserved by a request that gave up waiting.
+func (l *MemoryRateLimiter) cancel(key string) {
+	l.mtx.Lock()
+	if b := l.buckets[key]; b != nil {
+		b.tokens++
+	}
+	l.mtx.Unlock()
+}
@@ -0,0 +1,122 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	nethttp "net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// gateLimiter is a RateLimiter recording the keys it waits for and
+// blocking until its gate is opened.
+type gateLimiter struct {
+	mtx  sync.Mutex
+	keys []string
+	gate chan struct{}
+}
+
+func (l *gateLimiter) Wait(ctx context.Context, key string) error {
+	l.mtx.Lock()
+	l.keys = append(l.keys, key)
+	l.mtx.Unlock()
+
+	select {
+	case <-l.gate:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestDistributedRateLimit(t *testing.T) {
+	limiter := &gateLimiter{gate: make(chan struct{})}
+	var (
+		mtx  sync.Mutex
+		sent int
+	)
+	pool := http.NewClientPool(http.WithDistributedRateLimit(limiter, func(req *nethttp.Request) string {
+		return req.Header.Get("X-Tenant")
+	}))
+	pool.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		mtx.Lock()
+		sent++
+		mtx.Unlock()
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+	client := pool.GetClient(time.Second)
+
+	done := make(chan error, 1)
+	go func() {
+		req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/", nil)
+		req.Header.Set("X-Tenant", "acme")
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+
+	// The request waits for the limiter.
+	time.Sleep(20 * time.Millisecond)
+	mtx.Lock()
+	if sent != 0 {
+		t.Error("request sent before the limiter allowed it")
+	}
+	mtx.Unlock()
+
+	close(limiter.gate)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(limiter.keys) != 1 || limiter.keys[0] != "acme" {
+		t.Errorf("limiter waited for keys %q, want [acme]", limiter.keys)
+	}
+}
+
+func TestDistributedRateLimitError(t *testing.T) {
+	limiter := &gateLimiter{gate: make(chan struct{})}
+	pool := http.NewClientPool(http.WithDistributedRateLimit(limiter, nil))
+	pool.SetTransport(okTransport)
+
+	// The requests are keyed by host by default, and fail with the error
+	// of the limiter.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, "http://example.com:8080/", nil)
+	if _, err := pool.GetClient(time.Second).Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if len(limiter.keys) != 1 || limiter.keys[0] != "example.com:8080" {
+		t.Errorf("limiter waited for keys %q, want [example.com:8080]", limiter.keys)
+	}
+}
+
+func TestMemoryRateLimiter(t *testing.T) {
+	limiter := http.NewMemoryRateLimiter(20, 2)
+	ctx := context.Background()
+
+	// The burst is allowed at once, then the requests are spaced by
+	// 50ms, independently for every key.
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := limiter.Wait(ctx, "a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := limiter.Wait(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("4 requests in %v, want about 100ms", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx, "a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
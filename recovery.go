@@ -0,0 +1,47 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrTransportPanic is returned when sending a request panicked. The
+// returned error wraps it along with the recovered value.
+var ErrTransportPanic = errors.New("http: transport panicked")
+
+// WithPanicRecovery makes the clients of the pool recover the panics
+// occurring while sending their requests, in the transport or in any
+// middleware, and fail the request with an error wrapping
+// ErrTransportPanic instead. The onPanic callback, if not nil, is called
+// with the recovered value and the request beforehand, for instance to
+// log the stack trace. Panics occurring while reading a response body
+// are not recovered.
+func WithPanicRecovery(onPanic func(recovered interface{}, req *http.Request)) Option {
+	return func(c *ClientPool) {
+		c.panicRecovery = true
+		c.onPanic = onPanic
+		c.resetClients()
+	}
+}
+
+// recoveryTransport recovers the panics of the next transport.
+type recoveryTransport struct {
+	next    http.RoundTripper
+	onPanic func(recovered interface{}, req *http.Request)
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *recoveryTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if t.onPanic != nil {
+				t.onPanic(recovered, req)
+			}
+			closeRequestBody(req)
+			resp, err = nil, fmt.Errorf("%w: %v", ErrTransportPanic, recovered)
+		}
+	}()
+
+	return t.next.RoundTrip(req)
+}
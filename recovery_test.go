@@ -0,0 +1,45 @@
+package http_test
+
+import (
+	"errors"
+	nethttp "net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// panickingTransport is a buggy transport panicking on every request.
+var panickingTransport = roundTripperFunc(func(*nethttp.Request) (*nethttp.Response, error) {
+	panic("buggy middleware")
+})
+
+func TestPanicRecovery(t *testing.T) {
+	var recovered interface{}
+	var path string
+	cp := http.NewClientPool(http.WithPanicRecovery(func(r interface{}, req *nethttp.Request) {
+		recovered, path = r, req.URL.Path
+	}), http.WithRetry(2, time.Millisecond))
+	cp.SetTransport(panickingTransport)
+
+	_, err := cp.GetClient(time.Second).Get("http://example.com/panic")
+	if !errors.Is(err, http.ErrTransportPanic) {
+		t.Fatalf("error = %v, want %v", err, http.ErrTransportPanic)
+	}
+	if !strings.Contains(err.Error(), "buggy middleware") {
+		t.Errorf("error %q does not describe the panic", err)
+	}
+	if recovered != "buggy middleware" || path != "/panic" {
+		t.Errorf("callback got %v for %q", recovered, path)
+	}
+}
+
+func TestPanicRecoveryWithoutCallback(t *testing.T) {
+	cp := http.NewClientPool(http.WithPanicRecovery(nil))
+	cp.SetTransport(panickingTransport)
+
+	if _, err := cp.GetClient(time.Second).Get("http://example.com/"); !errors.Is(err, http.ErrTransportPanic) {
+		t.Fatalf("error = %v, want %v", err, http.ErrTransportPanic)
+	}
+}
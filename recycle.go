@@ -0,0 +1,105 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// WithClientAutoRecycle makes the pool recycle a client once the
+// requests sent through it failed errorThreshold times in a row, for
+// instance because its connections were silently dropped by the
+// network: the idle connections of its transport are closed and the
+// client is discarded, so that it is rebuilt by the next call to
+// GetClient for its timeout. The requests cancelled by their caller do
+// not count as errors, and a response resets the count.
+//
+// The clients already handed out remain usable. Since all the clients
+// of the pool share its transport, closing the idle connections of the
+// transport, including those of its copies for the soft connection
+// limit, the affinity keys and the tenants, affects the clients for
+// every timeout.
+func WithClientAutoRecycle(errorThreshold int) Option {
+	return func(c *ClientPool) {
+		c.recycleThreshold = errorThreshold
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+}
+
+// recycleClient closes the idle connections of the client for the
+// specified timeout and discards it, if it is still the client for the
+// timeout.
+func (c *ClientPool) recycleClient(timeout time.Duration, client *http.Client) {
+	c.mtx.Lock()
+	{
+		clients := c.loadClients()
+		if clients[timeout] == client {
+			// Save the clients to a copy of the map without this
+			// one.
+			updated := make(map[time.Duration]*http.Client, len(clients))
+			for t, cl := range clients {
+				if t != timeout {
+					updated[t] = cl
+				}
+			}
+			c.clients.Store(updated)
+
+			c.closeIdleConnections(c.bases[client])
+			delete(c.bases, client)
+		}
+	}
+	c.mtx.Unlock()
+}
+
+// closeIdleConnections closes the idle connections of the transport and
+// of its copies made for the soft connection limit, the affinity keys
+// and the tenants. Must be called while holding the lock.
+func (c *ClientPool) closeIdleConnections(transport http.RoundTripper) {
+	if ci, ok := transport.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+
+	t, ok := transport.(*http.Transport)
+	if !ok {
+		return
+	}
+	if c.softLimit != nil {
+		c.softLimit.closeIdle(t)
+	}
+	if c.affinity != nil {
+		c.affinity.closeIdle(t)
+	}
+	if c.tenants != nil {
+		c.tenants.closeIdle(t)
+	}
+}
+
+// recycleTransport counts the consecutive errors of the requests sent
+// through the next transport, and recycles their client once they reach
+// the threshold.
+type recycleTransport struct {
+	next      http.RoundTripper
+	threshold int32
+	recycle   func()
+
+	// errors is the number of consecutive errors, accessed atomically.
+	errors int32
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *recycleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err == nil:
+		atomic.StoreInt32(&t.errors, 0)
+	case errors.Is(req.Context().Err(), context.Canceled):
+	case atomic.AddInt32(&t.errors, 1) == t.threshold:
+		t.recycle()
+	}
+	return resp, err
+}
@@ -0,0 +1,102 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// closeCountingTransport is a transport failing the requests to /fail,
+// counting the times its idle connections are closed.
+type closeCountingTransport struct {
+	closed int32
+}
+
+func (t *closeCountingTransport) RoundTrip(req *nethttp.Request) (*nethttp.Response, error) {
+	if req.URL.Path == "/fail" {
+		return nil, errors.New("connection reset")
+	}
+	return statusResponse(req, nethttp.StatusOK), nil
+}
+
+func (t *closeCountingTransport) CloseIdleConnections() {
+	atomic.AddInt32(&t.closed, 1)
+}
+
+func TestClientAutoRecycle(t *testing.T) {
+	transport := &closeCountingTransport{}
+	pool := http.NewClientPool(http.WithClientAutoRecycle(3))
+	pool.SetTransport(transport)
+
+	get := func(client *nethttp.Client, path string) {
+		resp, err := client.Get("http://example.com" + path)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}
+
+	client := pool.GetClient(time.Second)
+	other := pool.GetClient(2 * time.Second)
+
+	// A response resets the count of consecutive errors.
+	for _, path := range []string{"/fail", "/fail", "/", "/fail", "/fail"} {
+		get(client, path)
+	}
+	if pool.GetClient(time.Second) != client || atomic.LoadInt32(&transport.closed) != 0 {
+		t.Fatal("expected the client to be kept after non-consecutive errors")
+	}
+
+	get(client, "/fail")
+	if pool.GetClient(time.Second) == client {
+		t.Error("expected the client to be rebuilt after 3 consecutive errors")
+	}
+	if n := atomic.LoadInt32(&transport.closed); n != 1 {
+		t.Errorf("idle connections closed %d times, want 1", n)
+	}
+
+	// The other clients and the recycled client remain usable.
+	if pool.GetClient(2*time.Second) != other {
+		t.Error("expected the client for another timeout to be kept")
+	}
+	resp, err := client.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestClientAutoRecycleCopies(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	defer server.Close()
+	closed := httptest.NewServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	closed.Close()
+
+	pool := http.NewClientPool(http.WithClientAutoRecycle(3), http.WithSoftConnLimit(2), http.WithConnectionAffinity())
+	pool.SetTransport(&nethttp.Transport{})
+	client := pool.GetClient(time.Second)
+
+	ctx := context.Background()
+	keyed := http.ContextWithAffinityKey(ctx, "session")
+	limited := connAddr(t, client, ctx, server.URL)
+	affine := connAddr(t, client, keyed, server.URL)
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Get(closed.URL); err == nil {
+			t.Fatal("expected the request to the closed server to fail")
+		}
+	}
+
+	// The connections of the copies of the transport were closed too.
+	if addr := connAddr(t, client, ctx, server.URL); addr == limited {
+		t.Error("reused the connection of the soft limit after the recycle")
+	}
+	if addr := connAddr(t, client, keyed, server.URL); addr == affine {
+		t.Error("reused the connection of the affinity key after the recycle")
+	}
+}
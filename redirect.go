@@ -0,0 +1,59 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrTooManyRedirectsToHost is returned when a chain of redirects leads
+// to the same host more times than allowed by SetMaxRedirectsPerHost.
+var ErrTooManyRedirectsToHost = errors.New("http: too many redirects to host")
+
+// maxRedirects is the total number of redirects followed, as with the
+// default policy of the core http package.
+const maxRedirects = 10
+
+// SetMaxRedirectsPerHost limits the number of redirects to the same host
+// that the clients of the pool follow in a single chain of redirects, so
+// that servers bouncing requests between a few hosts are detected
+// early. Following one more redirect to a host fails the request with
+// ErrTooManyRedirectsToHost. The total number of redirects is still
+// limited to 10. Zero removes the limit.
+func (c *ClientPool) SetMaxRedirectsPerHost(n int) {
+	c.mtx.Lock()
+	{
+		c.maxRedirectsPerHost = n
+
+		// Ensuring that new clients requested from the pool will use
+		// the new redirect policy.
+		c.resetClients()
+	}
+	c.mtx.Unlock()
+}
+
+// checkRedirect returns the redirect policy of the clients, or nil for
+// the default policy. Must be called while holding the lock.
+func (c *ClientPool) checkRedirect() func(*http.Request, []*http.Request) error {
+	perHost := c.maxRedirectsPerHost
+	if perHost <= 0 {
+		return nil
+	}
+
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return errors.New("stopped after 10 redirects")
+		}
+
+		// The first request of the chain is not a redirect.
+		redirects := 1
+		for _, prev := range via[1:] {
+			if prev.URL.Host == req.URL.Host {
+				redirects++
+			}
+		}
+		if redirects > perHost {
+			return ErrTooManyRedirectsToHost
+		}
+		return nil
+	}
+}
@@ -0,0 +1,59 @@
+package http_test
+
+import (
+	"errors"
+	nethttp "net/http"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// bouncingTransport redirects every request between the hosts a and b.
+var bouncingTransport = roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+	next := "http://a.example.com/"
+	if req.URL.Host == "a.example.com" {
+		next = "http://b.example.com/"
+	}
+
+	resp := statusResponse(req, nethttp.StatusFound)
+	resp.Header.Set("Location", next)
+	return resp, nil
+})
+
+func TestMaxRedirectsPerHost(t *testing.T) {
+	var hosts []string
+	cp := http.NewClientPool()
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		hosts = append(hosts, req.URL.Host)
+		return bouncingTransport(req)
+	}))
+	cp.SetMaxRedirectsPerHost(2)
+
+	_, err := cp.GetClient(time.Second).Get("http://a.example.com/")
+	if !errors.Is(err, http.ErrTooManyRedirectsToHost) {
+		t.Fatalf("error = %v, want %v", err, http.ErrTooManyRedirectsToHost)
+	}
+
+	// a, b, a, b, a, then the third redirect to b trips the limit.
+	if len(hosts) != 5 {
+		t.Fatalf("sent requests to %v, want 5 requests", hosts)
+	}
+}
+
+func TestMaxRedirectsPerHostDefault(t *testing.T) {
+	var requests int
+	cp := http.NewClientPool()
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		requests++
+		return bouncingTransport(req)
+	}))
+
+	_, err := cp.GetClient(time.Second).Get("http://a.example.com/")
+	if err == nil || errors.Is(err, http.ErrTooManyRedirectsToHost) {
+		t.Fatalf("error = %v, want the default redirect limit", err)
+	}
+	if requests != 10 {
+		t.Fatalf("got %d requests, want 10", requests)
+	}
+}
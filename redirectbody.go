@@ -0,0 +1,94 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// ErrBodyNotReplayable is returned when a request is redirected with a
+// 307 or 308 status code, which require sending its body again, but the
+// body can only be read once.
+var ErrBodyNotReplayable = errors.New("http: request body cannot be sent again on redirect")
+
+// WithPreserveBodyOnRedirect makes the requests sent by the helpers of
+// the pool, such as Do, DoWithCancel or NewRequest, resend their body
+// when redirected with a 307 or 308 status code, which preserve the
+// method and the body of the request. The core http package only
+// follows these redirects for the requests whose GetBody function is
+// set, and otherwise returns the redirect response as is. The helpers
+// set it for the bodies implementing io.Seeker, such as files, which
+// are closed once the request is done.
+//
+// Streaming bodies cannot be read again: their requests, whether sent
+// by the helpers or directly through the clients of the pool, fail with
+// ErrBodyNotReplayable when redirected with a 307 or 308 status code.
+func WithPreserveBodyOnRedirect() Option {
+	return func(c *ClientPool) {
+		c.preserveBody = true
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+}
+
+// preserveRequestBody returns a copy of the request whose GetBody function
+// rewinds its body, if the pool preserves the bodies on redirect and the
+// body implements io.Seeker, along with a function closing the body once
+// the request is done. Otherwise, it returns the request as is.
+func (c *ClientPool) preserveRequestBody(req *http.Request) (*http.Request, func()) {
+	c.mtx.RLock()
+	preserve := c.preserveBody
+	c.mtx.RUnlock()
+
+	if !preserve || req.GetBody != nil || req.Body == nil || req.Body == http.NoBody {
+		return req, func() {}
+	}
+	s, ok := req.Body.(io.Seeker)
+	if !ok {
+		return req, func() {}
+	}
+	offset, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return req, func() {}
+	}
+
+	// The body is closed by the transport after every attempt, so its
+	// requests are given a body that cannot close it.
+	body := req.Body
+	preq := req.Clone(req.Context())
+	preq.Body = io.NopCloser(body)
+	preq.GetBody = func() (io.ReadCloser, error) {
+		if _, err := s.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return io.NopCloser(body), nil
+	}
+	return preq, func() { body.Close() }
+}
+
+// redirectBodyTransport fails the requests sent through the next
+// transport that are redirected with a 307 or 308 status code but whose
+// body cannot be sent again.
+type redirectBodyTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *redirectBodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		hasBody := req.Body != nil && req.Body != http.NoBody
+		if hasBody && req.GetBody == nil && resp.Header.Get("Location") != "" {
+			discardBody(resp)
+			return nil, ErrBodyNotReplayable
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,87 @@
+package http_test
+
+import (
+	"errors"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// newPermanentRedirectServer returns a server redirecting /old to /new
+// with a 308 status code, and echoing the method and body of the
+// requests to /new.
+func newPermanentRedirectServer() *httptest.Server {
+	return httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/old" {
+			io.Copy(io.Discard, r.Body)
+			nethttp.Redirect(w, r, "/new", nethttp.StatusPermanentRedirect)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		io.WriteString(w, r.Method+" "+string(body))
+	}))
+}
+
+func TestPreserveBodyOnRedirect(t *testing.T) {
+	server := newPermanentRedirectServer()
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "body")
+	if err := os.WriteFile(path, []byte("payload"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := http.NewClientPool(http.WithPreserveBodyOnRedirect())
+
+	// The core http package does not set the GetBody function of a file.
+	req, _ := nethttp.NewRequest(nethttp.MethodPost, server.URL+"/old", file)
+	resp, err := pool.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if string(body) != "POST payload" {
+		t.Errorf("body = %q, want %q", body, "POST payload")
+	}
+
+	// The file is closed once the request is done.
+	if _, err := file.Read(make([]byte, 1)); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("read error = %v, want %v", err, os.ErrClosed)
+	}
+}
+
+func TestPreserveBodyOnRedirectStreaming(t *testing.T) {
+	server := newPermanentRedirectServer()
+	defer server.Close()
+
+	pool := http.NewClientPool(http.WithPreserveBodyOnRedirect())
+	body := io.MultiReader(strings.NewReader("payload"))
+	req, _ := nethttp.NewRequest(nethttp.MethodPost, server.URL+"/old", body)
+	if _, err := pool.Do(req); !errors.Is(err, http.ErrBodyNotReplayable) {
+		t.Errorf("error = %v, want %v", err, http.ErrBodyNotReplayable)
+	}
+
+	// Without the option, the redirect response is returned as is.
+	req, _ = nethttp.NewRequest(nethttp.MethodPost, server.URL+"/old", io.MultiReader(strings.NewReader("payload")))
+	resp, err := http.NewClientPool().GetClient(time.Second).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != nethttp.StatusPermanentRedirect {
+		t.Errorf("status = %d, want %d", resp.StatusCode, nethttp.StatusPermanentRedirect)
+	}
+}
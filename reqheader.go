@@ -0,0 +1,124 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ErrRequestHeadersTooLarge is matched by the errors returned for the
+// requests whose header block exceeds the limit set with
+// SetMaxRequestHeaderBytes.
+var ErrRequestHeadersTooLarge = errors.New("http: request headers too large")
+
+// maxLargestHeaders is the number of headers listed in a
+// RequestHeadersTooLargeError.
+const maxLargestHeaders = 3
+
+// HeaderSize is the size of a header in a request, all its values
+// included.
+type HeaderSize struct {
+	Name string
+	Size int64
+}
+
+// RequestHeadersTooLargeError is returned, before sending them, for the
+// requests whose header block exceeds the limit set with
+// SetMaxRequestHeaderBytes. It lists the largest headers of the request,
+// largest first, to tell which one caused the failure.
+type RequestHeadersTooLargeError struct {
+	Limit   int64
+	Size    int64
+	Largest []HeaderSize
+}
+
+func (e *RequestHeadersTooLargeError) Error() string {
+	largest := make([]string, len(e.Largest))
+	for i, h := range e.Largest {
+		largest[i] = fmt.Sprintf("%s: %d bytes", h.Name, h.Size)
+	}
+	return fmt.Sprintf("http: request header of %d bytes exceeds the limit of %d bytes (largest %s)",
+		e.Size, e.Limit, strings.Join(largest, ", "))
+}
+
+func (e *RequestHeadersTooLargeError) Unwrap() error {
+	return ErrRequestHeadersTooLarge
+}
+
+// SetMaxRequestHeaderBytes limits the size of the request line and
+// header block of the requests sent by the clients of the pool, measured
+// as sent on the wire by HTTP/1. Requests exceeding n bytes are not sent
+// and fail with a *RequestHeadersTooLargeError, instead of the cryptic
+// error a server would return. The headers added by the transport
+// itself, such as User-Agent, are not accounted for. A non-positive
+// limit removes the check.
+func (c *ClientPool) SetMaxRequestHeaderBytes(n int64) {
+	c.mtx.Lock()
+	{
+		c.maxRequestHeaderBytes = n
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+	c.mtx.Unlock()
+}
+
+// requestHeaderLimitTransport rejects the requests whose header block
+// exceeds the limit before sending them through the next transport.
+type requestHeaderLimitTransport struct {
+	next  http.RoundTripper
+	limit int64
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *requestHeaderLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	size, headers := requestHeaderSize(req)
+	if size <= t.limit {
+		return t.next.RoundTrip(req)
+	}
+
+	closeRequestBody(req)
+	sort.Slice(headers, func(i, j int) bool {
+		return headers[i].Size > headers[j].Size
+	})
+	if len(headers) > maxLargestHeaders {
+		headers = headers[:maxLargestHeaders]
+	}
+	return nil, &RequestHeadersTooLargeError{Limit: t.limit, Size: size, Largest: headers}
+}
+
+// requestHeaderSize returns the size of the request line and header
+// block of a request as written by HTTP/1, along with the size of every
+// header.
+func requestHeaderSize(req *http.Request) (int64, []HeaderSize) {
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	// The request line, such as "GET /path HTTP/1.1\r\n".
+	size := int64(len(method) + len(req.URL.RequestURI()) + len("HTTP/1.1") + 4)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	// "Host: host\r\n"
+	headers := []HeaderSize{{Name: "Host", Size: int64(len(host) + 8)}}
+	for key, values := range req.Header {
+		h := HeaderSize{Name: key}
+		for _, value := range values {
+			// "Key: value\r\n"
+			h.Size += int64(len(key) + len(value) + 4)
+		}
+		headers = append(headers, h)
+	}
+
+	for _, h := range headers {
+		size += h.Size
+	}
+	// The empty line ending the block.
+	return size + 2, headers
+}
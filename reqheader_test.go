@@ -0,0 +1,66 @@
+package http_test
+
+import (
+	"errors"
+	nethttp "net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestMaxRequestHeaderBytes(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool()
+	cp.SetTransport(failingTransport(0, 0, &calls))
+	cp.SetMaxRequestHeaderBytes(1 << 10)
+	client := cp.GetClient(time.Second)
+
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/", nil)
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	req.Header.Set("Cookie", "session="+strings.Repeat("x", 2<<10))
+	req.Header.Set("X-Trace", strings.Repeat("y", 100))
+	_, err = client.Do(req)
+	if !errors.Is(err, http.ErrRequestHeadersTooLarge) {
+		t.Fatalf("error = %v, want %v", err, http.ErrRequestHeadersTooLarge)
+	}
+	if calls != 1 {
+		t.Errorf("got %d requests sent, want 1", calls)
+	}
+
+	var tooLarge *http.RequestHeadersTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("error = %v, want a *RequestHeadersTooLargeError", err)
+	}
+	if tooLarge.Limit != 1<<10 || tooLarge.Size <= 2<<10 {
+		t.Errorf("got limit %d and size %d, want 1024 and more than 2048", tooLarge.Limit, tooLarge.Size)
+	}
+	if len(tooLarge.Largest) != 3 || tooLarge.Largest[0].Name != "Cookie" || tooLarge.Largest[1].Name != "X-Trace" {
+		t.Errorf("got largest headers %v, want Cookie then X-Trace", tooLarge.Largest)
+	}
+	if want := len("Cookie: session=\r\n") + 2<<10; tooLarge.Largest[0].Size != int64(want) {
+		t.Errorf("got Cookie size %d, want %d", tooLarge.Largest[0].Size, want)
+	}
+}
+
+func TestMaxRequestHeaderBytesRemoved(t *testing.T) {
+	cp := http.NewClientPool()
+	cp.SetTransport(okTransport)
+	cp.SetMaxRequestHeaderBytes(64)
+	cp.SetMaxRequestHeaderBytes(0)
+
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/", nil)
+	req.Header.Set("Cookie", strings.Repeat("x", 1<<10))
+	resp, err := cp.GetClient(time.Second).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// RequestError is returned by Do and the other helpers of the pool
+// sending requests when a request fails. It wraps the error returned by
+// the client.
+type RequestError struct {
+	Method string
+	URL    string
+
+	// Attempts is the number of times the request was sent, which is
+	// more than one when the pool retries requests.
+	Attempts int
+
+	// Elapsed is the time spent sending the request, including the
+	// retries.
+	Elapsed time.Duration
+
+	Err error
+}
+
+func (e *RequestError) Error() string {
+	err := e.Err
+	if uerr, ok := err.(*url.Error); ok {
+		err = uerr.Err
+	}
+	return fmt.Sprintf("http: %s %s failed after %d attempt(s) in %v: %v", e.Method, e.URL, e.Attempts, e.Elapsed, err)
+}
+
+// Unwrap returns the error returned by the client.
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// attemptCountKey is the context key for the number of attempts made at
+// sending a request, counted by the retry transport.
+type attemptCountKey struct{}
+
+// countAttempt records an attempt at sending the request, if counted.
+func countAttempt(req *http.Request) {
+	if n, ok := req.Context().Value(attemptCountKey{}).(*int32); ok {
+		atomic.AddInt32(n, 1)
+	}
+}
+
+// do sends the request with the client as send does, decoding the
+// non-2xx responses with the error decoder of the pool, if any.
+func (c *ClientPool) do(client *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := c.send(client, req)
+	if err != nil {
+		return nil, err
+	}
+	return c.decodeError(resp)
+}
+
+// send sends the request with the client, wrapping its error in a
+// RequestError. The body of the request is preserved on redirect with
+// WithPreserveBodyOnRedirect.
+func (c *ClientPool) send(client *http.Client, req *http.Request) (*http.Response, error) {
+	req, done := c.preserveRequestBody(req)
+	defer done()
+
+	var attempts int32
+	ctx := context.WithValue(req.Context(), attemptCountKey{}, &attempts)
+
+	start := time.Now()
+	resp, err := contextDoer(ctx, client).Do(req.WithContext(ctx))
+	if err == nil {
+		return resp, nil
+	}
+
+	n := int(atomic.LoadInt32(&attempts))
+	if n == 0 {
+		n = 1
+	}
+	return nil, &RequestError{
+		Method:   req.Method,
+		URL:      req.URL.Redacted(),
+		Attempts: n,
+		Elapsed:  time.Since(start),
+		Err:      err,
+	}
+}
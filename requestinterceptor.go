@@ -0,0 +1,84 @@
+package http
+
+import (
+	"net/http"
+)
+
+// SetRequestInterceptor sets a function called with every request sent
+// by the clients of the pool before any other middleware handles it,
+// for instance to add headers derived from the context of the request
+// or to rewrite its URL. The function is given a copy of the request,
+// which it can modify; returning an error aborts the request, which
+// fails with the error. Since it runs first, the middleware
+// authenticating or signing requests, such as the one installed by
+// WithOAuth2, see its changes. A nil function removes the interceptor.
+// The function is also called for the requests of the redirects.
+func (c *ClientPool) SetRequestInterceptor(fn func(*http.Request) error) {
+	c.mtx.Lock()
+	{
+		c.requestInterceptor = fn
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+	c.mtx.Unlock()
+}
+
+// requestInterceptorTransport calls the interceptor with the requests
+// before sending them through the next transport.
+type requestInterceptorTransport struct {
+	next        http.RoundTripper
+	interceptor func(*http.Request) error
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *requestInterceptorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ireq := req.Clone(req.Context())
+	if err := t.interceptor(ireq); err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+	return t.next.RoundTrip(ireq)
+}
+
+// SetResponseInterceptor sets a function called with every response
+// received by the clients of the pool, after every middleware handled
+// it and before it is returned to the caller, for instance to record
+// deprecation headers. The body is left untouched unless the function
+// reads it, in which case it must replace it for the caller. Returning
+// an error fails the request with the error, the body of the response
+// being closed. A nil function removes the interceptor. The function is
+// also called for the responses of the redirects.
+func (c *ClientPool) SetResponseInterceptor(fn func(*http.Response) error) {
+	c.mtx.Lock()
+	{
+		c.responseInterceptor = fn
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+	c.mtx.Unlock()
+}
+
+// responseInterceptorTransport calls the interceptor with the responses
+// of the next transport.
+type responseInterceptorTransport struct {
+	next        http.RoundTripper
+	interceptor func(*http.Response) error
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *responseInterceptorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.interceptor(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,136 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// correlationKey is the context key of the correlation ID of the tests.
+type correlationKey struct{}
+
+func TestRequestInterceptor(t *testing.T) {
+	headers := make(chan nethttp.Header, 1)
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		headers <- r.Header
+	}))
+	defer server.Close()
+	green := strings.TrimPrefix(server.URL, "http://")
+
+	cp := http.NewClientPool()
+	cp.SetRequestInterceptor(func(req *nethttp.Request) error {
+		if req.URL.Host == "blue.example.com" {
+			req.URL.Host = green
+			req.Host = ""
+		}
+		if id, ok := req.Context().Value(correlationKey{}).(string); ok {
+			req.Header.Set("X-Correlation-Id", id)
+		}
+		return nil
+	})
+
+	ctx := context.WithValue(context.Background(), correlationKey{}, "abc")
+	req, _ := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, "http://blue.example.com/", nil)
+	resp, err := cp.GetClient(time.Second).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got := (<-headers).Get("X-Correlation-Id"); got != "abc" {
+		t.Errorf("got correlation ID %q, want %q", got, "abc")
+	}
+	if req.URL.Host != "blue.example.com" || req.Header.Get("X-Correlation-Id") != "" {
+		t.Error("the request of the caller was modified")
+	}
+}
+
+func TestRequestInterceptorAbort(t *testing.T) {
+	var calls int32
+	errDenied := errors.New("denied")
+	cp := http.NewClientPool(http.WithRetry(3, time.Millisecond))
+	cp.SetTransport(failingTransport(0, 0, &calls))
+	cp.SetRequestInterceptor(func(*nethttp.Request) error { return errDenied })
+
+	if _, err := cp.GetClient(time.Second).Get("http://example.com/"); !errors.Is(err, errDenied) {
+		t.Fatalf("error = %v, want %v", err, errDenied)
+	}
+	if calls != 0 {
+		t.Errorf("got %d requests sent, want 0", calls)
+	}
+
+	cp.SetRequestInterceptor(nil)
+	resp, err := cp.GetClient(time.Second).Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestResponseInterceptor(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/old" {
+			w.Header().Set("Deprecation", "true")
+		}
+		w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	var deprecated []string
+	var seen int
+	cp := http.NewClientPool()
+	cp.SetResponseInterceptor(func(resp *nethttp.Response) error {
+		seen++
+		if resp.Header.Get("Deprecation") != "" {
+			deprecated = append(deprecated, resp.Request.URL.Path)
+		}
+		return nil
+	})
+	client := cp.GetClient(time.Second)
+
+	for _, path := range []string{"/new", "/old"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		// The interceptor did not consume the body.
+		if string(body) != "body" {
+			t.Errorf("got body %q, want %q", body, "body")
+		}
+	}
+	if seen != 2 || len(deprecated) != 1 || deprecated[0] != "/old" {
+		t.Errorf("saw %d responses and deprecated paths %v, want 2 and [/old]", seen, deprecated)
+	}
+}
+
+func TestResponseInterceptorAbort(t *testing.T) {
+	errGone := errors.New("gone")
+	cp := http.NewClientPool()
+	cp.SetTransport(failingTransport(1, nethttp.StatusGone, new(int32)))
+	cp.SetResponseInterceptor(func(resp *nethttp.Response) error {
+		if resp.StatusCode == nethttp.StatusGone {
+			return errGone
+		}
+		return nil
+	})
+	client := cp.GetClient(time.Second)
+
+	if _, err := client.Get("http://example.com/"); !errors.Is(err, errGone) {
+		t.Fatalf("error = %v, want %v", err, errGone)
+	}
+	resp, err := client.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
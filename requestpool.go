@@ -0,0 +1,66 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// RequestPool recycles the requests built on hot paths, saving the
+// allocation of the request and of its header for every call. The zero
+// value is ready to use, and a RequestPool is safe for concurrent use.
+//
+// A released request is reset and handed to a later caller of Acquire,
+// so it must not be referenced once released: release a request only
+// after the response to it has been read entirely and its body closed,
+// and never release the copies returned by its WithContext and Clone
+// methods in place of the request itself, nor a request whose body is
+// still read by a transport.
+type RequestPool struct {
+	pool sync.Pool
+}
+
+// Acquire returns a request with the specified method and URL, as
+// returned by http.NewRequest, reusing a released request if any. The
+// request has an empty header, no body and the background context.
+func (p *RequestPool) Acquire(method, rawURL string) (*http.Request, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, _ := p.pool.Get().(*http.Request)
+	if req == nil {
+		req = &http.Request{Header: make(http.Header)}
+	}
+	*req = http.Request{
+		Method:     method,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     req.Header,
+		Host:       u.Host,
+	}
+	return req, nil
+}
+
+// Release resets the request and returns it to the pool, to be reused by
+// a later call to Acquire. The request must not be used afterwards.
+func (p *RequestPool) Release(req *http.Request) {
+	header := req.Header
+	if header == nil {
+		header = make(http.Header)
+	}
+	for name := range header {
+		delete(header, name)
+	}
+
+	// Resetting the request drops its context, body and response, so
+	// that they do not outlive it.
+	*req = http.Request{Header: header}
+	p.pool.Put(req)
+}
@@ -0,0 +1,85 @@
+package http_test
+
+import (
+	"context"
+	nethttp "net/http"
+	"testing"
+
+	"github.com/Updater/http"
+)
+
+type requestPoolKey struct{}
+
+func TestRequestPool(t *testing.T) {
+	var pool http.RequestPool
+
+	for i := 0; i < 100; i++ {
+		req, err := pool.Acquire(nethttp.MethodGet, "http://example.com/items?page=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Nothing is left from the previous acquisitions.
+		if len(req.Header) != 0 || req.Body != nil || req.ContentLength != 0 || req.Context() != context.Background() {
+			t.Fatalf("acquisition %d: got header %v, body %v and context %v, want a fresh request", i, req.Header, req.Body, req.Context())
+		}
+		if req.Method != nethttp.MethodGet || req.URL.String() != "http://example.com/items?page=1" || req.Host != "example.com" {
+			t.Fatalf("acquisition %d: got %s %s for host %s", i, req.Method, req.URL, req.Host)
+		}
+
+		req.Header.Set("X-Request", "previous")
+		req.Body = nethttp.NoBody
+		req.ContentLength = 8
+		*req = *req.WithContext(context.WithValue(context.Background(), requestPoolKey{}, i))
+		pool.Release(req)
+	}
+
+	if _, err := pool.Acquire(nethttp.MethodGet, "://invalid"); err == nil {
+		t.Error("expected an error for an invalid URL")
+	}
+}
+
+func TestRequestPoolSend(t *testing.T) {
+	var pool http.RequestPool
+	cp := http.NewClientPool()
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		if req.Method != nethttp.MethodPost || req.Header.Get("Content-Type") != "text/plain" {
+			t.Errorf("got %s request with header %v", req.Method, req.Header)
+		}
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+
+	for i := 0; i < 10; i++ {
+		req, err := pool.Acquire(nethttp.MethodPost, "http://example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "text/plain")
+		req.Body = nethttp.NoBody
+
+		resp, err := cp.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		pool.Release(req)
+	}
+}
+
+func BenchmarkNewRequest(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		req, _ := nethttp.NewRequest(nethttp.MethodGet, "http://example.com/items", nil)
+		req.Header.Set("Accept", "application/json")
+	}
+}
+
+func BenchmarkRequestPool(b *testing.B) {
+	var pool http.RequestPool
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		req, _ := pool.Acquire(nethttp.MethodGet, "http://example.com/items")
+		req.Header.Set("Accept", "application/json")
+		pool.Release(req)
+	}
+}
@@ -0,0 +1,280 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Attempt describes a single attempt at sending a request.
+type Attempt struct {
+	// StatusCode is the status code of the response, or 0 if the
+	// attempt failed with an error.
+	StatusCode int
+	Err        error
+	Duration   time.Duration
+}
+
+// attemptsKey is the context key for the attempts that led to a response.
+type attemptsKey struct{}
+
+// AttemptsFromResponse returns the attempts made to obtain the specified
+// response, in order, the last one being the attempt that produced it.
+// It returns nil if the response was not obtained through a pool with
+// retries enabled.
+func AttemptsFromResponse(resp *http.Response) []Attempt {
+	if resp == nil || resp.Request == nil {
+		return nil
+	}
+
+	attempts, _ := resp.Request.Context().Value(attemptsKey{}).([]Attempt)
+	return attempts
+}
+
+// RetryClassifier reports whether a request that produced the specified
+// response or error should be retried. Exactly one of resp and err is
+// non-nil.
+type RetryClassifier func(req *http.Request, resp *http.Response, err error) bool
+
+// DefaultRetryClassifier is the RetryClassifier used when none is
+// specified. It retries requests that failed to connect, since nothing
+// was sent to the server, and requests with an idempotent method that
+// received a 5xx status code.
+func DefaultRetryClassifier(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		return isDialError(err)
+	}
+	return resp.StatusCode >= http.StatusInternalServerError && isIdempotent(req)
+}
+
+// retryConfig holds the settings of the retry transport.
+type retryConfig struct {
+	classifier RetryClassifier
+
+	// maxBackoff caps the delay between two attempts, and budget the
+	// total time spent on a request. Zero means no limit.
+	maxBackoff time.Duration
+	budget     time.Duration
+
+	// handshakeAttempts is the number of attempts of the requests
+	// whose TLS handshakes time out, if they are retried separately.
+	handshakeAttempts int
+}
+
+// RetryOption configures the retries installed by WithRetry.
+type RetryOption func(*retryConfig)
+
+// WithRetryClassifier sets the function deciding which requests are
+// retried. By default DefaultRetryClassifier is used.
+func WithRetryClassifier(classifier RetryClassifier) RetryOption {
+	return func(rc *retryConfig) {
+		rc.classifier = classifier
+	}
+}
+
+// WithMaxBackoff caps the delay before a retry, which otherwise doubles
+// after each retry.
+func WithMaxBackoff(d time.Duration) RetryOption {
+	return func(rc *retryConfig) {
+		rc.maxBackoff = d
+	}
+}
+
+// WithRetryBudget bounds the total time spent on a request, attempts and
+// delays before the retries included. No retry is made once the delay
+// before it would exceed the budget, in which case the last response or
+// error is returned even if attempts remain.
+func WithRetryBudget(maxTotal time.Duration) RetryOption {
+	return func(rc *retryConfig) {
+		rc.budget = maxTotal
+	}
+}
+
+// WithHandshakeTimeoutRetries retries the requests whose TLS handshake
+// timed out separately from the other failures, up to a total of
+// maxAttempts handshake timeouts, whatever their method and the
+// classifier, since nothing was sent to the server. These attempts do
+// not count towards the attempts set with WithRetry.
+func WithHandshakeTimeoutRetries(maxAttempts int) RetryOption {
+	return func(rc *retryConfig) {
+		rc.handshakeAttempts = maxAttempts
+	}
+}
+
+// WithRetry makes the clients of the pool retry failing requests, up to
+// a total of maxAttempts attempts. The delay before the first retry is
+// backoff and doubles after each retry. Requests with a body are only
+// retried if their GetBody function is set, so the body can be sent
+// again. No retry is made if the delay before it would exceed the
+// deadline of the request, in which case the last response or error is
+// returned.
+func WithRetry(maxAttempts int, backoff time.Duration, opts ...RetryOption) Option {
+	config := retryConfig{
+		classifier: DefaultRetryClassifier,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			return &retryTransport{
+				next:        next,
+				maxAttempts: maxAttempts,
+				backoff:     backoff,
+				config:      config,
+				clock:       c.timeSource(),
+			}
+		})
+	}
+}
+
+// retryTransport retries the requests failing on the next transport.
+type retryTransport struct {
+	next        http.RoundTripper
+	maxAttempts int
+	backoff     time.Duration
+	config      retryConfig
+	clock       clock
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var attempts []Attempt
+	maxAttempts, backoff, config := t.retryPolicy(req.Context())
+	begin := t.clock.Now()
+	handshakeTimeouts := 0
+
+	observers := retryObservers(req)
+	for attempt := 1; ; attempt++ {
+		areq := req
+		if attempt > 1 {
+			var err error
+			if areq, err = rewindRequest(req); err != nil {
+				return nil, err
+			}
+			for _, observer := range observers {
+				observer.RetryAttempted(req.URL.Host, attempt)
+			}
+		}
+
+		countAttempt(req)
+		start := t.clock.Now()
+		resp, err := t.next.RoundTrip(areq)
+
+		status := 0
+		if err == nil {
+			status = resp.StatusCode
+		}
+		attempts = append(attempts, Attempt{
+			StatusCode: status,
+			Err:        err,
+			Duration:   t.clock.Now().Sub(start),
+		})
+
+		if config.maxBackoff > 0 && backoff > config.maxBackoff {
+			backoff = config.maxBackoff
+		}
+		overBudget := config.budget > 0 && t.clock.Now().Sub(begin)+backoff > config.budget
+		if deadline, ok := req.Context().Deadline(); ok && deadline.Sub(t.clock.Now()) < backoff {
+			// The retry would fail with the deadline of the request,
+			// hiding the outcome of the last attempt.
+			overBudget = true
+		}
+
+		// The handshake timeouts have their own number of attempts,
+		// which do not count towards the others.
+		var retry, exhausted bool
+		if config.handshakeAttempts > 0 && isTLSHandshakeTimeout(err) {
+			handshakeTimeouts++
+			retry, exhausted = true, handshakeTimeouts >= config.handshakeAttempts
+		} else {
+			retry = config.classifier(areq, resp, err)
+			exhausted = attempt-handshakeTimeouts >= maxAttempts
+		}
+		if !retry && err == nil && attempt > 1 {
+			for _, observer := range observers {
+				observer.RetrySucceeded(req.URL.Host, attempt)
+			}
+		}
+
+		if exhausted || overBudget || !canRewind(req) || !retry {
+			if resp != nil {
+				if resp.Request == nil {
+					resp.Request = areq
+				}
+				resp.Request = resp.Request.WithContext(
+					context.WithValue(resp.Request.Context(), attemptsKey{}, attempts))
+			}
+			return resp, err
+		}
+
+		if resp != nil {
+			discardBody(resp)
+		}
+
+		if err := sleep(req.Context(), t.clock, backoff); err != nil {
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+// isDialError reports whether the error occurred while establishing a
+// connection, in which case the request was not sent.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// isTLSHandshakeTimeout reports whether the error occurred because the
+// TLS handshake timed out, in which case the request was not sent. The
+// core http package does not export the type of the error, which is
+// identified by its message.
+func isTLSHandshakeTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout() &&
+		strings.Contains(netErr.Error(), "TLS handshake timeout")
+}
+
+// isIdempotent reports whether a request can be safely sent more than
+// once, following the same rules as the core http package.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	if req.Context().Value(idempotencyKey{}) != nil {
+		return true
+	}
+
+	_, ok := req.Header["Idempotency-Key"]
+	if !ok {
+		_, ok = req.Header["X-Idempotency-Key"]
+	}
+	return ok
+}
+
+// canRewind reports whether the body of a request can be sent again.
+func canRewind(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewindRequest returns a copy of the request with a fresh body so it can
+// be sent again.
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	rreq := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		rreq.Body = body
+	}
+	return rreq, nil
+}
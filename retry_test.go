@@ -0,0 +1,345 @@
+package http_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	nethttp "net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// failingTransport fails the first n requests with the specified status
+// code, or with errFailing if the code is zero, and then succeeds.
+func failingTransport(n int, code int, calls *int32) nethttp.RoundTripper {
+	return roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		if req.Body != nil {
+			io.Copy(io.Discard, req.Body)
+			req.Body.Close()
+		}
+
+		if int(atomic.AddInt32(calls, 1)) > n {
+			return statusResponse(req, nethttp.StatusOK), nil
+		}
+		if code == 0 {
+			return nil, errFailing
+		}
+		return statusResponse(req, code), nil
+	})
+}
+
+// errFailing simulates a failure to connect to the server.
+var errFailing = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+func TestRetryAttemptHistory(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool(http.WithRetry(3, time.Millisecond))
+	cp.SetTransport(failingTransport(2, nethttp.StatusServiceUnavailable, &calls))
+
+	resp, err := cp.GetClient(time.Second).Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	attempts := http.AttemptsFromResponse(resp)
+	if len(attempts) != 3 {
+		t.Fatalf("got %d attempts, want 3", len(attempts))
+	}
+	for i, want := range []int{503, 503, 200} {
+		if attempts[i].StatusCode != want {
+			t.Errorf("attempt %d: status = %d, want %d", i+1, attempts[i].StatusCode, want)
+		}
+	}
+}
+
+func TestRetryAttemptHistoryErrors(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool(http.WithRetry(3, time.Millisecond))
+	cp.SetTransport(failingTransport(1, 0, &calls))
+
+	resp, err := cp.GetClient(time.Second).Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	attempts := http.AttemptsFromResponse(resp)
+	if len(attempts) != 2 {
+		t.Fatalf("got %d attempts, want 2", len(attempts))
+	}
+	if !errors.Is(attempts[0].Err, errFailing) || attempts[0].StatusCode != 0 {
+		t.Errorf("first attempt = %+v, want a %v error", attempts[0], errFailing)
+	}
+	if attempts[1].Err != nil || attempts[1].StatusCode != nethttp.StatusOK {
+		t.Errorf("second attempt = %+v, want a 200 status", attempts[1])
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool(http.WithRetry(3, time.Millisecond))
+	cp.SetTransport(failingTransport(10, 0, &calls))
+
+	if _, err := cp.GetClient(time.Second).Get("http://example.com/"); !errors.Is(err, errFailing) {
+		t.Fatalf("error = %v, want %v", err, errFailing)
+	}
+	if calls != 3 {
+		t.Fatalf("got %d attempts, want 3", calls)
+	}
+}
+
+func TestRetryRewindsBody(t *testing.T) {
+	var bodies []string
+	cp := http.NewClientPool(http.WithRetry(2, time.Millisecond))
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		req.Body.Close()
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			return statusResponse(req, nethttp.StatusBadGateway), nil
+		}
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+
+	req, _ := nethttp.NewRequest(nethttp.MethodPut, "http://example.com/", strings.NewReader("payload"))
+	resp, err := cp.GetClient(time.Second).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(bodies) != 2 || bodies[0] != "payload" || bodies[1] != "payload" {
+		t.Fatalf("bodies = %q, want the payload twice", bodies)
+	}
+}
+
+func TestNoAttemptHistoryWithoutRetry(t *testing.T) {
+	cp := http.NewClientPool()
+	cp.SetTransport(okTransport)
+
+	resp, err := cp.GetClient(time.Second).Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts := http.AttemptsFromResponse(resp); attempts != nil {
+		t.Fatalf("attempts = %v, want nil", attempts)
+	}
+}
+
+func TestDefaultRetryClassifier(t *testing.T) {
+	tests := []struct {
+		method string
+		code   int
+		want   int32
+	}{
+		{nethttp.MethodGet, nethttp.StatusServiceUnavailable, 2},
+		{nethttp.MethodPost, nethttp.StatusServiceUnavailable, 1},
+		{nethttp.MethodPost, 0, 2},
+		{nethttp.MethodGet, nethttp.StatusNotFound, 1},
+	}
+
+	for _, tt := range tests {
+		var calls int32
+		cp := http.NewClientPool(http.WithRetry(2, time.Millisecond))
+		cp.SetTransport(failingTransport(1, tt.code, &calls))
+
+		req, _ := nethttp.NewRequest(tt.method, "http://example.com/", nil)
+		if resp, err := cp.GetClient(time.Second).Do(req); err == nil {
+			resp.Body.Close()
+		}
+		if calls != tt.want {
+			t.Errorf("%s with status %d: got %d attempts, want %d", tt.method, tt.code, calls, tt.want)
+		}
+	}
+}
+
+func TestRetryClassifierCustom(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool(http.WithRetry(3, time.Millisecond,
+		http.WithRetryClassifier(func(req *nethttp.Request, resp *nethttp.Response, err error) bool {
+			return err == nil && resp.StatusCode == nethttp.StatusTooManyRequests
+		}),
+	))
+	cp.SetTransport(failingTransport(2, nethttp.StatusTooManyRequests, &calls))
+
+	resp, err := cp.GetClient(time.Second).Post("http://example.com/", "text/plain", strings.NewReader("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if calls != 3 || resp.StatusCode != nethttp.StatusOK {
+		t.Fatalf("got %d attempts ending with %d, want 3 ending with 200", calls, resp.StatusCode)
+	}
+}
+
+func TestRetryClassifierRefusesReceived(t *testing.T) {
+	// Only retry when the request never reached the server.
+	onlyUnsent := func(req *nethttp.Request, resp *nethttp.Response, err error) bool {
+		var opErr *net.OpError
+		return err != nil && errors.As(err, &opErr) && opErr.Op == "dial"
+	}
+
+	var calls int32
+	cp := http.NewClientPool(http.WithRetry(3, time.Millisecond, http.WithRetryClassifier(onlyUnsent)))
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, io.ErrUnexpectedEOF
+	}))
+
+	if _, err := cp.GetClient(time.Second).Get("http://example.com/"); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	if calls != 1 {
+		t.Fatalf("got %d attempts, want 1", calls)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool(http.WithRetry(10, 20*time.Millisecond, http.WithRetryBudget(50*time.Millisecond)))
+	cp.SetTransport(failingTransport(10, 0, &calls))
+
+	// The second retry would wait until 60ms, past the budget.
+	start := time.Now()
+	if _, err := cp.GetClient(time.Second).Get("http://example.com/"); !errors.Is(err, errFailing) {
+		t.Fatalf("error = %v, want %v", err, errFailing)
+	}
+	if calls != 2 {
+		t.Errorf("got %d attempts, want 2", calls)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("request took %v, want at most 50ms", elapsed)
+	}
+}
+
+func TestRetryBudgetReturnsLastResponse(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool(http.WithRetry(10, 20*time.Millisecond, http.WithRetryBudget(30*time.Millisecond)))
+	cp.SetTransport(failingTransport(10, nethttp.StatusServiceUnavailable, &calls))
+
+	resp, err := cp.GetClient(time.Second).Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if calls != 2 || resp.StatusCode != nethttp.StatusServiceUnavailable {
+		t.Fatalf("got %d attempts ending with %d, want 2 ending with 503", calls, resp.StatusCode)
+	}
+	if attempts := http.AttemptsFromResponse(resp); len(attempts) != 2 {
+		t.Errorf("got %d attempts in the history, want 2", len(attempts))
+	}
+}
+
+func TestRetryMaxBackoff(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool(http.WithRetry(4, 20*time.Millisecond, http.WithMaxBackoff(20*time.Millisecond)))
+	cp.SetTransport(failingTransport(10, 0, &calls))
+
+	// Without the ceiling, the delays would add up to 140ms.
+	start := time.Now()
+	if _, err := cp.GetClient(time.Second).Get("http://example.com/"); !errors.Is(err, errFailing) {
+		t.Fatalf("error = %v, want %v", err, errFailing)
+	}
+	if calls != 4 {
+		t.Errorf("got %d attempts, want 4", calls)
+	}
+	if elapsed := time.Since(start); elapsed >= 120*time.Millisecond {
+		t.Errorf("request took %v, want less than 120ms", elapsed)
+	}
+}
+
+// stalledTLSListener accepts connections and never answers, so that the
+// TLS handshakes with it time out.
+type stalledTLSListener struct {
+	net.Listener
+	accepted int32
+}
+
+func newStalledTLSListener(t *testing.T) *stalledTLSListener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &stalledTLSListener{Listener: ln}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&l.accepted, 1)
+			conns = append(conns, conn)
+		}
+	}()
+	return l
+}
+
+func TestHandshakeTimeoutRetries(t *testing.T) {
+	for _, tt := range []struct {
+		opts []http.RetryOption
+		want int32
+	}{
+		{want: 1},
+		{opts: []http.RetryOption{http.WithHandshakeTimeoutRetries(3)}, want: 3},
+	} {
+		ln := newStalledTLSListener(t)
+		cp := http.NewClientPool(http.WithRetry(2, time.Millisecond, tt.opts...))
+		client := cp.GetClientTimeouts(http.Timeouts{TLSHandshake: 20 * time.Millisecond, Overall: 5 * time.Second})
+
+		// The handshake timeouts of requests that are not idempotent
+		// are retried too.
+		_, err := client.Post("https://"+ln.Addr().String()+"/", "text/plain", strings.NewReader("x"))
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			t.Fatalf("error = %v, want a handshake timeout", err)
+		}
+		if accepted := atomic.LoadInt32(&ln.accepted); accepted != tt.want {
+			t.Errorf("got %d attempts, want %d", accepted, tt.want)
+		}
+	}
+}
+
+// handshakeTimeoutError mimics the handshake timeouts of the core http
+// package.
+type handshakeTimeoutError struct{}
+
+func (handshakeTimeoutError) Error() string   { return "net/http: TLS handshake timeout" }
+func (handshakeTimeoutError) Timeout() bool   { return true }
+func (handshakeTimeoutError) Temporary() bool { return true }
+
+func TestHandshakeTimeoutRetriesSeparate(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool(http.WithRetry(2, time.Millisecond, http.WithHandshakeTimeoutRetries(3)))
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			return nil, handshakeTimeoutError{}
+		}
+		return statusResponse(req, nethttp.StatusServiceUnavailable), nil
+	}))
+
+	resp, err := cp.GetClient(time.Second).Get("https://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// The two handshake timeouts leave the two attempts of WithRetry.
+	if calls != 4 || resp.StatusCode != nethttp.StatusServiceUnavailable {
+		t.Errorf("got %d attempts ending with %d, want 4 ending with 503", calls, resp.StatusCode)
+	}
+}
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"context"
+	"time"
+)
+
+// RetryPolicy overrides the retries installed by WithRetry for the
+// requests whose context carries it, see ContextWithRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts. A value of 1 or less
+	// disables the retries, those of the TLS handshake timeouts
+	// included.
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry, which doubles after
+	// each retry. Zero means the backoff of the pool is used.
+	Backoff time.Duration
+
+	// Classifier decides which requests are retried. Nil means the
+	// classifier of the pool is used.
+	Classifier RetryClassifier
+}
+
+// retryPolicyKey is the context key for the retry policy of a request.
+type retryPolicyKey struct{}
+
+// ContextWithRetryPolicy returns a copy of the context carrying the retry
+// policy, which the pool uses in place of the settings of WithRetry for
+// the requests sent with the context, for instance to never retry a
+// write that is not idempotent. The policy has no effect if the pool was
+// not configured with WithRetry.
+func ContextWithRetryPolicy(ctx context.Context, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, policy)
+}
+
+// retryPolicy returns the retry settings of the transport, overridden by
+// the policy of the request context, if any.
+func (t *retryTransport) retryPolicy(ctx context.Context) (maxAttempts int, backoff time.Duration, config retryConfig) {
+	maxAttempts, backoff, config = t.maxAttempts, t.backoff, t.config
+
+	policy, ok := ctx.Value(retryPolicyKey{}).(RetryPolicy)
+	if !ok {
+		return maxAttempts, backoff, config
+	}
+	maxAttempts = policy.MaxAttempts
+	if maxAttempts <= 1 {
+		maxAttempts, config.handshakeAttempts = 1, 0
+	}
+	if policy.Backoff > 0 {
+		backoff = policy.Backoff
+	}
+	if policy.Classifier != nil {
+		config.classifier = policy.Classifier
+	}
+	return maxAttempts, backoff, config
+}
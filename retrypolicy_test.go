@@ -0,0 +1,70 @@
+package http_test
+
+import (
+	"context"
+	nethttp "net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestContextWithRetryPolicy(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool(http.WithRetry(3, time.Millisecond))
+	cp.SetTransport(failingTransport(1, nethttp.StatusServiceUnavailable, &calls))
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		wantCode  int
+		wantCalls int32
+	}{
+		{"default", context.Background(), nethttp.StatusOK, 2},
+		{"disabled", http.ContextWithRetryPolicy(context.Background(), http.RetryPolicy{MaxAttempts: 1}), nethttp.StatusServiceUnavailable, 1},
+		{"more attempts", http.ContextWithRetryPolicy(context.Background(), http.RetryPolicy{
+			MaxAttempts: 5,
+			Classifier: func(_ *nethttp.Request, resp *nethttp.Response, err error) bool {
+				return err != nil || resp.StatusCode != nethttp.StatusOK
+			},
+		}), nethttp.StatusOK, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+
+			req, _ := nethttp.NewRequestWithContext(tt.ctx, nethttp.MethodGet, "http://example.com/", nil)
+			resp, err := cp.GetClient(0).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+			if n := atomic.LoadInt32(&calls); n != tt.wantCalls {
+				t.Errorf("got %d attempts, want %d", n, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestContextWithRetryPolicyAttempts(t *testing.T) {
+	var calls int32
+	cp := http.NewClientPool(http.WithRetry(2, time.Millisecond))
+	cp.SetTransport(failingTransport(10, nethttp.StatusBadGateway, &calls))
+
+	ctx := http.ContextWithRetryPolicy(context.Background(), http.RetryPolicy{MaxAttempts: 4})
+	req, _ := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, "http://example.com/", nil)
+	resp, err := cp.GetClient(0).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if n := atomic.LoadInt32(&calls); n != 4 {
+		t.Errorf("got %d attempts, want 4", n)
+	}
+}
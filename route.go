@@ -0,0 +1,75 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+)
+
+// SetRouteClassifier sets the function deriving the route label reported
+// to the observers implementing RouteObserver from every request. The
+// route should have a low cardinality, such as "/users/{id}" rather than
+// the raw path, so it can be used as a metrics label. If nil, routes are
+// not reported.
+func (c *ClientPool) SetRouteClassifier(fn func(*http.Request) string) {
+	c.mtx.Lock()
+	{
+		c.routeClassifier = fn
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+	c.mtx.Unlock()
+}
+
+// TemplateRoute is a route classifier deriving the route from the path
+// of the request, replacing the numeric segments with "{id}" and the
+// UUID segments with "{uuid}".
+func TemplateRoute(req *http.Request) string {
+	segments := strings.Split(req.URL.Path, "/")
+	for i, segment := range segments {
+		switch {
+		case isNumeric(segment):
+			segments[i] = "{id}"
+		case isUUID(segment):
+			segments[i] = "{uuid}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isUUID reports whether s is a UUID in its canonical textual form.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !isHex(s[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isHex(b byte) bool {
+	return ('0' <= b && b <= '9') || ('a' <= b && b <= 'f') || ('A' <= b && b <= 'F')
+}
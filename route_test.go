@@ -0,0 +1,74 @@
+package http_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// routeRecorder records the routes of the completed requests.
+type routeRecorder struct {
+	mtx    sync.Mutex
+	routes []string
+}
+
+func (r *routeRecorder) RequestCompleted(statusClass int, err error, d time.Duration) {}
+
+func (r *routeRecorder) RouteCompleted(route string, statusClass int, err error, d time.Duration) {
+	r.mtx.Lock()
+	r.routes = append(r.routes, route)
+	r.mtx.Unlock()
+}
+
+func TestRouteClassifier(t *testing.T) {
+	recorder := &routeRecorder{}
+	cp := http.NewClientPool(http.WithObserver(recorder))
+	cp.SetTransport(okTransport)
+	cp.SetRouteClassifier(http.TemplateRoute)
+	client := cp.GetClient(time.Second)
+
+	urls := []string{
+		"http://example.com/users/42",
+		"http://example.com/users/42/orders/7?page=2",
+		"http://example.com/items/123e4567-e89b-12d3-a456-426614174000",
+		"http://example.com/users/me",
+		"http://example.com/v2/health",
+	}
+	for _, url := range urls {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	want := []string{
+		"/users/{id}",
+		"/users/{id}/orders/{id}",
+		"/items/{uuid}",
+		"/users/me",
+		"/v2/health",
+	}
+	if !reflect.DeepEqual(recorder.routes, want) {
+		t.Fatalf("routes = %q, want %q", recorder.routes, want)
+	}
+}
+
+func TestRouteClassifierUnset(t *testing.T) {
+	recorder := &routeRecorder{}
+	cp := http.NewClientPool(http.WithObserver(recorder))
+	cp.SetTransport(okTransport)
+
+	resp, err := cp.GetClient(time.Second).Get("http://example.com/users/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(recorder.routes) != 0 {
+		t.Fatalf("routes = %q, want none without a classifier", recorder.routes)
+	}
+}
@@ -0,0 +1,40 @@
+package http
+
+import (
+	"net/http"
+)
+
+// WithSentRequestCapture makes the clients of the pool call fn with a
+// snapshot of every request they send, once every middleware of the pool
+// modified it, right before it is passed to the transport: its headers
+// include the ones set for authentication, signing or compression. A
+// request sent several times, for instance when retried, is captured
+// every time. The snapshot has no body, so that capturing the request
+// does not consume it, and must not be modified. The headers added by
+// the transport itself, such as User-Agent, are not included.
+func WithSentRequestCapture(fn func(*http.Request)) Option {
+	return func(c *ClientPool) {
+		c.sentCapture = fn
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+}
+
+// sentCaptureTransport captures the requests sent through the next
+// transport.
+type sentCaptureTransport struct {
+	next    http.RoundTripper
+	capture func(*http.Request)
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *sentCaptureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	snapshot := req.Clone(req.Context())
+	snapshot.Body = nil
+	snapshot.GetBody = nil
+	t.capture(snapshot)
+
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,59 @@
+package http_test
+
+import (
+	"io"
+	nethttp "net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/Updater/http"
+)
+
+func TestSentRequestCapture(t *testing.T) {
+	var captured []*nethttp.Request
+	cp := http.NewClientPool(
+		http.WithSentRequestCapture(func(req *nethttp.Request) { captured = append(captured, req) }),
+		http.WithOAuth2(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "secret", TokenType: "Bearer"})),
+		http.WithIdempotencyKey("Idempotency-Key", func() string { return "key-1" }),
+		http.WithPooledGzip(),
+	)
+
+	var sentBody string
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		sentBody = string(body)
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+
+	resp, err := cp.GetClient(time.Second).Post("http://example.com/items", "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(captured) != 1 {
+		t.Fatalf("got %d captured requests, want 1", len(captured))
+	}
+	req := captured[0]
+	for key, want := range map[string]string{
+		"Authorization":   "Bearer secret",
+		"Idempotency-Key": "key-1",
+		"Accept-Encoding": "gzip",
+		"Content-Type":    "text/plain",
+	} {
+		if got := req.Header.Get(key); got != want {
+			t.Errorf("got %s header %q, want %q", key, got, want)
+		}
+	}
+	if req.Method != nethttp.MethodPost || req.URL.String() != "http://example.com/items" || req.Body != nil {
+		t.Errorf("got captured request %s %s with body %v", req.Method, req.URL, req.Body)
+	}
+
+	// Capturing the request did not consume its body.
+	if sentBody != "payload" {
+		t.Errorf("got body %q sent, want %q", sentBody, "payload")
+	}
+}
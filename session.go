@@ -0,0 +1,44 @@
+package http
+
+import (
+	"crypto/tls"
+)
+
+// WithSharedSessionCache makes the default transport of the pool store
+// the TLS sessions it establishes in the specified cache, and resume
+// them when reconnecting to the same servers. Sharing a cache between
+// pools, for instance between a pool and its clones, lets each of them
+// resume the sessions established by the others, saving full handshakes.
+// The cache is used concurrently by every connection of the pools
+// sharing it, so it must be safe for concurrent use, as the caches
+// returned by tls.NewLRUClientSessionCache are. The cache overrides the
+// one of the configuration set with SetDefaultTLSConfig, if any.
+func WithSharedSessionCache(cache tls.ClientSessionCache) Option {
+	return func(c *ClientPool) {
+		c.sessionCache = cache
+		c.resetClients()
+	}
+}
+
+// defaultTLSConfig returns the TLS Configuration of the default
+// transport. Must be called while holding the lock.
+func (c *ClientPool) defaultTLSConfig() *tls.Config {
+	if c.sessionCache == nil && len(c.pins) == 0 && c.certSelector == nil {
+		return c.tlsConfig
+	}
+
+	config := c.tlsConfig.Clone()
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if c.sessionCache != nil {
+		config.ClientSessionCache = c.sessionCache
+	}
+	if c.certSelector != nil {
+		config.GetClientCertificate = c.certSelector
+	}
+	if len(c.pins) > 0 {
+		config.VerifyConnection = verifyPins(c.pins, config.VerifyConnection)
+	}
+	return config
+}
@@ -0,0 +1,64 @@
+package http_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// fetchTLS sends a GET request and returns the TLS state of the
+// connection it was sent over.
+func fetchTLS(t *testing.T, client *nethttp.Client, url string) *tls.ConnectionState {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.TLS == nil {
+		t.Fatal("expected a TLS connection")
+	}
+	return resp.TLS
+}
+
+func TestSharedSessionCacheClone(t *testing.T) {
+	server := httptest.NewTLSServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	parent := http.NewClientPool(http.WithSharedSessionCache(tls.NewLRUClientSessionCache(16)))
+	parent.SetDefaultTLSConfig(&tls.Config{RootCAs: roots})
+
+	if state := fetchTLS(t, parent.GetClient(time.Second), server.URL); state.DidResume {
+		t.Fatal("first connection resumed a session")
+	}
+
+	clone := parent.Clone()
+	if state := fetchTLS(t, clone.GetClient(time.Second), server.URL); !state.DidResume {
+		t.Fatal("clone did not resume the session established by the parent")
+	}
+}
+
+func TestWithoutSharedSessionCache(t *testing.T) {
+	server := httptest.NewTLSServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	parent := http.NewClientPool()
+	parent.SetDefaultTLSConfig(&tls.Config{RootCAs: roots})
+	fetchTLS(t, parent.GetClient(time.Second), server.URL)
+
+	if state := fetchTLS(t, parent.Clone().GetClient(time.Second), server.URL); state.DidResume {
+		t.Fatal("clone resumed a session without a shared cache")
+	}
+}
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// HeaderTooLargeError is returned for the responses whose header block
+// exceeds the limit set with WithTotalSizeLimit.
+type HeaderTooLargeError struct {
+	Limit int64
+	Size  int64
+}
+
+func (e *HeaderTooLargeError) Error() string {
+	return fmt.Sprintf("http: response header of %d bytes exceeds the limit of %d bytes", e.Size, e.Limit)
+}
+
+// BodyTooLargeError is returned for the responses whose body exceeds the
+// limit set with WithTotalSizeLimit, either when the response is
+// received if its Content-Length exceeds the limit, or when reading the
+// body past the limit.
+type BodyTooLargeError struct {
+	Limit int64
+}
+
+func (e *BodyTooLargeError) Error() string {
+	return fmt.Sprintf("http: response body exceeds the limit of %d bytes", e.Limit)
+}
+
+// WithTotalSizeLimit caps the size of the responses received by the
+// clients of the pool. Responses whose status line and header block,
+// measured as sent on the wire by HTTP/1, exceed maxHeaderBytes fail with
+// a *HeaderTooLargeError. Reading more than maxBodyBytes from a response
+// body fails with a *BodyTooLargeError. A non-positive limit disables
+// the corresponding check.
+//
+// The header block is measured once parsed by the transport, which caps
+// its size with its own MaxResponseHeaderBytes setting.
+func WithTotalSizeLimit(maxHeaderBytes, maxBodyBytes int64) Option {
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			return &sizeLimitTransport{
+				next:           next,
+				maxHeaderBytes: maxHeaderBytes,
+				maxBodyBytes:   maxBodyBytes,
+			}
+		})
+	}
+}
+
+// sizeLimitTransport limits the size of the responses of the next
+// transport.
+type sizeLimitTransport struct {
+	next           http.RoundTripper
+	maxHeaderBytes int64
+	maxBodyBytes   int64
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *sizeLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.maxHeaderBytes > 0 {
+		if size := headerSize(resp); size > t.maxHeaderBytes {
+			resp.Body.Close()
+			return nil, &HeaderTooLargeError{Limit: t.maxHeaderBytes, Size: size}
+		}
+	}
+
+	if t.maxBodyBytes > 0 {
+		if resp.ContentLength > t.maxBodyBytes {
+			resp.Body.Close()
+			return nil, &BodyTooLargeError{Limit: t.maxBodyBytes}
+		}
+		resp.Body = &limitedBody{
+			ReadCloser: resp.Body,
+			remaining:  t.maxBodyBytes,
+			limit:      t.maxBodyBytes,
+		}
+	}
+	return resp, nil
+}
+
+// headerSize returns the size of the status line and header block of a
+// response as written by HTTP/1.
+func headerSize(resp *http.Response) int64 {
+	// The status line, such as "HTTP/1.1 200 OK\r\n".
+	size := int64(len(resp.Proto) + len(resp.Status) + 3)
+	for key, values := range resp.Header {
+		for _, value := range values {
+			// "Key: value\r\n"
+			size += int64(len(key) + len(value) + 4)
+		}
+	}
+	// The empty line ending the block.
+	return size + 2
+}
+
+// limitedBody fails the reads past the limit of a response body.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	limit     int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, &BodyTooLargeError{Limit: b.limit}
+	}
+
+	// Reading one byte past the limit tells whether the body exceeds
+	// it.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = -1
+		return n, &BodyTooLargeError{Limit: b.limit}
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,75 @@
+package http_test
+
+import (
+	"errors"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestTotalSizeLimitHeader(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/large" {
+			w.Header().Set("X-Padding", strings.Repeat("x", 1024))
+		}
+	}))
+	defer server.Close()
+
+	cp := http.NewClientPool(http.WithTotalSizeLimit(512, 0))
+	client := cp.GetClient(time.Second)
+
+	resp, err := client.Get(server.URL + "/small")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	_, err = client.Get(server.URL + "/large")
+	var headerErr *http.HeaderTooLargeError
+	if !errors.As(err, &headerErr) {
+		t.Fatalf("error = %v, want a *HeaderTooLargeError", err)
+	}
+	if headerErr.Limit != 512 || headerErr.Size <= 1024 {
+		t.Fatalf("got limit %d and size %d, want 512 and more than 1024", headerErr.Limit, headerErr.Size)
+	}
+}
+
+func TestTotalSizeLimitBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		unknown bool
+		fail    bool
+	}{
+		{"within the limit", 100, false, false},
+		{"content length too large", 101, false, true},
+		{"unknown length within the limit", 100, true, false},
+		{"unknown length too large", 101, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp := http.NewClientPool(http.WithTotalSizeLimit(0, 100))
+			cp.SetTransport(sizedTransport(tt.size, tt.unknown))
+
+			resp, err := cp.GetClient(time.Second).Get("http://example.com/")
+			if err == nil {
+				defer resp.Body.Close()
+				var body []byte
+				body, err = io.ReadAll(resp.Body)
+				if err == nil && len(body) != tt.size {
+					t.Fatalf("read %d bytes, want %d", len(body), tt.size)
+				}
+			}
+
+			var bodyErr *http.BodyTooLargeError
+			if got := errors.As(err, &bodyErr); got != tt.fail {
+				t.Fatalf("error = %v, want a *BodyTooLargeError: %t", err, tt.fail)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// gzipMagic is the header starting every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// WithSniffGzip makes the clients of the pool decompress the response
+// bodies starting with the gzip magic number even if their response
+// lacks a Content-Encoding header, for servers compressing their
+// responses without saying so. The other bodies are returned as is.
+// Responses with a Content-Encoding header, and those already
+// decompressed by the transport, are never sniffed.
+//
+// The first two bytes of the body are read before the response is
+// returned, which delays the responses of the servers sending their
+// headers long before their body.
+func WithSniffGzip() Option {
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			return &sniffGzipTransport{next: next}
+		})
+	}
+}
+
+// sniffGzipTransport decompresses the unlabeled gzip responses of the
+// next transport.
+type sniffGzipTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *sniffGzipTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Method == http.MethodHead || resp.ContentLength == 0 || resp.Uncompressed ||
+		resp.StatusCode == http.StatusPartialContent || resp.Header.Get("Content-Encoding") != "" {
+		return resp, nil
+	}
+
+	prefix := make([]byte, len(gzipMagic))
+	n, err := io.ReadFull(resp.Body, prefix)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		resp.Body.Close()
+		return nil, err
+	}
+	body := &multiReadCloser{
+		Reader: io.MultiReader(bytes.NewReader(prefix[:n]), resp.Body),
+		Closer: resp.Body,
+	}
+	if !bytes.Equal(prefix[:n], gzipMagic) {
+		resp.Body = body
+		return resp, nil
+	}
+
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	resp.Body = &gzipBody{body: body}
+	return resp, nil
+}
@@ -0,0 +1,53 @@
+package http_test
+
+import (
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestSniffGzip(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		switch r.URL.Path {
+		case "/labeled":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipped("labeled payload"))
+		case "/unlabeled":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(gzipped("unlabeled payload"))
+		case "/plain":
+			io.WriteString(w, "plain payload")
+		case "/prefix":
+			w.Write([]byte{0x1f, 0x00, 0x8b})
+		case "/short":
+			w.Write([]byte{0x1f})
+		}
+	}))
+	defer server.Close()
+
+	cp := http.NewClientPool(http.WithSniffGzip())
+	client := cp.GetClient(time.Second)
+	for _, test := range []struct {
+		path string
+		want string
+	}{
+		{"/labeled", "labeled payload"},
+		{"/unlabeled", "unlabeled payload"},
+		{"/plain", "plain payload"},
+		{"/prefix", "\x1f\x00\x8b"},
+		{"/short", "\x1f"},
+		{"/empty", ""},
+	} {
+		got, err := getString(client, server.URL+test.path)
+		if err != nil {
+			t.Fatalf("%s: %v", test.path, err)
+		}
+		if got != test.want {
+			t.Errorf("%s: body = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"golang.org/x/net/proxy"
+)
+
+// socks5Proxy is the SOCKS5 proxy the connections of the default
+// transport are tunneled through.
+type socks5Proxy struct {
+	addr string
+	auth *proxy.Auth
+}
+
+// SetSOCKS5Proxy makes the default transport tunnel all its connections
+// through the SOCKS5 proxy listening at addr, authenticating with the
+// specified credentials if not nil. The proxy resolves the hosts of the
+// requests. An HTTP proxy selected with SetProxy or from the environment,
+// if any, is reached through the SOCKS5 proxy. An empty address removes
+// the SOCKS5 proxy. Transports set with SetTransport are not affected.
+func (c *ClientPool) SetSOCKS5Proxy(addr string, auth *proxy.Auth) error {
+	var socks5 *socks5Proxy
+	if addr != "" {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("http: invalid SOCKS5 proxy address: %w", err)
+		}
+		socks5 = &socks5Proxy{addr: addr, auth: auth}
+	}
+
+	c.mtx.Lock()
+	{
+		c.socks5 = socks5
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+	c.mtx.Unlock()
+	return nil
+}
+
+// socks5Dial returns the dial function tunneling the connections through
+// the SOCKS5 proxy, which is reached with the specified function, or the
+// function as is if no SOCKS5 proxy is set. Must be called while holding
+// the lock.
+func (c *ClientPool) socks5Dial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if c.socks5 == nil {
+		return dial
+	}
+
+	dialer, err := proxy.SOCKS5("tcp", c.socks5.addr, c.socks5.auth, contextDialer(dial))
+	if err != nil {
+		return func(context.Context, string, string) (net.Conn, error) {
+			return nil, err
+		}
+	}
+
+	// The dialers of the SOCKS5 package support contexts.
+	return dialer.(proxy.ContextDialer).DialContext
+}
+
+// contextDialer is a dial function used as a proxy.ContextDialer.
+type contextDialer func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (d contextDialer) Dial(network, addr string) (net.Conn, error) {
+	return d(context.Background(), network, addr)
+}
+
+func (d contextDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return d(ctx, network, addr)
+}
@@ -0,0 +1,203 @@
+package http_test
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/proxy"
+
+	"github.com/Updater/http"
+)
+
+// socks5Server is a minimal SOCKS5 server supporting the CONNECT command
+// to IPv4 addresses and domain names. If user is not empty, the clients
+// must authenticate with the user and pass credentials.
+type socks5Server struct {
+	ln         net.Listener
+	user, pass string
+
+	mtx      sync.Mutex
+	connects []string
+}
+
+func newSOCKS5Server(t *testing.T, user, pass string) *socks5Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &socks5Server{ln: ln, user: user, pass: pass}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *socks5Server) addr() string {
+	return s.ln.Addr().String()
+}
+
+// destinations returns the addresses the clients connected to.
+func (s *socks5Server) destinations() []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return append([]string(nil), s.connects...)
+}
+
+func (s *socks5Server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *socks5Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	// Greeting: version, methods.
+	buf := make([]byte, 262)
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		return
+	}
+	if s.user == "" {
+		conn.Write([]byte{5, 0})
+	} else {
+		conn.Write([]byte{5, 2})
+		if !s.authenticate(conn) {
+			return
+		}
+	}
+
+	// Request: version, command, reserved, address type.
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil || buf[1] != 1 {
+		return
+	}
+	var host string
+	switch buf[3] {
+	case 1:
+		if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+			return
+		}
+		host = net.IP(buf[:4]).String()
+	case 3:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return
+		}
+		n := int(buf[0])
+		if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+			return
+		}
+		host = string(buf[:n])
+	default:
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(buf[:2]))))
+
+	s.mtx.Lock()
+	s.connects = append(s.connects, addr)
+	s.mtx.Unlock()
+
+	upstream, err := net.Dial("tcp", addr)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer upstream.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go io.Copy(upstream, conn)
+	io.Copy(conn, upstream)
+}
+
+// authenticate performs the username/password authentication.
+func (s *socks5Server) authenticate(conn net.Conn) bool {
+	// Version, then length-prefixed user and password.
+	buf := make([]byte, 255)
+	if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+		return false
+	}
+	var fields [2]string
+	for i := range fields {
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return false
+		}
+		n := int(buf[0])
+		if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+			return false
+		}
+		fields[i] = string(buf[:n])
+	}
+	user, pass := fields[0], fields[1]
+
+	if user != s.user || pass != s.pass {
+		conn.Write([]byte{1, 1})
+		return false
+	}
+	conn.Write([]byte{1, 0})
+	return true
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+	socks := newSOCKS5Server(t, "", "")
+
+	cp := http.NewClientPool()
+	cp.SetProxy(func(*nethttp.Request) (*url.URL, error) { return nil, nil })
+	if err := cp.SetSOCKS5Proxy(socks.addr(), nil); err != nil {
+		t.Fatal(err)
+	}
+	getDiscard(t, cp.GetClient(time.Second), server.URL)
+
+	if got := socks.destinations(); len(got) != 1 || got[0] != server.Listener.Addr().String() {
+		t.Errorf("got proxied connections %v, want one to %s", got, server.Listener.Addr())
+	}
+
+	// Removing the proxy connects directly.
+	cp.SetSOCKS5Proxy("", nil)
+	getDiscard(t, cp.GetClient(time.Second), server.URL)
+	if got := socks.destinations(); len(got) != 1 {
+		t.Errorf("got %d proxied connections, want 1", len(got))
+	}
+}
+
+func TestSOCKS5ProxyAuth(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	defer server.Close()
+	socks := newSOCKS5Server(t, "user", "secret")
+
+	cp := http.NewClientPool()
+	cp.SetProxy(func(*nethttp.Request) (*url.URL, error) { return nil, nil })
+
+	cp.SetSOCKS5Proxy(socks.addr(), &proxy.Auth{User: "user", Password: "wrong"})
+	if _, err := cp.GetClient(time.Second).Get(server.URL); err == nil {
+		t.Error("expected an error with invalid credentials")
+	}
+
+	cp.SetSOCKS5Proxy(socks.addr(), &proxy.Auth{User: "user", Password: "secret"})
+	getDiscard(t, cp.GetClient(time.Second), server.URL)
+}
+
+func TestSOCKS5ProxyInvalidAddress(t *testing.T) {
+	if err := http.NewClientPool().SetSOCKS5Proxy("localhost", nil); err == nil {
+		t.Error("expected an error")
+	}
+}
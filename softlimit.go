@@ -0,0 +1,137 @@
+package http
+
+import (
+	"net/http"
+	"sync"
+)
+
+// WithSoftConnLimit makes the clients of the pool keep at most n
+// connections per host open for reuse. Unlike the MaxConnsPerHost
+// setting of http.Transport, requests beyond the limit are not queued:
+// while n requests to a host are in flight, the others are sent over
+// new connections that are closed once their response body is closed.
+// A request is in flight until its response body is closed.
+//
+// Only transports of type *http.Transport are limited; other
+// transports are used as is.
+func WithSoftConnLimit(n int) Option {
+	return func(c *ClientPool) {
+		c.softLimit = &softConnLimit{
+			conns:      n,
+			transports: make(map[*http.Transport]*softLimitTransport),
+		}
+		c.resetClients()
+	}
+}
+
+// softConnLimit holds the limited copies of the base transports.
+type softConnLimit struct {
+	conns int
+
+	mtx        sync.Mutex
+	transports map[*http.Transport]*softLimitTransport
+}
+
+// transport returns the limited copy of the base transport.
+func (l *softConnLimit) transport(base *http.Transport) *softLimitTransport {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	t := l.transports[base]
+	if t == nil {
+		pooled := base.Clone()
+		pooled.MaxConnsPerHost = l.conns
+		pooled.MaxIdleConnsPerHost = l.conns
+
+		overflow := base.Clone()
+		overflow.DisableKeepAlives = true
+
+		t = &softLimitTransport{
+			conns:    l.conns,
+			pooled:   pooled,
+			overflow: overflow,
+			inFlight: make(map[string]int),
+		}
+		l.transports[base] = t
+	}
+	return t
+}
+
+// reset discards the limited transports, closing their idle
+// connections.
+func (l *softConnLimit) reset() {
+	l.mtx.Lock()
+	{
+		for base, t := range l.transports {
+			t.pooled.CloseIdleConnections()
+			delete(l.transports, base)
+		}
+	}
+	l.mtx.Unlock()
+}
+
+// closeIdle closes the idle connections of the limited copy of the base
+// transport, if any.
+func (l *softConnLimit) closeIdle(base *http.Transport) {
+	l.mtx.Lock()
+	{
+		if t := l.transports[base]; t != nil {
+			t.pooled.CloseIdleConnections()
+		}
+	}
+	l.mtx.Unlock()
+}
+
+// softLimitTransport sends the requests over the pooled connections
+// while they are available, over new connections otherwise.
+type softLimitTransport struct {
+	conns    int
+	pooled   *http.Transport
+	overflow *http.Transport
+
+	mtx      sync.Mutex
+	inFlight map[string]int
+}
+
+// acquire reports whether a request to the host can use the pooled
+// connections, counting it in flight if so.
+func (t *softLimitTransport) acquire(host string) bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.inFlight[host] >= t.conns {
+		return false
+	}
+	t.inFlight[host]++
+	return true
+}
+
+func (t *softLimitTransport) release(host string) {
+	t.mtx.Lock()
+	{
+		if t.inFlight[host]--; t.inFlight[host] <= 0 {
+			delete(t.inFlight, host)
+		}
+	}
+	t.mtx.Unlock()
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *softLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Scheme + "://" + req.URL.Host
+	if !t.acquire(host) {
+		return t.overflow.RoundTrip(req)
+	}
+
+	resp, err := t.pooled.RoundTrip(req)
+	if err != nil {
+		t.release(host)
+		return nil, err
+	}
+
+	resp.Body = &releaseBody{
+		ReadCloser: resp.Body,
+		release:    func() { t.release(host) },
+	}
+	return resp, nil
+}
@@ -0,0 +1,94 @@
+package http_test
+
+import (
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestSoftConnLimit(t *testing.T) {
+	const burst = 8
+
+	var arrived int32
+	release := make(chan struct{})
+	server := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/burst" && atomic.AddInt32(&arrived, 1) == burst {
+			close(release)
+		}
+		if r.URL.Path == "/burst" {
+			<-release
+		}
+	}))
+
+	var mtx sync.Mutex
+	open := make(map[net.Conn]bool)
+	server.Config.ConnState = func(conn net.Conn, state nethttp.ConnState) {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		switch state {
+		case nethttp.StateNew:
+			open[conn] = true
+		case nethttp.StateClosed, nethttp.StateHijacked:
+			delete(open, conn)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	cp := http.NewClientPool(http.WithSoftConnLimit(2))
+	cp.SetTransport(&nethttp.Transport{})
+	client := cp.GetClient(5 * time.Second)
+
+	// Every request of the burst must be in flight at the same time for
+	// the handler to answer, so none of them can be queued.
+	var wg sync.WaitGroup
+	errs := make(chan error, burst)
+	for i := 0; i < burst; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL + "/burst")
+			if err != nil {
+				errs <- err
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	// The steady state reuses the pooled connections only.
+	for i := 0; i < 10; i++ {
+		resp, err := client.Get(server.URL + "/steady")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mtx.Lock()
+		n := len(open)
+		mtx.Unlock()
+
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d open connections after the burst, want 2", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
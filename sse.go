@@ -0,0 +1,255 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultSSERetry is the delay before reconnecting to an event
+	// stream when the server did not specify one.
+	defaultSSERetry = 3 * time.Second
+
+	// maxSSERetry caps the delay between reconnections to an event
+	// stream that keeps failing.
+	maxSSERetry = time.Minute
+)
+
+// errSSEDone is returned when the server asks to stop reconnecting.
+var errSSEDone = errors.New("http: event stream done")
+
+// Event is an event received from a Server-Sent Events stream.
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// StreamSSE opens a Server-Sent Events stream with a GET request to the
+// specified URL and calls handler for every event received. When the
+// connection drops, it reconnects after the delay requested by the
+// server, doubling it while reconnections fail, and sends the ID of the
+// last event received in the Last-Event-ID header so the server can
+// resume the stream.
+//
+// StreamSSE returns when the context is done, when the handler returns
+// an error, which is returned as is, when the server answers with a 204
+// status code, when it answers with a status code that is not worth
+// retrying, or when the stream cannot be opened for a reason that
+// reconnecting does not fix, such as a URL of an unsupported scheme, a
+// certificate that cannot be verified or a redirect refused by the
+// redirect policy of the pool. The stream is read with a client sharing
+// the transport and the redirect policy of the pool but without any
+// overall timeout.
+func (c *ClientPool) StreamSSE(ctx context.Context, url string, handler func(Event) error) error {
+	c.mtx.RLock()
+	checkRedirect := c.checkRedirect()
+	c.mtx.RUnlock()
+
+	client := &http.Client{
+		Transport:     c.GetClient(0).Transport,
+		CheckRedirect: sseCheckRedirect(checkRedirect),
+	}
+
+	stream := sseStream{
+		send: func(req *http.Request) (*http.Response, error) {
+			return c.send(client, req)
+		},
+		url:     url,
+		handler: handler,
+		retry:   defaultSSERetry,
+	}
+
+	failures := 0
+	for {
+		received, err := stream.connect(ctx)
+		if err == errSSEDone {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if received {
+			failures = 0
+		} else if failures < 16 {
+			failures++
+		}
+
+		delay := stream.retry
+		for i := 1; i < failures && delay < maxSSERetry; i++ {
+			delay *= 2
+		}
+		if delay > maxSSERetry {
+			delay = maxSSERetry
+		}
+
+		if err := sleep(ctx, systemClock, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// sseRedirectError is an error of the redirect policy of an event
+// stream.
+type sseRedirectError struct {
+	err error
+}
+
+func (e *sseRedirectError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error of the redirect policy.
+func (e *sseRedirectError) Unwrap() error {
+	return e.err
+}
+
+// sseCheckRedirect returns the redirect policy of the event streams,
+// applying the policy of the pool, or the default policy of the core
+// http package if nil, and marking its errors as sseRedirectError.
+func sseCheckRedirect(policy func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		var err error
+		if policy != nil {
+			err = policy(req, via)
+		} else if len(via) >= maxRedirects {
+			err = errors.New("stopped after 10 redirects")
+		}
+
+		if err != nil && err != http.ErrUseLastResponse {
+			return &sseRedirectError{err: err}
+		}
+		return err
+	}
+}
+
+// isPermanentSSEError reports whether the error of an event stream
+// connection is not fixed by reconnecting.
+func isPermanentSSEError(err error) bool {
+	var redirectErr *sseRedirectError
+	return errors.As(err, &redirectErr) || errors.Is(err, ErrPublicKeyPinMismatch) || isCertificateError(err)
+}
+
+// sseStream holds the state of an event stream across reconnections.
+type sseStream struct {
+	send    func(*http.Request) (*http.Response, error)
+	url     string
+	handler func(Event) error
+	lastID  string
+	retry   time.Duration
+}
+
+// connect opens a connection to the stream and dispatches its events
+// until it drops. It reports whether any event was received, and
+// returns an error if the stream must not be reconnected.
+func (s *sseStream) connect(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return false, err
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return false, fmt.Errorf("http: unsupported event stream scheme %q", req.URL.Scheme)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if s.lastID != "" {
+		req.Header.Set("Last-Event-ID", s.lastID)
+	}
+
+	resp, err := s.send(req)
+	if err != nil {
+		if isPermanentSSEError(err) {
+			return false, err
+		}
+		return false, ctx.Err()
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return false, errSSEDone
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return false, nil
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("http: unexpected event stream status %s", resp.Status)
+	}
+
+	received, err := s.read(resp.Body)
+	if err != nil {
+		return received, err
+	}
+	return received, ctx.Err()
+}
+
+// read parses the events of a stream and dispatches them to the handler.
+func (s *sseStream) read(body io.Reader) (bool, error) {
+	var (
+		received bool
+		event    Event
+		data     strings.Builder
+		hasData  bool
+
+		// The ID is only committed when an event is dispatched.
+		id = s.lastID
+	)
+
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// Incomplete events are discarded when the stream drops.
+			return received, nil
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			// A blank line dispatches the event.
+			s.lastID = id
+			if hasData {
+				event.ID = id
+				event.Data = data.String()
+				received = true
+				if err := s.handler(event); err != nil {
+					return received, err
+				}
+			}
+			event, hasData = Event{}, false
+			data.Reset()
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch field {
+		case "":
+			// Comment line.
+		case "event":
+			event.Event = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				id = value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				s.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
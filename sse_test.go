@@ -0,0 +1,160 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestStreamSSEReconnect(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		lastIDs  []string
+		connects int
+	)
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		mtx.Lock()
+		connects++
+		n := connects
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		mtx.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		if n == 1 {
+			// Send two events and drop the connection.
+			fmt.Fprint(w, "retry: 10\n\n")
+			fmt.Fprint(w, ": comment\nid: 1\nevent: greeting\ndata: hello\n\n")
+			fmt.Fprint(w, "id: 2\ndata: multi\ndata: line\n\n")
+			fmt.Fprint(w, "id: 3\ndata: incomplete")
+			return
+		}
+		fmt.Fprint(w, "id: 3\ndata: resumed\n\n")
+	}))
+	defer server.Close()
+
+	errStop := errors.New("stop")
+	var events []http.Event
+
+	cp := http.NewClientPool()
+	err := cp.StreamSSE(context.Background(), server.URL, func(e http.Event) error {
+		events = append(events, e)
+		if len(events) == 3 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("error = %v, want %v", err, errStop)
+	}
+
+	want := []http.Event{
+		{ID: "1", Event: "greeting", Data: "hello"},
+		{ID: "2", Data: "multi\nline"},
+		{ID: "3", Data: "resumed"},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %+v, want %+v", events, want)
+	}
+	if !reflect.DeepEqual(lastIDs, []string{"", "2"}) {
+		t.Fatalf("Last-Event-ID headers = %q, want [\"\" \"2\"]", lastIDs)
+	}
+}
+
+func TestStreamSSECancel(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: first\n\n")
+		w.(nethttp.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- http.NewClientPool().StreamSSE(ctx, server.URL, func(http.Event) error {
+			cancel()
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not stop on cancellation")
+	}
+}
+
+func TestStreamSSENoContent(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.WriteHeader(nethttp.StatusNoContent)
+	}))
+	defer server.Close()
+
+	err := http.NewClientPool().StreamSSE(context.Background(), server.URL, func(http.Event) error {
+		t.Error("unexpected event")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error = %v, want nil", err)
+	}
+}
+
+func TestStreamSSEPermanentErrors(t *testing.T) {
+	untrusted := httptest.NewTLSServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	defer untrusted.Close()
+	var redirects int32
+	redirecting := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		atomic.AddInt32(&redirects, 1)
+		nethttp.Redirect(w, r, "/again", nethttp.StatusFound)
+	}))
+	defer redirecting.Close()
+
+	limited := http.NewClientPool()
+	limited.SetMaxRedirectsPerHost(2)
+
+	tests := []struct {
+		name string
+		pool *http.ClientPool
+		url  string
+		want error
+	}{
+		{name: "scheme", pool: http.NewClientPool(), url: "ftp://example.com/events"},
+		{name: "certificate", pool: http.NewClientPool(), url: untrusted.URL},
+		{name: "redirect policy", pool: limited, url: redirecting.URL, want: http.ErrTooManyRedirectsToHost},
+		{name: "redirects", pool: http.NewClientPool(), url: redirecting.URL},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := tt.pool.StreamSSE(ctx, tt.url, func(http.Event) error {
+			t.Errorf("%s: unexpected event", tt.name)
+			return nil
+		})
+		cancel()
+
+		if err == nil || errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: error = %v, want the error opening the stream", tt.name, err)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// The streams are opened once, following the redirects up to the
+	// limit of the pool, then of the core http package.
+	if n := atomic.LoadInt32(&redirects); n != 3+10 {
+		t.Errorf("server redirected %d requests, want %d", n, 3+10)
+	}
+}
@@ -0,0 +1,268 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// staleMaxBodyBytes is the size of the largest response body kept
+	// to be served stale.
+	staleMaxBodyBytes = 1 << 20
+
+	// staleMaxEntries is the number of responses kept to be served
+	// stale, beyond which arbitrary entries are evicted.
+	staleMaxEntries = 1024
+)
+
+// staleWarning is the Warning header of the responses served stale.
+const staleWarning = `110 - "Response is Stale"`
+
+// WithStaleOnError makes the clients of the pool serve the last
+// successful response to a GET request when the request fails later
+// on, if the response expired less than maxStale ago, rather than
+// failing. The pool has no response cache: every request is sent
+// upstream, and the response kept for it is replaced by every
+// successful response. A request fails when the upstream cannot be
+// reached or answers with a 5xx status code.
+//
+// The responses served stale carry a Warning header with the code 110.
+// Their expiry is derived from the max-age directive of their
+// Cache-Control header or from their Expires header, and is the time
+// they were received at otherwise. A response is kept once its body has
+// been read entirely. Event streams and responses with the no-store
+// directive or a body over 1MiB are not kept, and the Vary header is
+// ignored.
+func WithStaleOnError(maxStale time.Duration) Option {
+	cache := &staleCache{
+		maxStale: maxStale,
+		entries:  make(map[string]*staleEntry),
+	}
+
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			return &staleTransport{
+				next:  next,
+				cache: cache,
+				clock: c.timeSource(),
+			}
+		})
+	}
+}
+
+// staleEntry is a response kept to be served stale.
+type staleEntry struct {
+	resp    *http.Response
+	body    []byte
+	expires time.Time
+}
+
+// staleCache holds the last successful responses by URL.
+type staleCache struct {
+	maxStale time.Duration
+
+	mtx     sync.Mutex
+	entries map[string]*staleEntry
+}
+
+// store keeps the response received for the URL, replacing the previous
+// one.
+func (s *staleCache) store(url string, entry *staleEntry) {
+	s.mtx.Lock()
+	{
+		if _, ok := s.entries[url]; !ok && len(s.entries) >= staleMaxEntries {
+			for k := range s.entries {
+				delete(s.entries, k)
+				break
+			}
+		}
+		s.entries[url] = entry
+	}
+	s.mtx.Unlock()
+}
+
+// remove discards the response kept for the URL.
+func (s *staleCache) remove(url string) {
+	s.mtx.Lock()
+	delete(s.entries, url)
+	s.mtx.Unlock()
+}
+
+// lookup returns the response kept for the URL if it can still be
+// served at the specified time, or nil.
+func (s *staleCache) lookup(url string, now time.Time) *staleEntry {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	entry := s.entries[url]
+	if entry == nil {
+		return nil
+	}
+	if now.Sub(entry.expires) > s.maxStale {
+		delete(s.entries, url)
+		return nil
+	}
+	return entry
+}
+
+// staleTransport serves the last successful responses of the next
+// transport when their requests fail.
+type staleTransport struct {
+	next  http.RoundTripper
+	cache *staleCache
+	clock clock
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *staleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "" && req.Method != http.MethodGet {
+		return t.next.RoundTrip(req)
+	}
+
+	url := req.URL.String()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		if entry := t.cache.lookup(url, t.clock.Now()); entry != nil {
+			if resp != nil {
+				discardBody(resp)
+			}
+			return entry.response(req), nil
+		}
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		return t.keep(url, resp)
+	}
+	return resp, nil
+}
+
+// keep keeps the successful response to be served stale, if possible,
+// as its body is read. Event streams are never kept.
+func (t *staleTransport) keep(url string, resp *http.Response) (*http.Response, error) {
+	if hasDirective(resp.Header, "no-store") || resp.ContentLength > staleMaxBodyBytes || isEventStream(resp.Header) {
+		t.cache.remove(url)
+		return resp, nil
+	}
+
+	kept := *resp
+	kept.Header = resp.Header.Clone()
+	resp.Body = &staleBody{
+		ReadCloser: resp.Body,
+		cache:      t.cache,
+		url:        url,
+		entry: &staleEntry{
+			resp:    &kept,
+			expires: expiry(resp.Header, t.clock.Now()),
+		},
+	}
+	return resp, nil
+}
+
+// isEventStream reports whether the header is the one of an event
+// stream.
+func isEventStream(header http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+// staleBody copies the body of a response as it is read, and keeps the
+// response to be served stale once the whole body was read.
+type staleBody struct {
+	io.ReadCloser
+
+	cache *staleCache
+	url   string
+	entry *staleEntry
+	buf   bytes.Buffer
+
+	// done is set once the response was kept or could not be.
+	done bool
+}
+
+// Read implements the io.Reader interface.
+func (b *staleBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.done {
+		return n, err
+	}
+
+	if b.buf.Len()+n > staleMaxBodyBytes {
+		b.done = true
+		b.buf = bytes.Buffer{}
+		b.cache.remove(b.url)
+		return n, err
+	}
+	b.buf.Write(p[:n])
+
+	if err == io.EOF {
+		b.done = true
+		b.entry.body = b.buf.Bytes()
+		b.cache.store(b.url, b.entry)
+	}
+	return n, err
+}
+
+// response returns a copy of the kept response, for the request, with
+// the Warning header of the stale responses.
+func (e *staleEntry) response(req *http.Request) *http.Response {
+	resp := *e.resp
+	resp.Header = e.resp.Header.Clone()
+	resp.Header.Add("Warning", staleWarning)
+	resp.Body = io.NopCloser(bytes.NewReader(e.body))
+	resp.ContentLength = int64(len(e.body))
+	resp.Request = req
+	return &resp
+}
+
+// multiReadCloser reads from the reader and closes the closer.
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// hasDirective reports whether the Cache-Control header carries the
+// directive.
+func hasDirective(header http.Header, directive string) bool {
+	_, ok := cacheDirective(header, directive)
+	return ok
+}
+
+// cacheDirective returns the value of the directive of the Cache-Control
+// header, and whether the directive is present.
+func cacheDirective(header http.Header, directive string) (string, bool) {
+	for _, field := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value := strings.TrimSpace(field), ""
+		if i := strings.IndexByte(name, '='); i >= 0 {
+			name, value = name[:i], strings.Trim(name[i+1:], `"`)
+		}
+		if strings.EqualFold(name, directive) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// expiry returns the time the response received at the specified time
+// expires at, based on its headers.
+func expiry(header http.Header, received time.Time) time.Time {
+	if value, ok := cacheDirective(header, "max-age"); ok {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return received.Add(time.Duration(seconds) * time.Second)
+		}
+	}
+
+	if expires, err := http.ParseTime(header.Get("Expires")); err == nil {
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			return received.Add(expires.Sub(date))
+		}
+		return expires
+	}
+	return received
+}
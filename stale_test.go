@@ -0,0 +1,172 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// errUpstreamDown is returned by the transport of the tests when the
+// upstream is unreachable.
+var errUpstreamDown = errors.New("upstream down")
+
+// upstream is a transport answering with a 200 status code and a body
+// counting the responses, failing with a 503 status code or failing with
+// errUpstreamDown depending on its mode.
+type upstream struct {
+	mode      int32
+	responses int32
+}
+
+const (
+	upstreamUp = iota
+	upstreamUnavailable
+	upstreamDown
+)
+
+func (u *upstream) RoundTrip(req *nethttp.Request) (*nethttp.Response, error) {
+	switch atomic.LoadInt32(&u.mode) {
+	case upstreamUnavailable:
+		return statusResponse(req, nethttp.StatusServiceUnavailable), nil
+	case upstreamDown:
+		return nil, errUpstreamDown
+	}
+
+	n := atomic.AddInt32(&u.responses, 1)
+	resp := statusResponse(req, nethttp.StatusOK)
+	resp.Header.Set("Cache-Control", "max-age=0")
+	resp.Body = io.NopCloser(strings.NewReader(strings.Repeat("v", int(n))))
+	return resp, nil
+}
+
+// getBody returns the body and Warning header of the response to a GET
+// request to the URL.
+func getBody(client *nethttp.Client, url string) (string, string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	return string(body), resp.Header.Get("Warning"), err
+}
+
+func TestStaleOnError(t *testing.T) {
+	u := &upstream{}
+	pool := http.NewClientPool(http.WithStaleOnError(time.Minute))
+	pool.SetTransport(u)
+	client := pool.GetClient(time.Second)
+
+	for _, step := range []struct {
+		mode    int32
+		body    string
+		warning string
+	}{
+		{upstreamUp, "v", ""},
+		{upstreamUnavailable, "v", `110 - "Response is Stale"`},
+		{upstreamUp, "vv", ""},
+		{upstreamDown, "vv", `110 - "Response is Stale"`},
+	} {
+		atomic.StoreInt32(&u.mode, step.mode)
+		body, warning, err := getBody(client, "http://example.com/items")
+		if err != nil {
+			t.Fatalf("mode %d: %v", step.mode, err)
+		}
+		if body != step.body || warning != step.warning {
+			t.Errorf("mode %d: got %q with warning %q, want %q with %q", step.mode, body, warning, step.body, step.warning)
+		}
+	}
+
+	// Nothing was kept for other URLs.
+	if _, _, err := getBody(client, "http://example.com/other"); !errors.Is(err, errUpstreamDown) {
+		t.Errorf("error = %v, want %v", err, errUpstreamDown)
+	}
+}
+
+func TestStaleOnErrorExpired(t *testing.T) {
+	u := &upstream{}
+	pool := http.NewClientPool(http.WithStaleOnError(20 * time.Millisecond))
+	pool.SetTransport(u)
+	client := pool.GetClient(time.Second)
+
+	if _, _, err := getBody(client, "http://example.com/"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The response is too stale to be served.
+	time.Sleep(50 * time.Millisecond)
+	atomic.StoreInt32(&u.mode, upstreamDown)
+	if _, _, err := getBody(client, "http://example.com/"); !errors.Is(err, errUpstreamDown) {
+		t.Errorf("error = %v, want %v", err, errUpstreamDown)
+	}
+}
+
+func TestStaleOnErrorStreaming(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path == "/events" {
+			w.Header().Set("Content-Type", "text/event-stream")
+		}
+		fmt.Fprint(w, "data: first\n\n")
+		w.(nethttp.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	pool := http.NewClientPool(http.WithStaleOnError(time.Minute))
+
+	// The response is returned before its body is read entirely.
+	resp, err := pool.GetClient(5 * time.Second).Get(server.URL + "/download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := make([]byte, len("data: first\n\n"))
+	if _, err := io.ReadFull(resp.Body, first); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// Event streams are delivered as they arrive.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errStop := errors.New("stop")
+	err = pool.StreamSSE(ctx, server.URL+"/events", func(e http.Event) error {
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("error = %v, want %v", err, errStop)
+	}
+}
+
+func TestStaleOnErrorUnreadBody(t *testing.T) {
+	u := &upstream{}
+	pool := http.NewClientPool(http.WithStaleOnError(time.Minute))
+	pool.SetTransport(u)
+	client := pool.GetClient(time.Second)
+
+	// A response whose body was not read is not kept.
+	resp, err := client.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	atomic.StoreInt32(&u.mode, upstreamDown)
+	if _, _, err := getBody(client, "http://example.com/"); !errors.Is(err, errUpstreamDown) {
+		t.Errorf("error = %v, want %v", err, errUpstreamDown)
+	}
+}
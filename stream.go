@@ -0,0 +1,134 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrReadTimeout is returned when reading a response body took longer
+// than the stream read timeout of the pool.
+var ErrReadTimeout = errors.New("http: timeout reading response body")
+
+// SetStreamReadTimeout sets the maximum time a single read of a response
+// body can take, independently of the overall client timeout. The
+// deadline is reset after every read, so it limits the idle gaps of a
+// streaming response rather than its total duration. A read exceeding
+// it fails with ErrReadTimeout. Zero disables the limit.
+func (c *ClientPool) SetStreamReadTimeout(timeout time.Duration) {
+	c.mtx.Lock()
+	{
+		c.streamReadTimeout = timeout
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+	c.mtx.Unlock()
+}
+
+// streamTimeoutTransport wraps the bodies of the responses received
+// from the next transport to enforce a timeout on every read.
+type streamTimeoutTransport struct {
+	next    http.RoundTripper
+	timeout time.Duration
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *streamTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+
+	var (
+		connMtx sync.Mutex
+		conn    net.Conn
+	)
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			connMtx.Lock()
+			conn = info.Conn
+			connMtx.Unlock()
+		},
+	})
+
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	body := &timeoutBody{
+		body:    resp.Body,
+		timeout: t.timeout,
+		cancel:  cancel,
+	}
+
+	// The body of a HTTP/1 response is read straight from its
+	// connection, so the deadline can be enforced by the connection
+	// itself. HTTP/2 connections are shared by concurrent streams.
+	if resp.ProtoMajor == 1 {
+		connMtx.Lock()
+		body.conn = conn
+		connMtx.Unlock()
+	}
+
+	resp.Body = body
+	return resp, nil
+}
+
+// timeoutBody is a response body failing reads that take longer than a
+// timeout.
+type timeoutBody struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	cancel  context.CancelFunc
+	conn    net.Conn
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	if b.conn != nil {
+		b.conn.SetReadDeadline(time.Now().Add(b.timeout))
+		n, err := b.body.Read(p)
+		if err == io.EOF {
+			// The connection may be reused by another request.
+			b.conn.SetReadDeadline(time.Time{})
+		}
+
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return n, ErrReadTimeout
+		}
+		return n, err
+	}
+
+	// Without access to the connection the read is aborted by
+	// cancelling the request once the timeout expires.
+	var timedOut int32
+	timer := time.AfterFunc(b.timeout, func() {
+		atomic.StoreInt32(&timedOut, 1)
+		b.cancel()
+	})
+
+	n, err := b.body.Read(p)
+	timer.Stop()
+
+	if err != nil && atomic.LoadInt32(&timedOut) == 1 {
+		return n, ErrReadTimeout
+	}
+	return n, err
+}
+
+func (b *timeoutBody) Close() error {
+	if b.conn != nil {
+		b.conn.SetReadDeadline(time.Time{})
+	}
+
+	err := b.body.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,104 @@
+package http_test
+
+import (
+	"errors"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// pausingHandler writes a first chunk, pauses and then writes a second
+// chunk of the response body.
+func pausingHandler(pause time.Duration) nethttp.HandlerFunc {
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, "first")
+		w.(nethttp.Flusher).Flush()
+
+		select {
+		case <-time.After(pause):
+		case <-r.Context().Done():
+			return
+		}
+		io.WriteString(w, "second")
+	}
+}
+
+func TestStreamReadTimeout(t *testing.T) {
+	server := httptest.NewServer(pausingHandler(300 * time.Millisecond))
+	defer server.Close()
+
+	cp := http.NewClientPool()
+	cp.SetStreamReadTimeout(50 * time.Millisecond)
+
+	resp, err := cp.GetClient(5 * time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if !errors.Is(err, http.ErrReadTimeout) {
+		t.Fatalf("error = %v, want ErrReadTimeout", err)
+	}
+	if string(body) != "first" {
+		t.Fatalf("body = %q, want %q", body, "first")
+	}
+}
+
+func TestStreamReadTimeoutHTTP2(t *testing.T) {
+	server := httptest.NewUnstartedServer(pausingHandler(300 * time.Millisecond))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	cp := http.NewClientPool()
+	cp.SetTransport(server.Client().Transport)
+	cp.SetStreamReadTimeout(50 * time.Millisecond)
+
+	resp, err := cp.GetClient(5 * time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("protocol = %s, want HTTP/2", resp.Proto)
+	}
+
+	if _, err := io.ReadAll(resp.Body); !errors.Is(err, http.ErrReadTimeout) {
+		t.Fatalf("error = %v, want ErrReadTimeout", err)
+	}
+}
+
+func TestStreamReadTimeoutResetsPerRead(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		// The total duration exceeds the timeout but every gap
+		// between writes is shorter.
+		for i := 0; i < 5; i++ {
+			io.WriteString(w, "chunk")
+			w.(nethttp.Flusher).Flush()
+			time.Sleep(30 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	cp := http.NewClientPool()
+	cp.SetStreamReadTimeout(100 * time.Millisecond)
+
+	resp, err := cp.GetClient(5 * time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) != 25 {
+		t.Fatalf("read %d bytes, want 25", len(body))
+	}
+}
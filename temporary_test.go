@@ -0,0 +1,57 @@
+package http_test
+
+import (
+	nethttp "net/http"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestWithTemporaryTransport(t *testing.T) {
+	original := &nethttp.Transport{}
+	temporary := &nethttp.Transport{}
+
+	cp := http.NewClientPool()
+	cp.SetTransport(original)
+
+	cp.WithTemporaryTransport(temporary, func() {
+		if cp.GetClient(time.Second).Transport != temporary {
+			t.Error("expected the temporary transport inside the block")
+		}
+
+		// Clients are also swapped for other goroutines.
+		done := make(chan nethttp.RoundTripper)
+		go func() { done <- cp.GetClient(2 * time.Second).Transport }()
+		if <-done != temporary {
+			t.Error("expected the temporary transport in other goroutines")
+		}
+	})
+
+	if cp.GetClient(time.Second).Transport != original {
+		t.Fatal("expected the original transport to be restored")
+	}
+}
+
+func TestWithTemporaryTransportPanic(t *testing.T) {
+	original := &nethttp.Transport{}
+
+	cp := http.NewClientPool()
+	cp.SetTransport(original)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected the panic to propagate")
+			}
+		}()
+		cp.WithTemporaryTransport(&nethttp.Transport{}, func() {
+			cp.GetClient(time.Second)
+			panic("boom")
+		})
+	}()
+
+	if cp.GetClient(time.Second).Transport != original {
+		t.Fatal("expected the original transport to be restored after a panic")
+	}
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"sync"
+)
+
+// tenantKey is the context key for the tenant of a request.
+type tenantKey struct{}
+
+// ContextWithTenant returns a copy of the context carrying the specified
+// tenant, whose client certificate is selected by the function set with
+// SetClientCertSelector.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant carried by the context, if any.
+// The selector set with SetClientCertSelector gets the tenant from the
+// context of the certificate request.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok
+}
+
+// SetClientCertSelector sets the function selecting the client
+// certificate presented by the default transport when a server requests
+// one, overriding the GetClientCertificate function of the TLS
+// Configuration. The context of the certificate request carries the
+// tenant of the request, see ContextWithTenant and TenantFromContext, so
+// that the certificate of the tenant can be presented.
+//
+// Since a connection presents the certificate of a single tenant, the
+// requests of every tenant are sent through their own copy of the
+// transport, with connections of their own, and the requests without a
+// tenant through the transport of the pool. The copies are discarded
+// when the transport settings of the pool change. Only transports of
+// type *http.Transport can be copied; other transports are used as is.
+// A nil function removes the selector.
+func (c *ClientPool) SetClientCertSelector(fn func(*tls.CertificateRequestInfo) (*tls.Certificate, error)) {
+	c.mtx.Lock()
+	{
+		c.certSelector = fn
+		c.tenants = nil
+		if fn != nil {
+			c.tenants = &tenantTransports{
+				transports: make(map[tenantTransportKey]*http.Transport),
+			}
+		}
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+	c.mtx.Unlock()
+}
+
+// tenantTransportKey identifies the transport of a tenant, copied from a
+// base transport.
+type tenantTransportKey struct {
+	base   *http.Transport
+	tenant string
+}
+
+// tenantTransports holds the transports of the tenants.
+type tenantTransports struct {
+	mtx        sync.Mutex
+	transports map[tenantTransportKey]*http.Transport
+}
+
+// transport returns the transport of the tenant, copied from the base
+// transport.
+func (tt *tenantTransports) transport(base *http.Transport, tenant string) *http.Transport {
+	tt.mtx.Lock()
+	defer tt.mtx.Unlock()
+
+	k := tenantTransportKey{base: base, tenant: tenant}
+	t := tt.transports[k]
+	if t == nil {
+		t = base.Clone()
+		tt.transports[k] = t
+	}
+	return t
+}
+
+// reset discards the transports of the tenants, closing their idle
+// connections.
+func (tt *tenantTransports) reset() {
+	tt.mtx.Lock()
+	{
+		for k, t := range tt.transports {
+			t.CloseIdleConnections()
+			delete(tt.transports, k)
+		}
+	}
+	tt.mtx.Unlock()
+}
+
+// closeIdle closes the idle connections of the transports of the tenants
+// copied from the base transport.
+func (tt *tenantTransports) closeIdle(base *http.Transport) {
+	tt.mtx.Lock()
+	{
+		for k, t := range tt.transports {
+			if k.base == base {
+				t.CloseIdleConnections()
+			}
+		}
+	}
+	tt.mtx.Unlock()
+}
+
+// tenantTransport sends the requests carrying a tenant through the
+// transport of their tenant, copied from the base transport, and the
+// other requests through the next transport.
+type tenantTransport struct {
+	next    http.RoundTripper
+	base    *http.Transport
+	tenants *tenantTransports
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *tenantTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tenant, ok := TenantFromContext(req.Context())
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+	return t.tenants.transport(t.base, tenant).RoundTrip(req)
+}
@@ -0,0 +1,90 @@
+package http_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// clientCertificate returns a self-signed client certificate with the
+// specified common name.
+func clientCertificate(t *testing.T, name string) *tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestClientCertSelector(t *testing.T) {
+	server := httptest.NewUnstartedServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	certs := map[string]*tls.Certificate{
+		"tenant-a": clientCertificate(t, "tenant-a"),
+		"tenant-b": clientCertificate(t, "tenant-b"),
+	}
+	errNoTenant := errors.New("no tenant")
+
+	cp := http.NewClientPool()
+	cp.SetDefaultTLSConfig(&tls.Config{RootCAs: roots})
+	cp.SetClientCertSelector(func(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		tenant, ok := http.TenantFromContext(info.Context())
+		if !ok {
+			return nil, errNoTenant
+		}
+		return certs[tenant], nil
+	})
+	client := cp.GetClient(time.Second)
+
+	// The same connection is never shared between tenants.
+	for _, tenant := range []string{"tenant-a", "tenant-b", "tenant-a", "tenant-b"} {
+		ctx := http.ContextWithTenant(context.Background(), tenant)
+		req, _ := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if string(name) != tenant {
+			t.Errorf("server saw the certificate of %q, want %q", name, tenant)
+		}
+	}
+
+	if _, err := client.Get(server.URL); !errors.Is(err, errNoTenant) {
+		t.Errorf("error = %v, want %v", err, errNoTenant)
+	}
+}
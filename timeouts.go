@@ -0,0 +1,129 @@
+package http
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Timeouts is a timeout profile of the clients returned by
+// GetClientTimeouts. Zero fields keep the default behavior.
+type Timeouts struct {
+	// Dial bounds the time to establish a connection, 30 seconds by
+	// default.
+	Dial time.Duration
+
+	// TLSHandshake bounds the time of the TLS handshakes, 10 seconds
+	// by default.
+	TLSHandshake time.Duration
+
+	// ResponseHeader bounds the time waited for the response headers
+	// once the request is written. There is no limit by default.
+	ResponseHeader time.Duration
+
+	// Overall is the timeout of the client, with the same semantics as
+	// the timeout given to GetClient, including the use of the default
+	// timeout of the pool if zero.
+	Overall time.Duration
+}
+
+// transport returns the profile of the transports, without the timeout
+// of the clients.
+func (t Timeouts) transport() Timeouts {
+	t.Overall = 0
+	return t
+}
+
+// GetClientTimeouts returns a HTTP Client for making HTTP calls with the
+// specified timeout profile. The clients for the same profile are
+// shared, and so are their transports across overall timeouts. Profiles
+// with only an overall timeout get the client returned by GetClient.
+//
+// The transport is a copy of the transport of the pool with the timeouts
+// of the profile, and has the same settings and middleware. Transports
+// set with SetTransport that are not of type *http.Transport cannot be
+// copied, nor can the transport reusing the least recently used
+// connections, and are used as is.
+func (c *ClientPool) GetClientTimeouts(t Timeouts) *http.Client {
+	if t.transport() == (Timeouts{}) {
+		return c.GetClient(t.Overall)
+	}
+	if t.Overall <= 0 {
+		t.Overall = time.Duration(atomic.LoadInt64(&c.defaultTimeout))
+	}
+
+	c.mtx.RLock()
+	client := c.timeoutClients[t]
+	c.mtx.RUnlock()
+	if client != nil {
+		return client
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if client := c.timeoutClients[t]; client != nil {
+		return client
+	}
+
+	transport := c.timeoutTransports[t.transport()]
+	if transport == nil {
+		transport = c.newTimeoutTransport(t)
+		if c.timeoutTransports == nil {
+			c.timeoutTransports = make(map[Timeouts]http.RoundTripper)
+		}
+		c.timeoutTransports[t.transport()] = transport
+	}
+
+	client = &http.Client{
+		Transport:     c.wrap(transport),
+		CheckRedirect: c.checkRedirect(),
+		Timeout:       t.Overall,
+	}
+	if c.bases == nil {
+		c.bases = make(map[*http.Client]http.RoundTripper)
+	}
+	c.bases[client] = transport
+	if c.timeoutClients == nil {
+		c.timeoutClients = make(map[Timeouts]*http.Client)
+	}
+	c.timeoutClients[t] = client
+	return client
+}
+
+// newTimeoutTransport returns a copy of the shared transport with the
+// timeouts of the profile. Must be called while holding the lock.
+func (c *ClientPool) newTimeoutTransport(t Timeouts) http.RoundTripper {
+	base, ok := c.sharedTransport().(*http.Transport)
+	if !ok {
+		return c.sharedTransport()
+	}
+
+	transport := base.Clone()
+	if t.TLSHandshake > 0 {
+		transport.TLSHandshakeTimeout = t.TLSHandshake
+	}
+	if t.ResponseHeader > 0 {
+		transport.ResponseHeaderTimeout = t.ResponseHeader
+	}
+	if t.Dial > 0 {
+		switch {
+		case base == c.defaultTransport:
+			transport.DialContext = c.defaultDial(t.Dial)
+		case base.DialContext == nil:
+			transport.DialContext = (&net.Dialer{Timeout: t.Dial, KeepAlive: 30 * time.Second}).DialContext
+		default:
+			// The dial function of the transport set cannot be
+			// extended, only shortened.
+			dial := base.DialContext
+			transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				ctx, cancel := context.WithTimeout(ctx, t.Dial)
+				defer cancel()
+				return dial(ctx, network, addr)
+			}
+		}
+	}
+	return transport
+}
@@ -0,0 +1,81 @@
+package http_test
+
+import (
+	"context"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestGetClientTimeouts(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	defer server.Close()
+
+	dialTimeouts := make(chan time.Duration, 1)
+	restore := http.SetDialContext(func(d *net.Dialer, ctx context.Context, network, addr string) (net.Conn, error) {
+		dialTimeouts <- d.Timeout
+		return d.DialContext(ctx, network, addr)
+	})
+	defer restore()
+
+	cp := http.NewClientPool()
+	timeouts := http.Timeouts{
+		Dial:           time.Second,
+		TLSHandshake:   2 * time.Second,
+		ResponseHeader: 3 * time.Second,
+		Overall:        4 * time.Second,
+	}
+	client := cp.GetClientTimeouts(timeouts)
+	if client.Timeout != 4*time.Second {
+		t.Errorf("got timeout %v, want 4s", client.Timeout)
+	}
+
+	transport, ok := client.Transport.(*nethttp.Transport)
+	if !ok {
+		t.Fatalf("got transport %T, want a *http.Transport", client.Transport)
+	}
+	if transport == cp.SharedTransport() {
+		t.Error("the client uses the shared transport")
+	}
+	if transport.TLSHandshakeTimeout != 2*time.Second || transport.ResponseHeaderTimeout != 3*time.Second {
+		t.Errorf("got TLS handshake timeout %v and response header timeout %v, want 2s and 3s",
+			transport.TLSHandshakeTimeout, transport.ResponseHeaderTimeout)
+	}
+
+	getDiscard(t, client, server.URL)
+	if d := <-dialTimeouts; d != time.Second {
+		t.Errorf("got dial timeout %v, want 1s", d)
+	}
+
+	if again := cp.GetClientTimeouts(timeouts); again != client {
+		t.Error("got a new client for the same profile")
+	}
+
+	// Another overall timeout shares the transport.
+	timeouts.Overall = 5 * time.Second
+	if other := cp.GetClientTimeouts(timeouts); other == client || other.Transport != client.Transport {
+		t.Error("the clients of the profile do not share their transport")
+	}
+}
+
+func TestGetClientTimeoutsDefaults(t *testing.T) {
+	cp := http.NewClientPool()
+	cp.SetDefaultTimeout(time.Minute)
+
+	if client := cp.GetClientTimeouts(http.Timeouts{Overall: time.Second}); client != cp.GetClient(time.Second) {
+		t.Error("a profile with only an overall timeout does not use GetClient")
+	}
+
+	client := cp.GetClientTimeouts(http.Timeouts{ResponseHeader: time.Second})
+	if client.Timeout != time.Minute {
+		t.Errorf("got timeout %v, want the default of the pool", client.Timeout)
+	}
+	transport := client.Transport.(*nethttp.Transport)
+	if transport.TLSHandshakeTimeout != 10*time.Second {
+		t.Errorf("got TLS handshake timeout %v, want the default 10s", transport.TLSHandshakeTimeout)
+	}
+}
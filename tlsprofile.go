@@ -0,0 +1,97 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+)
+
+// TLSProfile is a named set of TLS settings of the default transport,
+// for instance for one of the environments an application is deployed
+// to, see SetTLSProfile.
+type TLSProfile struct {
+	// RootCAs is the set of the certificate authorities trusted to
+	// verify the certificates of the servers. If nil, the authorities
+	// of the host are used.
+	RootCAs *x509.CertPool
+
+	// MinVersion is the minimum TLS version accepted, for instance
+	// tls.VersionTLS12. If zero, the default of the crypto/tls package
+	// is used.
+	MinVersion uint16
+
+	// InsecureSkipVerify disables the verification of the
+	// certificates of the servers. It should only be used in
+	// development environments.
+	InsecureSkipVerify bool
+
+	// Certificates are the client certificates presented to the
+	// servers requesting them.
+	Certificates []tls.Certificate
+}
+
+// config returns the TLS Configuration of the profile.
+func (p TLSProfile) config() *tls.Config {
+	return &tls.Config{
+		RootCAs:            p.RootCAs,
+		MinVersion:         p.MinVersion,
+		InsecureSkipVerify: p.InsecureSkipVerify,
+		Certificates:       append([]tls.Certificate(nil), p.Certificates...),
+	}
+}
+
+// SetTLSProfile registers the TLS profile with the specified name,
+// replacing the profile previously registered with the name, if any. If
+// the profile is in use, the default transport is rebuilt with the new
+// settings.
+func (c *ClientPool) SetTLSProfile(name string, profile TLSProfile) {
+	var active bool
+
+	c.mtx.Lock()
+	{
+		if c.tlsProfiles == nil {
+			c.tlsProfiles = make(map[string]TLSProfile)
+		}
+		c.tlsProfiles[name] = profile
+
+		if active = c.tlsProfile == name; active {
+			c.tlsConfig = profile.config()
+
+			// Ensuring that new clients requested from the pool will
+			// use the new transport settings.
+			c.resetClients()
+		}
+	}
+	c.mtx.Unlock()
+
+	if active {
+		c.warmup()
+	}
+}
+
+// UseTLSProfile makes the default transport use the settings of the TLS
+// profile registered with the specified name, see SetTLSProfile, in
+// place of the configuration set with SetDefaultTLSConfig or of the
+// profile used so far. The clients of the pool are rebuilt, so the
+// requests sent from then on open new connections with the settings of
+// the profile. It fails if no profile is registered with the name.
+// Transports set with SetTransport are not affected.
+func (c *ClientPool) UseTLSProfile(name string) error {
+	c.mtx.Lock()
+	profile, ok := c.tlsProfiles[name]
+	if ok {
+		c.tlsProfile = name
+		c.tlsConfig = profile.config()
+
+		// Ensuring that new clients requested from the pool will use
+		// the new transport settings.
+		c.resetClients()
+	}
+	c.mtx.Unlock()
+
+	if !ok {
+		return fmt.Errorf("http: unknown TLS profile %q", name)
+	}
+	c.warmup()
+	return nil
+}
@@ -0,0 +1,67 @@
+package http_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestTLSProfiles(t *testing.T) {
+	server := httptest.NewTLSServer(nethttp.HandlerFunc(func(nethttp.ResponseWriter, *nethttp.Request) {}))
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	pool := http.NewClientPool()
+	pool.SetTLSProfile("dev", http.TLSProfile{InsecureSkipVerify: true})
+	pool.SetTLSProfile("prod", http.TLSProfile{RootCAs: x509.NewCertPool(), MinVersion: tls.VersionTLS13})
+
+	if err := pool.UseTLSProfile("stage"); err == nil {
+		t.Error("expected an error using an unknown profile")
+	}
+
+	get := func() error {
+		resp, err := pool.GetClient(time.Second).Get(server.URL)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+	config := func() *tls.Config {
+		return pool.SharedTransport().(*nethttp.Transport).TLSClientConfig
+	}
+
+	if err := pool.UseTLSProfile("dev"); err != nil {
+		t.Fatal(err)
+	}
+	if c := config(); !c.InsecureSkipVerify {
+		t.Error("expected the dev profile to skip the verification")
+	}
+	if err := get(); err != nil {
+		t.Errorf("dev profile: %v", err)
+	}
+
+	// The prod profile does not trust the certificate of the server.
+	if err := pool.UseTLSProfile("prod"); err != nil {
+		t.Fatal(err)
+	}
+	if c := config(); c.InsecureSkipVerify || c.MinVersion != tls.VersionTLS13 {
+		t.Errorf("got InsecureSkipVerify %t and MinVersion %x, want the settings of the prod profile", c.InsecureSkipVerify, c.MinVersion)
+	}
+	if err := get(); err == nil {
+		t.Error("prod profile: expected a certificate verification error")
+	}
+
+	// Updating the profile in use rebuilds the clients.
+	pool.SetTLSProfile("prod", http.TLSProfile{RootCAs: roots, MinVersion: tls.VersionTLS13})
+	if err := get(); err != nil {
+		t.Errorf("updated prod profile: %v", err)
+	}
+}
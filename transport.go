@@ -0,0 +1,68 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// closeRequestBody closes the body of a request that will not be sent,
+// as required from every RoundTripper.
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// discardBody drains and closes the body of a response that will not be
+// returned, so its connection can be reused.
+func discardBody(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDiscardBytes))
+	resp.Body.Close()
+}
+
+// maxDiscardBytes is the maximum number of bytes read from a discarded
+// body to reuse its connection. Larger bodies are simply closed.
+const maxDiscardBytes = 64 << 10
+
+// sleep waits for the specified duration of the clock or until the
+// context is done.
+func sleep(ctx context.Context, clk clock, d time.Duration) error {
+	timer := clk.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cancelOnCloseBody is a response body releasing the context of its
+// request once it is closed.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// releaseBody calls release once it is closed.
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"net/http"
+	"time"
+)
+
+// GetUpgradeClient returns a HTTP Client for long-lived connections
+// upgraded to another protocol, such as WebSocket, whose connections are
+// established within dialTimeout, or 30 seconds if not positive. The
+// clients for the same dial timeout are shared.
+//
+// Unlike the clients returned by GetClient, the client has no overall
+// timeout, since the upgraded connection lives as long as the caller
+// needs it, and its transport neither waits for the response headers
+// for a limited time nor requests compressed responses. The transport
+// always has the settings of the default transport of the pool, with its
+// TLS Configuration, proxy and dialing options, even if a transport was
+// set with SetTransport, and the middleware of the pool is not applied
+// since it is meant for plain requests. The responses with the 101
+// status code have a body that can also be written to, as documented by
+// the core http package.
+func (c *ClientPool) GetUpgradeClient(dialTimeout time.Duration) *http.Client {
+	if dialTimeout <= 0 {
+		dialTimeout = 30 * time.Second
+	}
+
+	c.mtx.RLock()
+	client := c.upgrade[dialTimeout]
+	c.mtx.RUnlock()
+	if client != nil {
+		return client
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if client := c.upgrade[dialTimeout]; client != nil {
+		return client
+	}
+
+	transport := c.newDefaultTransport()
+	transport.DialContext = c.defaultDial(dialTimeout)
+	transport.DisableCompression = true
+
+	client = &http.Client{
+		Transport:     transport,
+		CheckRedirect: c.checkRedirect(),
+	}
+	if c.upgrade == nil {
+		c.upgrade = make(map[time.Duration]*http.Client)
+	}
+	c.upgrade[dialTimeout] = client
+	return client
+}
@@ -0,0 +1,96 @@
+package http_test
+
+import (
+	"bufio"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+// newEchoUpgradeServer returns a server switching the requests to an
+// echo protocol once upgraded.
+func newEchoUpgradeServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.Header.Get("Upgrade") != "echo" {
+			nethttp.Error(w, "upgrade required", nethttp.StatusUpgradeRequired)
+			return
+		}
+		conn, rw, err := w.(nethttp.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		rw.Flush()
+		io.Copy(conn, rw)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGetUpgradeClient(t *testing.T) {
+	server := newEchoUpgradeServer(t)
+
+	cp := http.NewClientPool()
+	cp.SetDefaultTimeout(50 * time.Millisecond)
+	client := cp.GetUpgradeClient(time.Second)
+	if client.Timeout != 0 {
+		t.Errorf("got timeout %v, want none", client.Timeout)
+	}
+	if again := cp.GetUpgradeClient(time.Second); again != client {
+		t.Error("got a new client for the same dial timeout")
+	}
+
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, server.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "echo")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != nethttp.StatusSwitchingProtocols {
+		t.Fatalf("got status %d, want 101", resp.StatusCode)
+	}
+
+	// The connection outlives the default timeout of the pool.
+	time.Sleep(100 * time.Millisecond)
+
+	conn := resp.Body.(io.ReadWriteCloser)
+	if _, err := io.WriteString(conn, "hello\n"); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "hello\n" {
+		t.Errorf("got %q, want %q", line, "hello\n")
+	}
+}
+
+func TestGetUpgradeClientTransport(t *testing.T) {
+	cp := http.NewClientPool()
+	cp.SetTransport(okTransport)
+
+	client := cp.GetUpgradeClient(0)
+	transport, ok := client.Transport.(*nethttp.Transport)
+	if !ok {
+		t.Fatalf("got transport %T, want a *http.Transport", client.Transport)
+	}
+	if !transport.DisableCompression || transport.ResponseHeaderTimeout != 0 {
+		t.Error("the transport requests compression or limits the wait for the headers")
+	}
+
+	// Changing the settings of the pool creates a new client.
+	cp.SetDefaultTLSConfig(nil)
+	if cp.GetUpgradeClient(0) == client {
+		t.Error("got the same client after a settings change")
+	}
+}
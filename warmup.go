@@ -0,0 +1,60 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// warmupTimeout is the timeout of the requests used to warm up the
+// connections of a new transport.
+const warmupTimeout = 10 * time.Second
+
+// WithWarmupOnSwap makes the pool asynchronously warm up connections to
+// the specified URLs whenever its transport or TLS configuration
+// changes, so that the first requests on the new transport do not pay
+// the cost of establishing connections. The URLs are requested with
+// HEAD requests.
+func WithWarmupOnSwap(urls []string) Option {
+	urls = append([]string(nil), urls...)
+
+	return func(c *ClientPool) {
+		c.warmupURLs = urls
+	}
+}
+
+// WithWarmupErrorHandler sets the function called with the errors
+// encountered while warming up connections. Errors are ignored if no
+// handler is set.
+func WithWarmupErrorHandler(fn func(url string, err error)) Option {
+	return func(c *ClientPool) {
+		c.onWarmupError = fn
+	}
+}
+
+// warmup requests the warmup URLs in the background using the current
+// transport of the pool.
+func (c *ClientPool) warmup() {
+	if len(c.warmupURLs) == 0 {
+		return
+	}
+
+	client := c.GetClient(warmupTimeout)
+	for _, url := range c.warmupURLs {
+		go c.warmupURL(client, url)
+	}
+}
+
+func (c *ClientPool) warmupURL(client *http.Client, url string) {
+	resp, err := client.Head(url)
+	if err != nil {
+		if c.onWarmupError != nil {
+			c.onWarmupError(url, err)
+		}
+		return
+	}
+
+	// Drain the body so the connection is kept alive for reuse.
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
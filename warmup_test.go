@@ -0,0 +1,135 @@
+package http_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	nethttp "net/http"
+	"testing"
+	"time"
+
+	"github.com/Updater/http"
+)
+
+func TestWarmupOnSetTransport(t *testing.T) {
+	urls := []string{"http://a.example.com/", "http://b.example.com/"}
+	cp := http.NewClientPool(http.WithWarmupOnSwap(urls))
+
+	warmed := make(chan string, len(urls))
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		if req.Method != nethttp.MethodHead {
+			t.Errorf("warmup method = %s, want HEAD", req.Method)
+		}
+		warmed <- req.URL.String()
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+
+	got := make(map[string]bool)
+	for range urls {
+		select {
+		case url := <-warmed:
+			got[url] = true
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for warmup")
+		}
+	}
+	for _, url := range urls {
+		if !got[url] {
+			t.Errorf("%s was not warmed up", url)
+		}
+	}
+}
+
+func TestWarmupErrorHandler(t *testing.T) {
+	errWarmup := errors.New("warmup failed")
+	failed := make(chan error, 1)
+
+	cp := http.NewClientPool(
+		http.WithWarmupOnSwap([]string{"http://example.com/"}),
+		http.WithWarmupErrorHandler(func(url string, err error) {
+			failed <- err
+		}),
+	)
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		return nil, errWarmup
+	}))
+
+	select {
+	case err := <-failed:
+		if !errors.Is(err, errWarmup) {
+			t.Fatalf("error = %v, want %v", err, errWarmup)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for warmup error")
+	}
+}
+
+func TestWarmupDisabled(t *testing.T) {
+	cp := http.NewClientPool()
+	cp.SetTransport(roundTripperFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		t.Error("unexpected warmup request")
+		return statusResponse(req, nethttp.StatusOK), nil
+	}))
+	time.Sleep(10 * time.Millisecond)
+}
+
+func TestWarmupOnTLSAndTemporarySwaps(t *testing.T) {
+	errDial := errors.New("dial failed")
+	defer http.SetDialContext(func(*net.Dialer, context.Context, string, string) (net.Conn, error) {
+		return nil, errDial
+	})()
+
+	warmed := make(chan error, 10)
+	cp := http.NewClientPool(
+		http.WithWarmupOnSwap([]string{"http://example.com/"}),
+		http.WithWarmupErrorHandler(func(url string, err error) {
+			warmed <- err
+		}),
+	)
+	cp.SetTLSProfile("strict", http.TLSProfile{MinVersion: tls.VersionTLS13})
+
+	// expectWarmup waits for the warmup through the default transport,
+	// or through the temporary transport if temporary is set.
+	errTemporary := errors.New("temporary transport")
+	expectWarmup := func(step string, temporary bool) {
+		t.Helper()
+
+		want := errDial
+		if temporary {
+			want = errTemporary
+		}
+		select {
+		case err := <-warmed:
+			if !errors.Is(err, want) {
+				t.Errorf("%s: warmup error = %v, want %v", step, err, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s: timed out waiting for warmup", step)
+		}
+	}
+
+	if err := cp.UseTLSProfile("strict"); err != nil {
+		t.Fatal(err)
+	}
+	expectWarmup("UseTLSProfile", false)
+	cp.SetTLSProfile("strict", http.TLSProfile{MinVersion: tls.VersionTLS12})
+	expectWarmup("SetTLSProfile", false)
+	cp.SetPinnedPublicKeys("example.com", [][]byte{{1}})
+	expectWarmup("SetPinnedPublicKeys", false)
+
+	cp.WithTemporaryTransport(roundTripperFunc(func(*nethttp.Request) (*nethttp.Response, error) {
+		return nil, errTemporary
+	}), func() {
+		expectWarmup("WithTemporaryTransport", true)
+	})
+	expectWarmup("WithTemporaryTransport restore", false)
+
+	// Registering a profile not in use does not change the transport.
+	cp.SetTLSProfile("other", http.TLSProfile{})
+	select {
+	case err := <-warmed:
+		t.Errorf("unexpected warmup: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
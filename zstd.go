@@ -0,0 +1,112 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdDecoders holds the zstd decoders that are not used by any body.
+var zstdDecoders sync.Pool
+
+// WithZstd makes the clients of the pool request zstd-compressed
+// responses, along with gzip-compressed ones, and decompress them, zstd
+// responses with decoders reused across responses. The responses using
+// other encodings are returned as is. As with the core http package,
+// requests that already carry an Accept-Encoding header are sent as is
+// and their responses are not decompressed.
+//
+// A decoder is returned to the pool once the body using it is closed.
+func WithZstd() Option {
+	return func(c *ClientPool) {
+		c.use(func(next http.RoundTripper) http.RoundTripper {
+			return &zstdTransport{next: next}
+		})
+	}
+}
+
+// zstdTransport decompresses the zstd and gzip responses of the next
+// transport.
+type zstdTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *zstdTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept-Encoding") != "" || req.Header.Get("Range") != "" || req.Method == http.MethodHead {
+		return t.next.RoundTrip(req)
+	}
+
+	zreq := req.Clone(req.Context())
+	zreq.Header.Set("Accept-Encoding", "zstd, gzip")
+
+	resp, err := t.next.RoundTrip(zreq)
+	if err != nil {
+		return nil, err
+	}
+
+	// The core http package no longer decompresses gzip responses
+	// since the request carries an Accept-Encoding header.
+	switch encoding := resp.Header.Get("Content-Encoding"); {
+	case strings.EqualFold(encoding, "zstd"):
+		resp.Body = &zstdBody{body: resp.Body}
+	case strings.EqualFold(encoding, "gzip"):
+		resp.Body = &gzipBody{body: resp.Body}
+	default:
+		return resp, nil
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// zstdBody decompresses a response body with a pooled decoder, acquired
+// on the first read. The decoder is guarded by the mutex, since the body
+// can be closed while being read.
+type zstdBody struct {
+	body io.ReadCloser
+
+	mtx     sync.Mutex
+	decoder *zstd.Decoder
+	err     error
+}
+
+func (b *zstdBody) Read(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.decoder == nil && b.err == nil {
+		if zd, ok := zstdDecoders.Get().(*zstd.Decoder); ok {
+			b.decoder, b.err = zd, zd.Reset(b.body)
+		} else {
+			// A single goroutine decodes the stream, since bodies
+			// are read as they arrive.
+			b.decoder, b.err = zstd.NewReader(b.body, zstd.WithDecoderConcurrency(1))
+		}
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.decoder.Read(p)
+}
+
+// Close closes the body and returns its decoder to the pool, once a read
+// in progress, which fails with the closed body, returns.
+func (b *zstdBody) Close() error {
+	err := b.body.Close()
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.decoder != nil {
+		zstdDecoders.Put(b.decoder)
+		b.decoder = nil
+	}
+	b.err = http.ErrBodyReadAfterClose
+	return err
+}
@@ -0,0 +1,109 @@
+package http_test
+
+import (
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/Updater/http"
+)
+
+func zstdCompressed(payload string) []byte {
+	zw, _ := zstd.NewWriter(nil)
+	defer zw.Close()
+	return zw.EncodeAll([]byte(payload), nil)
+}
+
+func TestWithZstd(t *testing.T) {
+	payload := strings.Repeat("zstd payload ", 100)
+	var accepted string
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		accepted = r.Header.Get("Accept-Encoding")
+		switch r.URL.Path {
+		case "/zstd":
+			w.Header().Set("Content-Encoding", "zstd")
+			w.Write(zstdCompressed(payload))
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipped(payload))
+		case "/br":
+			w.Header().Set("Content-Encoding", "br")
+			io.WriteString(w, "not really brotli")
+		default:
+			io.WriteString(w, payload)
+		}
+	}))
+	defer srv.Close()
+
+	cp := http.NewClientPool(http.WithZstd())
+	tests := []struct {
+		path     string
+		want     string
+		encoding string
+	}{
+		{"/zstd", payload, ""},
+		{"/gzip", payload, ""},
+		{"/plain", payload, ""},
+		{"/br", "not really brotli", "br"},
+	}
+	for _, tt := range tests {
+		// Every response is read twice to reuse the pooled decoders.
+		for i := 0; i < 2; i++ {
+			resp, err := cp.GetClient(0).Get(srv.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatalf("%s: %v", tt.path, err)
+			}
+
+			if !strings.Contains(accepted, "zstd") {
+				t.Errorf("%s: got Accept-Encoding %q, want zstd", tt.path, accepted)
+			}
+			if string(body) != tt.want {
+				t.Errorf("%s: got body %.40q, want %.40q", tt.path, body, tt.want)
+			}
+			if got := resp.Header.Get("Content-Encoding"); got != tt.encoding {
+				t.Errorf("%s: got Content-Encoding %q, want %q", tt.path, got, tt.encoding)
+			}
+		}
+	}
+}
+
+func TestWithZstdExplicitEncoding(t *testing.T) {
+	compressed := zstdCompressed("payload")
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Header().Set("Content-Encoding", "zstd")
+		w.Write(compressed)
+	}))
+	defer srv.Close()
+
+	cp := http.NewClientPool(http.WithZstd())
+	req, _ := nethttp.NewRequest(nethttp.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept-Encoding", "zstd")
+	resp, err := cp.GetClient(0).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if string(body) != string(compressed) || resp.Header.Get("Content-Encoding") != "zstd" {
+		t.Errorf("response to a request with an Accept-Encoding header was decompressed")
+	}
+}
+
+func TestWithZstdCloseWhileReading(t *testing.T) {
+	payload := strings.Repeat("zstd payload ", 10000)
+	cp := http.NewClientPool(http.WithZstd())
+	cp.SetTransport(stalledTransport("zstd", zstdCompressed(payload)))
+
+	testCloseWhileReading(t, cp.GetClient(time.Second), payload)
+}